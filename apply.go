@@ -0,0 +1,812 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// declaredConfigVersion is the version of the file format read by 'hue apply'
+const declaredConfigVersion = 1
+
+// declaredConfig describes a whole lighting setup in one file. 'hue apply'
+// brings the local files and the bridge in line with it and 'hue export'
+// writes it from the current state.
+type declaredConfig struct {
+	Version int               `yaml:"version"`
+	Colors  map[string]string `yaml:"colors,omitempty"`
+	Groups  []declaredGroup   `yaml:"groups,omitempty"`
+	Scenes  []Scene           `yaml:"scenes,omitempty"`
+	Areas   []declaredArea    `yaml:"areas,omitempty"`
+}
+
+type declaredGroup struct {
+	Name   string   `yaml:"name"`
+	Lights []string `yaml:"lights"`
+	Sync   bool     `yaml:"sync,omitempty"` // keep a bridge light group, see 'hue group sync'
+}
+
+type declaredArea struct {
+	Name      string              `yaml:"name"`
+	Lights    []string            `yaml:"lights"` // light names or IDs
+	Locations map[string]Location `yaml:"locations,omitempty"`
+}
+
+// yamlErrorPattern matches the line number in yaml.v3 error messages
+var yamlErrorPattern = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// yamlTypePattern matches the Go type names yaml.v3 appends to field errors
+var yamlTypePattern = regexp.MustCompile(` in type [\w.]+`)
+
+// isTOMLFile reports whether a declarative config file is TOML rather than
+// YAML, which is decided by its extension
+func isTOMLFile(file string) bool {
+	return strings.EqualFold(filepath.Ext(file), ".toml")
+}
+
+// tomlToYAML converts a TOML document to YAML so both formats share one
+// decoder and the same checks
+func tomlToYAML(data []byte) ([]byte, error) {
+	var doc map[string]interface{}
+	if err := toml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(doc)
+}
+
+// yamlToTOML converts a YAML document written by 'hue export' to TOML
+func yamlToTOML(data []byte) ([]byte, error) {
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	if err := toml.NewEncoder(&out).Encode(doc); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// loadDeclaredConfig reads and validates a declarative config file, as
+// TOML if its name ends in .toml and as YAML otherwise. Problems in a TOML
+// file have no line number once its syntax is valid.
+func loadDeclaredConfig(file string) (*declaredConfig, error) {
+	var data []byte
+	var err error
+	if file == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	isTOML := isTOMLFile(file)
+	if isTOML {
+		if data, err = tomlToYAML(data); err != nil {
+			return nil, &configFileError{File: file, Problems: tomlProblems(err), Listed: true}
+		}
+	}
+
+	var config declaredConfig
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil && err != io.EOF {
+		problems := yamlProblems(err)
+		if isTOML {
+			// The lines are those of the converted document
+			for i := range problems {
+				problems[i].Line = 0
+			}
+		}
+		return nil, &configFileError{File: file, Problems: problems, Listed: true}
+	}
+
+	problems := &problemList{}
+	if !isTOML {
+		problems.lines = yamlLines(data)
+	}
+	if config.Version > declaredConfigVersion {
+		problems.add("version", "file version %d was written by a newer version of hue (this one reads up to version %d)", config.Version, declaredConfigVersion)
+	}
+	checkDeclaredConfig(problems, &config)
+	if len(problems.problems) > 0 {
+		return nil, &configFileError{File: file, Problems: problems.problems, Listed: true}
+	}
+	return &config, nil
+}
+
+// tomlProblems turns a TOML syntax error into a problem with its line number
+func tomlProblems(err error) []configProblem {
+	var parseErr toml.ParseError
+	if errors.As(err, &parseErr) {
+		return []configProblem{{Line: parseErr.Position.Line, Message: parseErr.Message}}
+	}
+	return []configProblem{{Message: strings.TrimPrefix(err.Error(), "toml: ")}}
+}
+
+// yamlProblems turns a yaml.v3 decoding error into problems with line numbers
+func yamlProblems(err error) []configProblem {
+	messages := []string{err.Error()}
+	if typeErr, ok := err.(*yaml.TypeError); ok {
+		messages = typeErr.Errors
+	}
+
+	problems := make([]configProblem, len(messages))
+	for i, message := range messages {
+		problems[i] = configProblem{Message: strings.TrimPrefix(message, "yaml: ")}
+		if match := yamlErrorPattern.FindStringSubmatch(message); match != nil {
+			problems[i].Line, _ = strconv.Atoi(match[1])
+			problems[i].Message = yamlTypePattern.ReplaceAllString(match[2], "")
+		}
+	}
+	return problems
+}
+
+// yamlLines maps the path of every value in a YAML document, such as
+// scenes[0].commands[1], to the line the value starts on
+func yamlLines(data []byte) map[string]int {
+	lines := make(map[string]int)
+	var root yaml.Node
+	if yaml.Unmarshal(data, &root) != nil {
+		return lines
+	}
+
+	var walk func(node *yaml.Node, path string)
+	walk = func(node *yaml.Node, path string) {
+		lines[path] = node.Line
+		switch node.Kind {
+		case yaml.DocumentNode:
+			for _, child := range node.Content {
+				walk(child, path)
+			}
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				child := node.Content[i].Value
+				if path != "" {
+					child = path + "." + child
+				}
+				walk(node.Content[i+1], child)
+			}
+		case yaml.SequenceNode:
+			for i, child := range node.Content {
+				walk(child, fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	}
+	walk(&root, "")
+	return lines
+}
+
+func checkDeclaredConfig(problems *problemList, config *declaredConfig) {
+	declaredColorAliases = config.Colors
+	defer func() { declaredColorAliases = nil }()
+
+	for _, name := range sortedKeys(config.Colors) {
+		if _, err := parseColorWithAliases(config.Colors[name], config.Colors, 0); err != nil {
+			problems.add("colors."+name, "%v", err)
+		}
+	}
+
+	names := make(map[string]bool)
+	for i, group := range config.Groups {
+		path := fmt.Sprintf("groups[%d]", i)
+		checkDeclaredName(problems, path, "group", group.Name, names)
+		checkDeclaredLights(problems, path, "group", group.Lights)
+	}
+
+	checkScenes(problems, "scenes", config.Scenes)
+
+	names = make(map[string]bool)
+	for i, area := range config.Areas {
+		path := fmt.Sprintf("areas[%d]", i)
+		checkDeclaredName(problems, path, "area", area.Name, names)
+		checkDeclaredLights(problems, path, "area", area.Lights)
+	}
+}
+
+func checkDeclaredName(problems *problemList, path, kind, name string, seen map[string]bool) {
+	switch {
+	case name == "":
+		problems.add(path+".name", "%s has no name", kind)
+	case seen[name]:
+		problems.add(path+".name", "duplicate %s name '%s'", kind, name)
+	}
+	seen[name] = true
+}
+
+func checkDeclaredLights(problems *problemList, path, kind string, lights []string) {
+	if len(lights) == 0 {
+		problems.add(path+".lights", "%s has no lights", kind)
+	}
+	for i, light := range lights {
+		if strings.TrimSpace(light) == "" {
+			problems.add(fmt.Sprintf("%s.lights[%d]", path, i), "empty light name")
+		}
+	}
+}
+
+// planChange is one change 'hue apply' makes
+type planChange struct {
+	Action string `json:"action" yaml:"action"` // "add", "change" or "remove"
+	Kind   string `json:"kind" yaml:"kind"`     // "color", "group", "scene" or "area"
+	Name   string `json:"name" yaml:"name"`
+	Detail string `json:"detail,omitempty" yaml:"detail,omitempty"`
+}
+
+type planDocument struct {
+	Changes []planChange `json:"changes" yaml:"changes"`
+}
+
+// applyPlan holds the changes to make together with the updated local
+// files and the bridge changes that go with them
+type applyPlan struct {
+	changes []planChange
+
+	profiles      *ProfileConfig
+	groups        *GroupConfig
+	scenes        *SceneConfig
+	colorsChanged bool
+	groupsChanged bool
+	scenesChanged bool
+	sync          []string       // groups whose bridge light group is created or updated
+	unsync        []string       // groups whose bridge light group is deleted
+	bridgeChanges []func() error // other bridge changes, run in order
+}
+
+func (p *applyPlan) add(action, kind, name, detail string) {
+	p.changes = append(p.changes, planChange{Action: action, Kind: kind, Name: name, Detail: detail})
+}
+
+// buildPlan compares the declared config with the local files and the
+// bridge. Without prune, items missing from the file are left alone.
+func buildPlan(declared *declaredConfig, prune bool) (*applyPlan, error) {
+	plan := &applyPlan{}
+
+	allLights, err := bridge.GetLights()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lights: %w", err)
+	}
+	bridgeGroups, err := bridge.GetGroups()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get groups: %w", err)
+	}
+
+	if err := plan.planColors(declared, prune); err != nil {
+		return nil, err
+	}
+	if err := plan.planGroups(declared, prune, allLights, bridgeGroups); err != nil {
+		return nil, err
+	}
+	if err := plan.planScenes(declared, prune); err != nil {
+		return nil, err
+	}
+	if err := plan.planAreas(declared, prune, allLights, bridgeGroups); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+func (p *applyPlan) planColors(declared *declaredConfig, prune bool) error {
+	profiles, err := loadProfileConfig()
+	if os.IsNotExist(err) {
+		profiles = &ProfileConfig{Profiles: map[string]BridgeConfig{}}
+	} else if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	p.profiles = profiles
+
+	colors := make(map[string]string)
+	for name, value := range profiles.Colors {
+		colors[name] = value
+	}
+	for _, name := range sortedKeys(declared.Colors) {
+		value := declared.Colors[name]
+		current, exists := colors[name]
+		switch {
+		case !exists:
+			p.add("add", "color", name, value)
+		case current != value:
+			p.add("change", "color", name, current+" -> "+value)
+		default:
+			continue
+		}
+		colors[name] = value
+		p.colorsChanged = true
+	}
+	if prune {
+		for _, name := range sortedKeys(profiles.Colors) {
+			if _, declared := declared.Colors[name]; !declared {
+				p.add("remove", "color", name, "")
+				delete(colors, name)
+				p.colorsChanged = true
+			}
+		}
+	}
+	profiles.Colors = colors
+	return nil
+}
+
+func (p *applyPlan) planGroups(declared *declaredConfig, prune bool, allLights []Light, bridgeGroups []BridgeGroup) error {
+	groups, err := loadGroupConfig()
+	if os.IsNotExist(err) {
+		groups = &GroupConfig{Groups: []Group{}}
+	} else if err != nil {
+		return fmt.Errorf("failed to load groups: %w", err)
+	}
+	p.groups = groups
+
+	for _, want := range declared.Groups {
+		var details []string
+		action := "change"
+
+		group := findGroup(groups, want.Name)
+		switch {
+		case group == nil:
+			action = "add"
+			details = append(details, "lights: "+strings.Join(want.Lights, ", "))
+			groups.Groups = append(groups.Groups, Group{Name: want.Name, Lights: want.Lights})
+			group = &groups.Groups[len(groups.Groups)-1]
+			p.groupsChanged = true
+		case !reflect.DeepEqual(group.Lights, want.Lights):
+			details = append(details, fmt.Sprintf("lights: %s -> %s", strings.Join(group.Lights, ", "), strings.Join(want.Lights, ", ")))
+			group.Lights = want.Lights
+			p.groupsChanged = true
+		}
+
+		switch {
+		case want.Sync && !groupInSync(group, allLights, bridgeGroups):
+			details = append(details, "sync to bridge")
+			p.sync = append(p.sync, want.Name)
+		case !want.Sync && group.BridgeID != "":
+			details = append(details, "delete bridge group "+group.BridgeID)
+			p.unsync = append(p.unsync, want.Name)
+		}
+
+		if len(details) > 0 {
+			p.add(action, "group", want.Name, strings.Join(details, "; "))
+		}
+	}
+
+	if prune {
+		kept := groups.Groups[:0]
+		for _, group := range groups.Groups {
+			if declaredGroupNamed(declared.Groups, group.Name) {
+				kept = append(kept, group)
+				continue
+			}
+			p.add("remove", "group", group.Name, "")
+			if group.BridgeID != "" {
+				id := group.BridgeID
+				p.bridgeChanges = append(p.bridgeChanges, func() error { return deleteSyncedGroup(id) })
+			}
+			p.groupsChanged = true
+		}
+		groups.Groups = kept
+	}
+	return nil
+}
+
+func declaredGroupNamed(groups []declaredGroup, name string) bool {
+	for _, group := range groups {
+		if group.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// groupInSync reports whether a group's bridge light group exists and has
+// the group's lights
+func groupInSync(group *Group, allLights []Light, bridgeGroups []BridgeGroup) bool {
+	if group.BridgeID == "" {
+		return false
+	}
+	bridgeGroup := findBridgeGroup(bridgeGroups, "LightGroup", group.BridgeID)
+	if bridgeGroup == nil {
+		return false
+	}
+
+	var ids []string
+	for _, identifier := range group.Lights {
+		if light := resolveSingleLight(identifier, allLights); light != nil {
+			ids = append(ids, strconv.Itoa(light.ID))
+		}
+	}
+	return sameLights(ids, bridgeGroup.Lights)
+}
+
+func (p *applyPlan) planScenes(declared *declaredConfig, prune bool) error {
+	scenes, err := loadSceneConfig()
+	if os.IsNotExist(err) {
+		scenes = &SceneConfig{Scenes: []Scene{}}
+	} else if err != nil {
+		return fmt.Errorf("failed to load scenes: %w", err)
+	}
+	p.scenes = scenes
+
+	for _, want := range declared.Scenes {
+		want = normalizeScene(want)
+		detail := fmt.Sprintf("%d commands", len(sceneCommands(&want)))
+		if len(want.Steps) > 0 {
+			detail += fmt.Sprintf(" in %d steps", len(want.Steps))
+		}
+
+		scene := findScene(scenes, want.Name)
+		switch {
+		case scene == nil:
+			p.add("add", "scene", want.Name, detail)
+			scenes.Scenes = append(scenes.Scenes, want)
+		case !reflect.DeepEqual(normalizeScene(*scene), want):
+			p.add("change", "scene", want.Name, detail)
+			*scene = want
+		default:
+			continue
+		}
+		p.scenesChanged = true
+	}
+
+	if prune {
+		kept := scenes.Scenes[:0]
+		for _, scene := range scenes.Scenes {
+			if findScene(&SceneConfig{Scenes: declared.Scenes}, scene.Name) != nil {
+				kept = append(kept, scene)
+				continue
+			}
+			p.add("remove", "scene", scene.Name, "")
+			p.scenesChanged = true
+		}
+		scenes.Scenes = kept
+	}
+	return nil
+}
+
+// normalizeScene makes empty lists non-nil, so scenes read from YAML and
+// from the scene file compare equal
+func normalizeScene(scene Scene) Scene {
+	scene.Commands = normalizeCommands(scene.Commands)
+	scene.Steps = normalizeSteps(scene.Steps)
+	return scene
+}
+
+func normalizeCommands(commands []SceneCommand) []SceneCommand {
+	normalized := make([]SceneCommand, len(commands))
+	for i, command := range commands {
+		if command.Values == nil {
+			command.Values = []string{}
+		}
+		normalized[i] = command
+	}
+	return normalized
+}
+
+func normalizeSteps(steps []SceneStep) []SceneStep {
+	if len(steps) == 0 {
+		return nil
+	}
+	normalized := make([]SceneStep, len(steps))
+	for i, step := range steps {
+		if len(step.Commands) > 0 {
+			step.Commands = normalizeCommands(step.Commands)
+		} else {
+			step.Commands = nil
+		}
+		step.Steps = normalizeSteps(step.Steps)
+		normalized[i] = step
+	}
+	return normalized
+}
+
+func (p *applyPlan) planAreas(declared *declaredConfig, prune bool, allLights []Light, bridgeGroups []BridgeGroup) error {
+	local, err := loadEntertainmentConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load entertainment areas: %w", err)
+	}
+
+	for _, want := range declared.Areas {
+		var ids []string
+		for _, identifier := range want.Lights {
+			light := resolveSingleLight(identifier, allLights)
+			if light == nil {
+				return notFoundError("area '%s': light '%s' not found", want.Name, identifier)
+			}
+			ids = append(ids, strconv.Itoa(light.ID))
+		}
+
+		var area *EntertainmentArea
+		for i := range local.Areas {
+			if local.Areas[i].Name == want.Name {
+				area = &local.Areas[i]
+				break
+			}
+		}
+
+		var bridgeGroup *BridgeGroup
+		if area != nil && area.ID != "" {
+			bridgeGroup = findBridgeGroup(bridgeGroups, "Entertainment", area.ID)
+		}
+
+		switch {
+		case bridgeGroup == nil:
+			p.add("add", "area", want.Name, "lights: "+strings.Join(ids, ", "))
+			p.bridgeChanges = append(p.bridgeChanges, func() error {
+				id, err := createEntertainmentGroup(want.Name, ids)
+				if err != nil {
+					return fmt.Errorf("failed to create entertainment area '%s': %w", want.Name, err)
+				}
+				return saveEntertainmentArea(EntertainmentArea{ID: id, Name: want.Name, Type: "entertainment", Lights: ids, Locations: want.Locations})
+			})
+		case !sameLights(bridgeGroup.Lights, ids) || !reflect.DeepEqual(area.Locations, want.Locations):
+			detail := "locations"
+			if !sameLights(bridgeGroup.Lights, ids) {
+				detail = fmt.Sprintf("lights: %s -> %s", strings.Join(bridgeGroup.Lights, ", "), strings.Join(ids, ", "))
+			}
+			p.add("change", "area", want.Name, detail)
+
+			updated := *area
+			updated.Lights = ids
+			updated.Locations = want.Locations
+			moved := !sameLights(bridgeGroup.Lights, ids)
+			p.bridgeChanges = append(p.bridgeChanges, func() error {
+				if moved {
+					if err := bridge.UpdateGroup(updated.ID, BridgeGroup{Name: updated.Name, Lights: ids}); err != nil {
+						return fmt.Errorf("failed to update entertainment area '%s': %w", updated.Name, err)
+					}
+				}
+				return saveEntertainmentArea(updated)
+			})
+		}
+	}
+
+	if prune {
+		for _, area := range local.Areas {
+			declaredArea := false
+			for _, want := range declared.Areas {
+				declaredArea = declaredArea || want.Name == area.Name
+			}
+			if declaredArea {
+				continue
+			}
+
+			p.add("remove", "area", area.Name, "")
+			p.bridgeChanges = append(p.bridgeChanges, func() error {
+				if area.ID != "" {
+					if err := deleteEntertainmentGroup(area.ID); err != nil && exitCode(err) != exitNotFound {
+						return fmt.Errorf("failed to delete entertainment area '%s': %w", area.Name, err)
+					}
+				}
+				return removeEntertainmentArea(area.Name)
+			})
+		}
+	}
+	return nil
+}
+
+// execute makes the planned changes: local files first, then the bridge
+func (p *applyPlan) execute() error {
+	if p.colorsChanged {
+		if err := saveProfileConfig(*p.profiles); err != nil {
+			return fmt.Errorf("failed to save colors: %w", err)
+		}
+	}
+	if p.scenesChanged {
+		if err := saveSceneConfig(*p.scenes); err != nil {
+			return fmt.Errorf("failed to save scenes: %w", err)
+		}
+	}
+
+	if p.groupsChanged {
+		if err := saveGroupConfig(*p.groups); err != nil {
+			return fmt.Errorf("failed to save groups: %w", err)
+		}
+	}
+	// Syncing resolves the group's lights from the saved group file
+	for _, name := range p.sync {
+		if err := syncGroupToBridge(findGroup(p.groups, name)); err != nil {
+			return err
+		}
+	}
+	for _, name := range p.unsync {
+		group := findGroup(p.groups, name)
+		if err := deleteSyncedGroup(group.BridgeID); err != nil {
+			return err
+		}
+		group.BridgeID = ""
+	}
+	if len(p.sync) > 0 || len(p.unsync) > 0 {
+		if err := saveGroupConfig(*p.groups); err != nil {
+			return fmt.Errorf("failed to save groups: %w", err)
+		}
+	}
+
+	for _, change := range p.bridgeChanges {
+		if err := change(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printPlan writes the planned changes, terraform style
+func printPlan(changes []planChange) error {
+	return printOutput(planDocument{Changes: changes}, func(w io.Writer) {
+		if len(changes) == 0 {
+			fmt.Fprintln(w, "No changes: the local configuration and the bridge match the file")
+			return
+		}
+
+		counts := make(map[string]int)
+		symbols := map[string]string{"add": "+", "change": "~", "remove": "-"}
+		for _, change := range changes {
+			counts[change.Action]++
+			line := fmt.Sprintf("%s\t%s\t%s", symbols[change.Action], change.Kind, change.Name)
+			if change.Detail != "" {
+				line += "\t" + change.Detail
+			}
+			fmt.Fprintln(w, line)
+		}
+		fmt.Fprintf(w, "\nPlan: %d to add, %d to change, %d to remove\n", counts["add"], counts["change"], counts["remove"])
+	})
+}
+
+// confirm asks a yes/no question on the terminal
+func confirm(question string) bool {
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, _ := stdin.ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+var applyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Apply a declarative lighting config file",
+	Long: `Bring the local groups, scenes, color aliases and entertainment areas, and the bridge, in line
+with a YAML or TOML file such as one written by 'hue export'. Files ending in .toml are read as
+TOML, anything else as YAML. The changes are shown as a plan and made after confirmation. Items
+missing from the file are kept unless --prune is given.
+
+Examples:
+  hue apply -f lights.yaml --dry-run
+  hue apply -f lights.yaml
+  hue apply -f lights.yaml --prune --yes`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file, _ := cmd.Flags().GetString("file")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		yes, _ := cmd.Flags().GetBool("yes")
+		prune, _ := cmd.Flags().GetBool("prune")
+		if file == "-" && !yes && !dryRun {
+			return fmt.Errorf("reading the file from stdin needs --yes or --dry-run")
+		}
+
+		declared, err := loadDeclaredConfig(file)
+		if err != nil {
+			return err
+		}
+		unlock, err := lockConfig()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		plan, err := buildPlan(declared, prune)
+		if err != nil {
+			return err
+		}
+
+		if err := printPlan(plan.changes); err != nil {
+			return err
+		}
+		if len(plan.changes) == 0 || dryRun {
+			return nil
+		}
+		if !yes && !confirm("Apply these changes?") {
+			return fmt.Errorf("cancelled, no changes made")
+		}
+
+		if err := plan.execute(); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Applied %d changes\n", len(plan.changes))
+		return nil
+	},
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Write the current setup as a declarative config file",
+	Long: `Write the local groups, scenes, color aliases and entertainment areas of the current profile
+as a file that 'hue apply' reads: TOML if the file name ends in .toml, YAML otherwise.
+
+Examples:
+  hue export > lights.yaml
+  hue export -f lights.yaml
+  hue export -f lights.toml`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file, _ := cmd.Flags().GetString("file")
+
+		config := declaredConfig{Version: declaredConfigVersion}
+		profiles, err := loadProfileConfig()
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to load color aliases: %w", err)
+		}
+		if profiles != nil && len(profiles.Colors) > 0 {
+			config.Colors = profiles.Colors
+		}
+
+		groups, err := loadGroupConfig()
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to load groups: %w", err)
+		}
+		if groups != nil {
+			for _, group := range groups.Groups {
+				config.Groups = append(config.Groups, declaredGroup{Name: group.Name, Lights: group.Lights, Sync: group.BridgeID != ""})
+			}
+		}
+
+		scenes, err := loadSceneConfig()
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to load scenes: %w", err)
+		}
+		if scenes != nil {
+			config.Scenes = scenes.Scenes
+		}
+
+		areas, err := loadEntertainmentConfig()
+		if err != nil {
+			return fmt.Errorf("failed to load entertainment areas: %w", err)
+		}
+		for _, area := range areas.Areas {
+			config.Areas = append(config.Areas, declaredArea{Name: area.Name, Lights: area.Lights, Locations: area.Locations})
+		}
+		sort.Slice(config.Areas, func(i, j int) bool { return config.Areas[i].Name < config.Areas[j].Name })
+
+		var out bytes.Buffer
+		encoder := yaml.NewEncoder(&out)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(config); err != nil {
+			return err
+		}
+		encoder.Close()
+		data := out.Bytes()
+		if isTOMLFile(file) {
+			if data, err = yamlToTOML(data); err != nil {
+				return err
+			}
+		}
+
+		if file == "" || file == "-" {
+			_, err := os.Stdout.Write(data)
+			return err
+		}
+		if err := os.WriteFile(file, data, 0600); err != nil {
+			return err
+		}
+		fmt.Printf("Exported %d groups, %d scenes, %d color aliases and %d areas to %s\n", len(config.Groups), len(config.Scenes), len(config.Colors), len(config.Areas), file)
+		return nil
+	},
+}
+
+func init() {
+	applyCmd.Flags().StringP("file", "f", "", "Config file to apply, or - for stdin")
+	applyCmd.Flags().Bool("dry-run", false, "Only show the plan")
+	applyCmd.Flags().BoolP("yes", "y", false, "Apply without asking for confirmation")
+	applyCmd.Flags().Bool("prune", false, "Remove groups, scenes, aliases and areas missing from the file")
+	applyCmd.MarkFlagRequired("file")
+
+	exportCmd.Flags().StringP("file", "f", "", "Write to this file instead of stdout")
+}
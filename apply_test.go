@@ -0,0 +1,262 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// seedApplyState writes the local colors, groups and scenes the plan tests
+// compare declared configs against
+func seedApplyState(t *testing.T) {
+	t.Helper()
+	if err := saveProfileConfig(ProfileConfig{Colors: map[string]string{"brand": "#1DB954", "old": "red"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveGroupConfig(GroupConfig{Groups: []Group{
+		{Name: "desk", Lights: []string{"1"}},
+		{Name: "spare", Lights: []string{"2"}},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveSceneConfig(SceneConfig{Scenes: []Scene{
+		{Name: "evening", Commands: []SceneCommand{{Type: "on", Light: "1", Values: []string{}}}},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// seededDeclaration declares exactly the state seedApplyState writes
+func seededDeclaration() declaredConfig {
+	return declaredConfig{
+		Colors: map[string]string{"brand": "#1DB954", "old": "red"},
+		Groups: []declaredGroup{{Name: "desk", Lights: []string{"1"}}, {Name: "spare", Lights: []string{"2"}}},
+		Scenes: []Scene{{Name: "evening", Commands: []SceneCommand{{Type: "on", Light: "1"}}}},
+	}
+}
+
+func TestBuildPlan(t *testing.T) {
+	tests := []struct {
+		name    string
+		declare func(*declaredConfig)
+		prune   bool
+		want    []planChange
+	}{
+		{name: "no changes", declare: func(*declaredConfig) {}},
+		{
+			name:    "add a color",
+			declare: func(d *declaredConfig) { d.Colors["accent"] = "coral" },
+			want:    []planChange{{Action: "add", Kind: "color", Name: "accent", Detail: "coral"}},
+		},
+		{
+			name:    "change a color",
+			declare: func(d *declaredConfig) { d.Colors["brand"] = "#ff6600" },
+			want:    []planChange{{Action: "change", Kind: "color", Name: "brand", Detail: "#1DB954 -> #ff6600"}},
+		},
+		{
+			name:    "missing items are kept without prune",
+			declare: func(d *declaredConfig) { *d = declaredConfig{} },
+		},
+		{
+			name: "missing items are removed with prune",
+			declare: func(d *declaredConfig) {
+				delete(d.Colors, "old")
+				d.Groups = d.Groups[:1]
+				d.Scenes = nil
+			},
+			prune: true,
+			want: []planChange{
+				{Action: "remove", Kind: "color", Name: "old"},
+				{Action: "remove", Kind: "group", Name: "spare"},
+				{Action: "remove", Kind: "scene", Name: "evening"},
+			},
+		},
+		{
+			name: "add a group",
+			declare: func(d *declaredConfig) {
+				d.Groups = append(d.Groups, declaredGroup{Name: "hall", Lights: []string{"Hallway", "5"}})
+			},
+			want: []planChange{{Action: "add", Kind: "group", Name: "hall", Detail: "lights: Hallway, 5"}},
+		},
+		{
+			name: "add a synced group",
+			declare: func(d *declaredConfig) {
+				d.Groups = append(d.Groups, declaredGroup{Name: "hall", Lights: []string{"4"}, Sync: true})
+			},
+			want: []planChange{{Action: "add", Kind: "group", Name: "hall", Detail: "lights: 4; sync to bridge"}},
+		},
+		{
+			name:    "change the lights of a group",
+			declare: func(d *declaredConfig) { d.Groups[0].Lights = []string{"1", "3"} },
+			want:    []planChange{{Action: "change", Kind: "group", Name: "desk", Detail: "lights: 1 -> 1, 3"}},
+		},
+		{
+			name: "add and change scenes",
+			declare: func(d *declaredConfig) {
+				d.Scenes[0].Commands = append(d.Scenes[0].Commands, SceneCommand{Type: "brightness", Light: "1", Values: []string{"100"}})
+				d.Scenes = append(d.Scenes, Scene{
+					Name:     "night",
+					Commands: []SceneCommand{{Type: "off", Light: "2"}},
+					Steps:    []SceneStep{{Wait: "1s", Commands: []SceneCommand{{Type: "off", Light: "1"}}}},
+				})
+			},
+			want: []planChange{
+				{Action: "change", Kind: "scene", Name: "evening", Detail: "2 commands"},
+				{Action: "add", Kind: "scene", Name: "night", Detail: "2 commands in 1 steps"},
+			},
+		},
+		{
+			name: "add an entertainment area",
+			declare: func(d *declaredConfig) {
+				d.Areas = []declaredArea{{Name: "Desk", Lights: []string{"Living Room Lamp", "3"}}}
+			},
+			want: []planChange{{Action: "add", Kind: "area", Name: "Desk", Detail: "lights: 1, 3"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useFakeBridge(t)
+			seedApplyState(t)
+
+			declared := seededDeclaration()
+			test.declare(&declared)
+			plan, err := buildPlan(&declared, test.prune)
+			if err != nil {
+				t.Fatalf("buildPlan: %v", err)
+			}
+			if !reflect.DeepEqual(plan.changes, test.want) {
+				t.Errorf("changes = %+v, want %+v", plan.changes, test.want)
+			}
+		})
+	}
+}
+
+func TestBuildPlanWithoutConfigFile(t *testing.T) {
+	useFakeBridge(t)
+	declared := declaredConfig{Colors: map[string]string{"brand": "#1DB954"}}
+	plan, err := buildPlan(&declared, false)
+	if err != nil {
+		t.Fatalf("buildPlan: %v", err)
+	}
+	want := []planChange{{Action: "add", Kind: "color", Name: "brand", Detail: "#1DB954"}}
+	if !reflect.DeepEqual(plan.changes, want) {
+		t.Errorf("changes = %+v, want %+v", plan.changes, want)
+	}
+}
+
+func TestBuildPlanUnknownAreaLight(t *testing.T) {
+	useFakeBridge(t)
+	seedApplyState(t)
+
+	declared := seededDeclaration()
+	declared.Areas = []declaredArea{{Name: "Desk", Lights: []string{"Garage"}}}
+	_, err := buildPlan(&declared, false)
+	checkExitCode(t, err, exitNotFound)
+}
+
+func TestApplyPlanExecute(t *testing.T) {
+	fake := useFakeBridge(t)
+	seedApplyState(t)
+
+	declared := seededDeclaration()
+	declared.Colors["brand"] = "#ff6600"
+	declared.Groups = append(declared.Groups, declaredGroup{Name: "hall", Lights: []string{"4"}, Sync: true})
+	declared.Areas = []declaredArea{{Name: "Desk", Lights: []string{"1", "3"}}}
+	plan, err := buildPlan(&declared, false)
+	if err != nil {
+		t.Fatalf("buildPlan: %v", err)
+	}
+	if err := plan.execute(); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+
+	if group := fakeGroupByName(t, fake, "hall"); group == nil || !reflect.DeepEqual(group.Lights, []string{"4"}) {
+		t.Errorf("bridge light group = %+v, want lights [4]", group)
+	}
+	if area := fakeGroupByName(t, fake, "Desk"); area == nil || area.Type != "Entertainment" {
+		t.Errorf("bridge area = %+v, want an entertainment group", area)
+	}
+
+	// Applying the same file again has nothing left to do
+	plan, err = buildPlan(&declared, false)
+	if err != nil {
+		t.Fatalf("buildPlan: %v", err)
+	}
+	if len(plan.changes) > 0 {
+		t.Errorf("changes after applying = %+v, want none", plan.changes)
+	}
+}
+
+func TestLoadDeclaredConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		data     string
+		want     declaredConfig
+		problems int
+	}{
+		{
+			name: "yaml",
+			file: "hue.yaml",
+			data: "version: 1\ncolors:\n  brand: \"#1DB954\"\ngroups:\n  - name: desk\n    lights: [\"1\", \"3\"]\n",
+			want: declaredConfig{Version: 1, Colors: map[string]string{"brand": "#1DB954"}, Groups: []declaredGroup{{Name: "desk", Lights: []string{"1", "3"}}}},
+		},
+		{
+			name: "toml",
+			file: "hue.toml",
+			data: "version = 1\n\n[colors]\nbrand = \"#1DB954\"\n\n[[groups]]\nname = \"desk\"\nlights = [\"1\", \"3\"]\n",
+			want: declaredConfig{Version: 1, Colors: map[string]string{"brand": "#1DB954"}, Groups: []declaredGroup{{Name: "desk", Lights: []string{"1", "3"}}}},
+		},
+		{
+			name: "scene with a declared color alias",
+			file: "hue.yaml",
+			data: "version: 1\ncolors:\n  brand: \"#1DB954\"\nscenes:\n  - name: logo\n    commands:\n      - {type: color, light: \"1\", values: [brand]}\n",
+			want: declaredConfig{
+				Version: 1,
+				Colors:  map[string]string{"brand": "#1DB954"},
+				Scenes:  []Scene{{Name: "logo", Commands: []SceneCommand{{Type: "color", Light: "1", Values: []string{"brand"}}}}},
+			},
+		},
+		{
+			name:     "every problem is listed",
+			file:     "hue.yaml",
+			data:     "version: 1\ncolors:\n  brand: notacolor\ngroups:\n  - name: \"\"\n    lights: [\"1\"]\n",
+			problems: 2,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useFakeBridge(t)
+			file := filepath.Join(t.TempDir(), test.file)
+			if err := os.WriteFile(file, []byte(test.data), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := loadDeclaredConfig(file)
+			if test.problems > 0 {
+				fileErr, ok := err.(*configFileError)
+				if !ok || !fileErr.Listed || len(fileErr.Problems) != test.problems {
+					t.Fatalf("err = %v, want %d listed problems", err, test.problems)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadDeclaredConfig: %v", err)
+			}
+			if !reflect.DeepEqual(*got, test.want) {
+				t.Errorf("config = %+v, want %+v", *got, test.want)
+			}
+		})
+	}
+}
+
+func TestExportCmdUnreadableConfig(t *testing.T) {
+	useFakeBridge(t)
+	if err := os.WriteFile(configFile, []byte(`{"colors": {`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := exportCmd.RunE(exportCmd, nil); err == nil {
+		t.Error("export succeeded without the color aliases")
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWaitForLinkButton(t *testing.T) {
+	tests := []struct {
+		name    string
+		pressed time.Duration // when the link button is pressed, -1 for never
+		wait    time.Duration
+		code    int
+	}{
+		{name: "already pressed", wait: time.Second},
+		{name: "pressed while waiting", pressed: 1500 * time.Millisecond, wait: 5 * time.Second},
+		{name: "never pressed", pressed: -1, wait: time.Second, code: exitNotAuthorized},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := defaultFakeBridge()
+			fake.setLinkButton(test.pressed == 0)
+			defer fake.setLinkButton(true)
+			if test.pressed > 0 {
+				timer := time.AfterFunc(test.pressed, func() { fake.setLinkButton(true) })
+				defer timer.Stop()
+			}
+
+			var username, clientKey string
+			var err error
+			captureStdout(t, func() {
+				username, clientKey, err = waitForLinkButton(fakeBridgeHost, "test", test.wait)
+			})
+			checkExitCode(t, err, test.code)
+			if err == nil && (username == "" || clientKey == "") {
+				t.Errorf("waitForLinkButton = %q, %q; want a new user", username, clientKey)
+			}
+		})
+	}
+}
+
+func TestWaitForLinkButtonRetriesUnansweredRequests(t *testing.T) {
+	tests := []struct {
+		name       string
+		unanswered int32 // requests for a user the bridge does not answer
+		wait       time.Duration
+		code       int
+	}{
+		{name: "answered after timeouts", unanswered: 2, wait: 5 * time.Second},
+		{name: "never answered", unanswered: 100, wait: 1500 * time.Millisecond, code: exitUnreachable},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useFakeBridge(t)
+			useSessionPins(t)
+			timeout := createUserTimeout
+			createUserTimeout = 200 * time.Millisecond
+			defer func() { createUserTimeout = timeout }()
+
+			fake := newFakeBridge()
+			fake.linkButton = true
+			bridgeServer := &mockBridgeServer{bridge: fake}
+			var attempts int32
+			release := make(chan struct{})
+			server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == "POST" && r.URL.Path == "/api" && atomic.AddInt32(&attempts, 1) <= test.unanswered {
+					<-release
+					return
+				}
+				bridgeServer.ServeHTTP(w, r)
+			}))
+			cert, _ := testCertificate(t, fake.info.BridgeID)
+			server.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+			server.StartTLS()
+			defer server.Close()
+			defer close(release)
+			host := strings.TrimPrefix(server.URL, "https://")
+			if _, _, err := trustBridge(host, fake.info.BridgeID); err != nil {
+				t.Fatal(err)
+			}
+
+			var username string
+			var err error
+			captureStdout(t, func() {
+				username, _, err = waitForLinkButton(host, "test", test.wait)
+			})
+			checkExitCode(t, err, test.code)
+			if err == nil && username == "" {
+				t.Error("waitForLinkButton returned no user")
+			}
+		})
+	}
+}
@@ -0,0 +1,558 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Bridge APIs a profile can select
+const (
+	apiV1 = "v1"
+	apiV2 = "v2" // CLIP v2, /clip/v2/resource
+)
+
+// clipResource is a CLIP v2 resource. All resource types share this struct;
+// fields a type does not have stay nil.
+type clipResource struct {
+	ID       string         `json:"id"`
+	IDV1     string         `json:"id_v1,omitempty"` // e.g. "/lights/1", "/groups/2" or "/scenes/abc"
+	Type     string         `json:"type"`
+	Owner    *clipReference `json:"owner,omitempty"`
+	Metadata *clipMetadata  `json:"metadata,omitempty"`
+
+	On               *clipOn               `json:"on,omitempty"`
+	Dimming          *clipDimming          `json:"dimming,omitempty"`
+	Color            *clipColor            `json:"color,omitempty"`
+	ColorTemperature *clipColorTemperature `json:"color_temperature,omitempty"`
+
+	Services    []clipReference  `json:"services,omitempty"`
+	Children    []clipReference  `json:"children,omitempty"`
+	Group       *clipReference   `json:"group,omitempty"`        // scene
+	ProductData *clipProductData `json:"product_data,omitempty"` // device
+	Status      string           `json:"status,omitempty"`       // zigbee_connectivity, entertainment_configuration
+	BridgeID    string           `json:"bridge_id,omitempty"`    // bridge
+}
+
+type clipReference struct {
+	RID   string `json:"rid"`
+	RType string `json:"rtype"`
+}
+
+type clipMetadata struct {
+	Name      string `json:"name"`
+	Archetype string `json:"archetype,omitempty"`
+}
+
+type clipProductData struct {
+	ModelID string `json:"model_id"`
+}
+
+type clipOn struct {
+	On bool `json:"on"`
+}
+
+// clipDimming is the brightness in percent
+type clipDimming struct {
+	Brightness float64 `json:"brightness"`
+}
+
+type clipXY struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+type clipColor struct {
+	XY        clipXY     `json:"xy"`
+	Gamut     *clipGamut `json:"gamut,omitempty"`
+	GamutType string     `json:"gamut_type,omitempty"`
+}
+
+type clipGamut struct {
+	Red   clipXY `json:"red"`
+	Green clipXY `json:"green"`
+	Blue  clipXY `json:"blue"`
+}
+
+type clipColorTemperature struct {
+	Mirek       *uint16          `json:"mirek"`
+	MirekValid  bool             `json:"mirek_valid"`
+	MirekSchema *clipMirekSchema `json:"mirek_schema,omitempty"`
+}
+
+type clipMirekSchema struct {
+	Minimum uint16 `json:"mirek_minimum"`
+	Maximum uint16 `json:"mirek_maximum"`
+}
+
+// clipLightUpdate is the body of a PUT to a light or grouped_light
+type clipLightUpdate struct {
+	On               *clipOn          `json:"on,omitempty"`
+	Dimming          *clipDimming     `json:"dimming,omitempty"`
+	Color            *clipColorUpdate `json:"color,omitempty"`
+	ColorTemperature *clipMirek       `json:"color_temperature,omitempty"`
+	Dynamics         *clipDynamics    `json:"dynamics,omitempty"`
+	Alert            *clipAction      `json:"alert,omitempty"`
+	Effects          *clipEffect      `json:"effects,omitempty"`
+}
+
+type clipColorUpdate struct {
+	XY clipXY `json:"xy"`
+}
+
+type clipMirek struct {
+	Mirek uint16 `json:"mirek"`
+}
+
+// clipDynamics is the transition duration in milliseconds
+type clipDynamics struct {
+	Duration int `json:"duration"`
+}
+
+type clipAction struct {
+	Action string `json:"action"`
+}
+
+type clipEffect struct {
+	Effect string `json:"effect"`
+}
+
+// clipResponse is the envelope of every CLIP v2 response
+type clipResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Description string `json:"description"`
+	} `json:"errors"`
+}
+
+// v1 alerts and effects and their CLIP v2 counterparts
+var (
+	clipAlerts  = map[string]string{"select": "breathe", "lselect": "breathe"}
+	clipEffects = map[string]string{"none": "no_effect", "colorloop": "prism"}
+)
+
+// newClipLightUpdate converts a v1 state change to CLIP v2. CLIP v2 has no
+// hue and saturation, so those are sent as the matching xy color.
+func newClipLightUpdate(update StateUpdate) clipLightUpdate {
+	var result clipLightUpdate
+	if update.On != nil {
+		result.On = &clipOn{On: *update.On}
+	}
+	if update.Bri != nil {
+		result.Dimming = &clipDimming{Brightness: briToPercent(*update.Bri)}
+	}
+	switch {
+	case len(update.Xy) == 2:
+		result.Color = &clipColorUpdate{XY: clipXY{X: float64(update.Xy[0]), Y: float64(update.Xy[1])}}
+	case update.Hue != nil || update.Sat != nil:
+		hue, sat := uint16(0), uint8(254)
+		if update.Hue != nil {
+			hue = *update.Hue
+		}
+		if update.Sat != nil {
+			sat = *update.Sat
+		}
+		x, y := rgbToXY(hsvToRGB(float64(hue)/65535*360, float64(sat)/254, 1))
+		result.Color = &clipColorUpdate{XY: clipXY{X: float64(x), Y: float64(y)}}
+	}
+	if update.Ct != nil {
+		result.ColorTemperature = &clipMirek{Mirek: *update.Ct}
+	}
+	if update.TransitionTime != nil {
+		result.Dynamics = &clipDynamics{Duration: int(*update.TransitionTime) * 100}
+	}
+	if action, ok := clipAlerts[update.Alert]; ok {
+		result.Alert = &clipAction{Action: action}
+	}
+	if effect, ok := clipEffects[update.Effect]; ok {
+		result.Effects = &clipEffect{Effect: effect}
+	}
+	return result
+}
+
+// toStateUpdate converts a CLIP v2 state change back to v1
+func (u clipLightUpdate) toStateUpdate() StateUpdate {
+	var update StateUpdate
+	if u.On != nil {
+		update.On = boolPtr(u.On.On)
+	}
+	if u.Dimming != nil {
+		update.Bri = uint8Ptr(percentToBri(u.Dimming.Brightness))
+	}
+	if u.Color != nil {
+		update.Xy = []float32{float32(u.Color.XY.X), float32(u.Color.XY.Y)}
+	}
+	if u.ColorTemperature != nil {
+		update.Ct = uint16Ptr(u.ColorTemperature.Mirek)
+	}
+	if u.Dynamics != nil {
+		update.TransitionTime = uint16Ptr(uint16(u.Dynamics.Duration / 100))
+	}
+	if u.Alert != nil {
+		update.Alert = "select"
+	}
+	if u.Effects != nil {
+		for v1, v2 := range clipEffects {
+			if v2 == u.Effects.Effect {
+				update.Effect = v1
+			}
+		}
+	}
+	return update
+}
+
+// briToPercent converts a v1 brightness (1-254) to CLIP v2 percent
+func briToPercent(bri uint8) float64 {
+	return math.Round(float64(bri)/254*10000) / 100
+}
+
+// percentToBri converts a CLIP v2 brightness to v1, keeping lights that are
+// dimmed but not at 0% at a brightness of at least 1
+func percentToBri(percent float64) uint8 {
+	bri := math.Round(percent / 100 * 254)
+	if percent > 0 && bri < 1 {
+		bri = 1
+	}
+	return uint8(math.Min(bri, 254))
+}
+
+// clipLightType names the v1 light type with the same capabilities
+func clipLightType(light clipResource) string {
+	switch {
+	case light.Color != nil && light.ColorTemperature != nil:
+		return "Extended color light"
+	case light.Color != nil:
+		return "Color light"
+	case light.ColorTemperature != nil:
+		return "Color temperature light"
+	case light.Dimming != nil:
+		return "Dimmable light"
+	}
+	return "On/Off plug-in unit"
+}
+
+// clipV1ID returns the v1 ID of a resource, e.g. "2" for "/groups/2"
+func clipV1ID(resource clipResource, collection string) (string, bool) {
+	return strings.CutPrefix(resource.IDV1, "/"+collection+"/")
+}
+
+// clipIndex maps v1 IDs to the UUIDs of CLIP v2 resources
+type clipIndex struct {
+	lights        map[int]string    // light ID to light
+	groupedLights map[string]string // group ID to grouped_light, "0" for all lights
+	scenes        map[string]string // scene ID to scene
+}
+
+func newClipIndex(resources []clipResource) *clipIndex {
+	index := &clipIndex{
+		lights:        map[int]string{},
+		groupedLights: map[string]string{},
+		scenes:        map[string]string{},
+	}
+	for _, resource := range resources {
+		switch resource.Type {
+		case "light":
+			if id, ok := clipV1ID(resource, "lights"); ok {
+				if n, err := strconv.Atoi(id); err == nil {
+					index.lights[n] = resource.ID
+				}
+			}
+		case "grouped_light":
+			if id, ok := clipV1ID(resource, "groups"); ok {
+				index.groupedLights[id] = resource.ID
+			}
+		case "scene":
+			if id, ok := clipV1ID(resource, "scenes"); ok {
+				index.scenes[id] = resource.ID
+			}
+		}
+	}
+	return index
+}
+
+// clipClient talks to the CLIP v2 API for lights, group actions and scene
+// recall. Everything CLIP v2 has no v1 IDs for (editing groups and scenes,
+// users and pairing) goes through the embedded v1 client, as does starting
+// and stopping a stream: the DTLS stream speaks the v1 HueStream format,
+// which the bridge only accepts for areas activated through the v1 API.
+type clipClient struct {
+	*restClient
+
+	mutex sync.Mutex
+	index *clipIndex
+}
+
+func newClipClient(host, username string) *clipClient {
+	return &clipClient{restClient: &restClient{host: host, username: username}}
+}
+
+// newConfiguredClient returns the client for a saved bridge configuration,
+// using CLIP v2 when the profile selects it
+func newConfiguredClient(config BridgeConfig) BridgeClient {
+	if config.API == apiV2 && config.Host != fakeBridgeHost {
+		return newClipClient(config.Host, config.Username)
+	}
+	return newBridgeClient(config.Host, config.Username)
+}
+
+func (c *clipClient) GetLights() ([]Light, error) {
+	resources, err := c.getResources()
+	if err != nil {
+		return nil, err
+	}
+
+	devices := map[string]clipResource{}
+	connectivity := map[string]string{} // device to status
+	for _, resource := range resources {
+		switch resource.Type {
+		case "device":
+			devices[resource.ID] = resource
+		case "zigbee_connectivity":
+			if resource.Owner != nil {
+				connectivity[resource.Owner.RID] = resource.Status
+			}
+		}
+	}
+
+	var lights []Light
+	for _, resource := range resources {
+		if resource.Type != "light" {
+			continue
+		}
+		id, ok := clipV1ID(resource, "lights")
+		n, err := strconv.Atoi(id)
+		if !ok || err != nil {
+			continue
+		}
+		light := resource.toLight(n)
+		if resource.Owner != nil {
+			if device, ok := devices[resource.Owner.RID]; ok && device.ProductData != nil {
+				light.ModelID = device.ProductData.ModelID
+			}
+			if status, ok := connectivity[resource.Owner.RID]; ok {
+				light.State.Reachable = status == "connected"
+			}
+		}
+		lights = append(lights, light)
+	}
+	sort.Slice(lights, func(i, j int) bool {
+		return lights[i].ID < lights[j].ID
+	})
+	return lights, nil
+}
+
+// toLight converts a CLIP v2 light to a Light with v1 ID id
+func (r clipResource) toLight(id int) Light {
+	light := Light{ID: id, UUID: r.ID, Type: clipLightType(r), State: LightState{Reachable: true}}
+	if r.Metadata != nil {
+		light.Name = r.Metadata.Name
+	}
+	if r.On != nil {
+		light.State.On = r.On.On
+	}
+	light.State.Bri = 254
+	if r.Dimming != nil {
+		light.State.Bri = percentToBri(r.Dimming.Brightness)
+	}
+	if r.Color != nil {
+		light.State.Xy = []float32{float32(r.Color.XY.X), float32(r.Color.XY.Y)}
+		light.State.ColorMode = "xy"
+		light.Capabilities = &LightCapabilities{GamutType: r.Color.GamutType}
+		if gamut := r.Color.Gamut; gamut != nil {
+			light.Capabilities.Gamut = [][2]float64{
+				{gamut.Red.X, gamut.Red.Y},
+				{gamut.Green.X, gamut.Green.Y},
+				{gamut.Blue.X, gamut.Blue.Y},
+			}
+		}
+	}
+	if ct := r.ColorTemperature; ct != nil {
+		if ct.Mirek != nil {
+			light.State.Ct = *ct.Mirek
+		}
+		if ct.MirekValid {
+			light.State.ColorMode = "ct"
+		}
+		if light.Capabilities == nil {
+			light.Capabilities = &LightCapabilities{}
+		}
+		if ct.MirekSchema != nil {
+			light.Capabilities.CtMin = ct.MirekSchema.Minimum
+			light.Capabilities.CtMax = ct.MirekSchema.Maximum
+		}
+	}
+	return light
+}
+
+func (c *clipClient) SetLightState(id int, update StateUpdate) error {
+	uuid, ok, err := c.lookup(func(index *clipIndex) (string, bool) {
+		uuid, ok := index.lights[id]
+		return uuid, ok
+	})
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return &BridgeError{
+			Type:        bridgeErrResourceNotFound,
+			Address:     fmt.Sprintf("/lights/%d", id),
+			Description: fmt.Sprintf("resource, /lights/%d, not available", id),
+		}
+	}
+	return c.put("light/"+uuid, newClipLightUpdate(update))
+}
+
+// SetGroupAction uses the group's grouped_light. Groups without one, such
+// as v1 LightGroups, get the v1 group action.
+func (c *clipClient) SetGroupAction(groupID string, update StateUpdate) error {
+	uuid, ok, err := c.lookup(func(index *clipIndex) (string, bool) {
+		uuid, ok := index.groupedLights[groupID]
+		return uuid, ok
+	})
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return c.restClient.SetGroupAction(groupID, update)
+	}
+	return c.put("grouped_light/"+uuid, newClipLightUpdate(update))
+}
+
+// RecallScene activates a scene. CLIP v2 scenes belong to one room or zone,
+// so groupID is only used for scenes that fall back to v1.
+func (c *clipClient) RecallScene(groupID, sceneID string) error {
+	uuid, ok, err := c.lookup(func(index *clipIndex) (string, bool) {
+		uuid, ok := index.scenes[sceneID]
+		return uuid, ok
+	})
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return c.restClient.RecallScene(groupID, sceneID)
+	}
+	return c.put("scene/"+uuid, map[string]interface{}{"recall": clipAction{Action: "active"}})
+}
+
+// lookup finds a UUID in the index, reloading the index once when it was
+// built before the resource was created
+func (c *clipClient) lookup(find func(index *clipIndex) (string, bool)) (string, bool, error) {
+	c.mutex.Lock()
+	index := c.index
+	c.mutex.Unlock()
+
+	if index != nil {
+		if uuid, ok := find(index); ok {
+			return uuid, true, nil
+		}
+	}
+	if _, err := c.getResources(); err != nil {
+		return "", false, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	uuid, ok := find(c.index)
+	return uuid, ok, nil
+}
+
+// getResources reads every resource of the bridge and rebuilds the index
+func (c *clipClient) getResources() ([]clipResource, error) {
+	data, err := c.clipRequest("GET", "", nil)
+	if err != nil {
+		return nil, err
+	}
+	var resources []clipResource
+	if err := json.Unmarshal(data, &resources); err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	c.index = newClipIndex(resources)
+	c.mutex.Unlock()
+	return resources, nil
+}
+
+func (c *clipClient) put(resource string, payload interface{}) error {
+	_, err := c.clipRequest("PUT", "/"+resource, payload)
+	return err
+}
+
+// clipRequest calls /clip/v2/resource<path> and returns the data of the
+// response. Errors are reported as the BridgeError of the v1 API with the
+// same meaning, so they map to the same exit codes.
+func (c *clipClient) clipRequest(method, path string, payload interface{}) (json.RawMessage, error) {
+	var reqBody io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		reqBody = bytes.NewBuffer(data)
+	}
+
+	req, err := http.NewRequest(method, buildBridgeURL(c.host, "/clip/v2/resource"+path), reqBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("hue-application-key", c.username)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := bridgeHTTPClient(c.host).Do(req)
+	if err != nil {
+		return nil, certificateError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		return nil, &httpStatusError{StatusCode: resp.StatusCode}
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var result clipResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		if resp.StatusCode >= 400 {
+			return nil, &httpStatusError{StatusCode: resp.StatusCode}
+		}
+		return nil, fmt.Errorf("unexpected response from bridge: %w", err)
+	}
+	if resp.StatusCode >= 400 || len(result.Errors) > 0 {
+		description := http.StatusText(resp.StatusCode)
+		if len(result.Errors) > 0 {
+			description = result.Errors[0].Description
+		}
+		return nil, &BridgeError{Type: clipErrorType(resp.StatusCode), Address: path, Description: description}
+	}
+	return result.Data, nil
+}
+
+// clipErrorType returns the v1 error type for a CLIP v2 HTTP status
+func clipErrorType(status int) int {
+	switch status {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return bridgeErrUnauthorized
+	case http.StatusNotFound:
+		return bridgeErrResourceNotFound
+	case http.StatusInternalServerError:
+		return bridgeErrInternal
+	}
+	return bridgeErrInvalidValue
+}
+
+// checkClipSupport verifies that the bridge at host serves CLIP v2 for username
+func checkClipSupport(host, username string) error {
+	_, err := newClipClient(host, username).clipRequest("GET", "/bridge", nil)
+	return err
+}
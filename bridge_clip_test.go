@@ -0,0 +1,188 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// requestRecorder records the method and path of the requests it passes on
+type requestRecorder struct {
+	handler  http.Handler
+	mutex    sync.Mutex
+	requests []string
+}
+
+func (r *requestRecorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		r.mutex.Lock()
+		r.requests = append(r.requests, req.Method+" "+req.URL.Path)
+		r.mutex.Unlock()
+	}
+	r.handler.ServeHTTP(w, req)
+}
+
+// startClipBridge serves a new fake bridge over plain HTTP and returns a
+// CLIP v2 client for it
+func startClipBridge(t *testing.T) (*clipClient, *fakeBridge, *requestRecorder) {
+	t.Helper()
+	fake := newFakeBridge()
+	fake.openAccess = true
+	recorder := &requestRecorder{handler: &mockBridgeServer{bridge: fake}}
+	server := httptest.NewServer(recorder)
+	t.Cleanup(server.Close)
+	return newClipClient(server.URL, "test"), fake, recorder
+}
+
+func TestClipClientGetLights(t *testing.T) {
+	client, fake, _ := startClipBridge(t)
+	lights, err := client.GetLights()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := fake.getLights("test")
+	if len(lights) != len(want) {
+		t.Fatalf("got %d lights, want %d", len(lights), len(want))
+	}
+	for i, light := range lights {
+		expected := want[i]
+		if light.ID != expected.ID || light.Name != expected.Name || light.Type != expected.Type || light.ModelID != expected.ModelID {
+			t.Errorf("light %d = %+v, want %+v", i, light, expected)
+		}
+		if light.State.On != expected.State.On || light.State.Bri != expected.State.Bri || light.State.Reachable != expected.State.Reachable {
+			t.Errorf("light %d state = %+v, want %+v", light.ID, light.State, expected.State)
+		}
+		if uuid := clipResourceID("light", strconv.Itoa(light.ID)); light.UUID != uuid {
+			t.Errorf("light %d UUID = %s, want %s", light.ID, light.UUID, uuid)
+		}
+	}
+}
+
+func TestClipClientRequests(t *testing.T) {
+	on := StateUpdate{On: boolPtr(true), Bri: uint8Ptr(254)}
+	tests := []struct {
+		name string
+		run  func(client *clipClient, fake *fakeBridge) error
+		want []string
+		code int
+	}{
+		{
+			name: "light by v1 ID",
+			run:  func(client *clipClient, fake *fakeBridge) error { return client.SetLightState(3, on) },
+			want: []string{"PUT /clip/v2/resource/light/" + clipResourceID("light", "3")},
+		},
+		{
+			name: "unknown light",
+			run:  func(client *clipClient, fake *fakeBridge) error { return client.SetLightState(42, on) },
+			code: exitNotFound,
+		},
+		{
+			name: "room",
+			run:  func(client *clipClient, fake *fakeBridge) error { return client.SetGroupAction("1", on) },
+			want: []string{"PUT /clip/v2/resource/grouped_light/" + clipResourceID("grouped_light", "1")},
+		},
+		{
+			name: "all lights",
+			run:  func(client *clipClient, fake *fakeBridge) error { return client.SetGroupAction(allLightsGroup, on) },
+			want: []string{"PUT /clip/v2/resource/grouped_light/" + clipResourceID("grouped_light", allLightsGroup)},
+		},
+		{
+			name: "light group without a grouped light",
+			run: func(client *clipClient, fake *fakeBridge) error {
+				id := fake.addGroup(BridgeGroup{Name: "Desk", Type: "LightGroup", Lights: []string{"3"}})
+				return client.SetGroupAction(id, on)
+			},
+			want: []string{"PUT /api/test/groups/4/action"},
+		},
+		{
+			name: "entertainment streaming stays on v1",
+			run:  func(client *clipClient, fake *fakeBridge) error { return client.SetStreamActive("3", true) },
+			want: []string{"PUT /api/test/groups/3"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, fake, recorder := startClipBridge(t)
+			checkExitCode(t, test.run(client, fake), test.code)
+			if !reflect.DeepEqual(recorder.requests, test.want) {
+				t.Errorf("requests = %q, want %q", recorder.requests, test.want)
+			}
+		})
+	}
+}
+
+func TestClipClientRecallScene(t *testing.T) {
+	tests := []struct {
+		scene string
+		group string
+		clip  bool // whether the scene exists in CLIP v2
+	}{
+		{scene: "Relax", group: "1", clip: true},
+		{scene: "Sunset", group: allLightsGroup},
+	}
+	for _, test := range tests {
+		t.Run(test.scene, func(t *testing.T) {
+			client, fake, recorder := startClipBridge(t)
+			groups, _ := fake.getGroups("test")
+			scenes, _ := fake.getScenes("test")
+			scene, err := findBridgeScene(scenes, bridgeSceneNames(scenes, groups), test.scene)
+			if err != nil {
+				t.Fatal(err)
+			}
+			id := scene.ID
+			scene, _ = fake.getScene("test", id)
+			if err := client.RecallScene(test.group, id); err != nil {
+				t.Fatal(err)
+			}
+
+			want := "PUT /api/test/groups/" + test.group + "/action"
+			if test.clip {
+				want = "PUT /clip/v2/resource/scene/" + clipResourceID("scene", id)
+			}
+			if !reflect.DeepEqual(recorder.requests, []string{want}) {
+				t.Errorf("requests = %q, want %q", recorder.requests, want)
+			}
+			for _, lightID := range scene.Lights {
+				if id, _ := strconv.Atoi(lightID); fakeLight(t, fake, id).State.On != *scene.LightStates[lightID].On {
+					t.Errorf("light %s is not as scene %s sets it", lightID, test.scene)
+				}
+			}
+		})
+	}
+}
+
+func TestClipIndexReloads(t *testing.T) {
+	client, fake, recorder := startClipBridge(t)
+	if _, err := client.GetLights(); err != nil {
+		t.Fatal(err)
+	}
+	fake.addLight("Garage", "Dimmable light", "LWB010")
+	if err := client.SetLightState(6, StateUpdate{On: boolPtr(true)}); err != nil {
+		t.Fatalf("light created after the index was built: %v", err)
+	}
+	if want := []string{"PUT /clip/v2/resource/light/" + clipResourceID("light", "6")}; !reflect.DeepEqual(recorder.requests, want) {
+		t.Errorf("requests = %q, want %q", recorder.requests, want)
+	}
+}
+
+func TestClipLightUpdateRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		update StateUpdate
+	}{
+		{name: "on and brightness", update: StateUpdate{On: boolPtr(true), Bri: uint8Ptr(127)}},
+		{name: "xy", update: StateUpdate{Xy: []float32{0.3, 0.4}}},
+		{name: "color temperature", update: StateUpdate{Ct: uint16Ptr(370), TransitionTime: uint16Ptr(10)}},
+		{name: "effect", update: StateUpdate{Effect: "colorloop", Alert: "select"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := newClipLightUpdate(test.update).toStateUpdate(); !reflect.DeepEqual(got, test.update) {
+				t.Errorf("round trip = %+v, want %+v", got, test.update)
+			}
+		})
+	}
+}
@@ -0,0 +1,787 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fakeBridge is an in-memory bridge holding lights, groups and the user
+// whitelist. It backs the "fake" bridge host and can be served over HTTP.
+type fakeBridge struct {
+	mutex       sync.Mutex
+	info        BridgeInfo
+	lights      map[int]*Light
+	groups      map[string]*BridgeGroup
+	scenes      map[string]*BridgeScene
+	users       map[string]*fakeUser
+	openAccess  bool // accept any non-empty username
+	linkButton  bool // whether user creation is currently allowed
+	nextGroupID int
+}
+
+// fakeUser is an application in the whitelist of the fake bridge
+type fakeUser struct {
+	clientKey string
+	name      string
+	created   time.Time
+	lastUsed  time.Time
+}
+
+var fakeBridgeOnce sync.Once
+var fakeBridgeInstance *fakeBridge
+
+// defaultFakeBridge returns the process-wide fake used for the "fake" host.
+// It accepts any username and always has its link button pressed so that
+// every command can run without pairing first.
+func defaultFakeBridge() *fakeBridge {
+	fakeBridgeOnce.Do(func() {
+		fakeBridgeInstance = newFakeBridge()
+		fakeBridgeInstance.openAccess = true
+		fakeBridgeInstance.linkButton = true
+	})
+	return fakeBridgeInstance
+}
+
+// newFakeBridge creates a fake bridge with a few sample lights and groups
+func newFakeBridge() *fakeBridge {
+	f := &fakeBridge{
+		info: BridgeInfo{
+			Name:       "Fake Hue Bridge",
+			BridgeID:   "001788FFFE000000",
+			ModelID:    "BSB002",
+			APIVersion: "1.65.0",
+			SwVersion:  "1965111030",
+		},
+		lights:      make(map[int]*Light),
+		groups:      make(map[string]*BridgeGroup),
+		scenes:      make(map[string]*BridgeScene),
+		users:       make(map[string]*fakeUser),
+		nextGroupID: 1,
+	}
+
+	f.addLight("Living Room Lamp", "Extended color light", "LCT015")
+	f.addLight("Kitchen Ceiling", "Extended color light", "LCA001")
+	f.addLight("Desk Lamp", "Extended color light", "LCT015")
+	f.addLight("Hallway", "Dimmable light", "LWB010")
+	f.addLight("Bedroom Ambiance", "Color temperature light", "LTW001")
+
+	f.addGroup(BridgeGroup{Name: "Living Room", Type: "Room", Class: "Living room", Lights: []string{"1", "3"}})
+	f.addGroup(BridgeGroup{Name: "Kitchen", Type: "Room", Class: "Kitchen", Lights: []string{"2"}})
+	f.addGroup(BridgeGroup{
+		Name:   "TV Area",
+		Type:   "Entertainment",
+		Class:  "TV",
+		Lights: []string{"1", "3"},
+		Stream: &StreamConfig{ProxyMode: "auto"},
+	})
+
+	f.addScene(BridgeScene{
+		Name:   "Relax",
+		Type:   "GroupScene",
+		Group:  "1",
+		Lights: []string{"1", "3"},
+		LightStates: map[string]StateUpdate{
+			"1": {On: boolPtr(true), Bri: uint8Ptr(144), Ct: uint16Ptr(447)},
+			"3": {On: boolPtr(true), Bri: uint8Ptr(144), Ct: uint16Ptr(447)},
+		},
+	})
+	f.addScene(BridgeScene{
+		Name:   "Sunset",
+		Type:   "LightScene",
+		Lights: []string{"1", "2"},
+		LightStates: map[string]StateUpdate{
+			"1": {On: boolPtr(true), Bri: uint8Ptr(200), Xy: []float32{0.5916, 0.3824}},
+			"2": {On: boolPtr(false)},
+		},
+	})
+
+	// Apps that paired in the past, to list and prune
+	now := time.Now().UTC()
+	f.addUser("Hue#iPhone", now.AddDate(-2, 0, 0), now.AddDate(0, 0, -1))
+	f.addUser("hue_cli#device", now.AddDate(-1, 0, 0), now.AddDate(0, -8, 0))
+	f.addUser("hue_cli#device", now.AddDate(0, -6, 0), now.AddDate(0, -5, 0))
+	return f
+}
+
+func (f *fakeBridge) addUser(name string, created, lastUsed time.Time) {
+	f.users[randomHex(20)] = &fakeUser{clientKey: strings.ToUpper(randomHex(16)), name: name, created: created, lastUsed: lastUsed}
+}
+
+func (f *fakeBridge) addLight(name, lightType, modelID string) {
+	id := len(f.lights) + 1
+	light := &Light{
+		ID:      id,
+		Name:    name,
+		Type:    lightType,
+		ModelID: modelID,
+		State: LightState{
+			On:        false,
+			Bri:       254,
+			Reachable: true,
+		},
+	}
+	if lightSupportsCt(lightType) {
+		light.State.Ct = 366
+		light.State.ColorMode = "ct"
+		light.Capabilities = &LightCapabilities{CtMin: minMired, CtMax: maxMired}
+	}
+	if lightSupportsColor(lightType) {
+		light.State.Xy = []float32{0.4573, 0.41}
+		light.State.ColorMode = "xy"
+		if light.Capabilities == nil {
+			light.Capabilities = &LightCapabilities{}
+		}
+		gamut := lightGamut(*light)
+		light.Capabilities.GamutType = modelGamutTypes[modelID]
+		light.Capabilities.Gamut = [][2]float64{
+			{gamut.Red.X, gamut.Red.Y},
+			{gamut.Green.X, gamut.Green.Y},
+			{gamut.Blue.X, gamut.Blue.Y},
+		}
+	}
+	f.lights[id] = light
+}
+
+func (f *fakeBridge) addGroup(group BridgeGroup) string {
+	id := strconv.Itoa(f.nextGroupID)
+	f.nextGroupID++
+	group.ID = id
+	f.groups[id] = &group
+	return id
+}
+
+func (f *fakeBridge) addScene(scene BridgeScene) string {
+	id := randomHex(8)
+	scene.ID = id
+	if scene.Type == "" {
+		scene.Type = "LightScene"
+	}
+	f.scenes[id] = &scene
+	return id
+}
+
+// authorize reports whether username may use the API
+func (f *fakeBridge) authorize(username string) error {
+	if username != "" && f.openAccess {
+		return nil
+	}
+	if user, ok := f.users[username]; ok && username != "" {
+		user.lastUsed = time.Now().UTC()
+		return nil
+	}
+	return &BridgeError{Type: bridgeErrUnauthorized, Address: "/", Description: "unauthorized user"}
+}
+
+// setLinkButton simulates pressing (or releasing) the bridge link button
+func (f *fakeBridge) setLinkButton(pressed bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.linkButton = pressed
+}
+
+func (f *fakeBridge) getLights(username string) ([]Light, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err := f.authorize(username); err != nil {
+		return nil, err
+	}
+
+	lights := make([]Light, 0, len(f.lights))
+	for _, light := range f.lights {
+		copied := *light
+		copied.State.Xy = append([]float32(nil), light.State.Xy...)
+		lights = append(lights, copied)
+	}
+	sort.Slice(lights, func(i, j int) bool { return lights[i].ID < lights[j].ID })
+	return lights, nil
+}
+
+func (f *fakeBridge) setLightState(username string, id int, update StateUpdate) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err := f.authorize(username); err != nil {
+		return err
+	}
+
+	light, ok := f.lights[id]
+	if !ok {
+		return &BridgeError{
+			Type:        bridgeErrResourceNotFound,
+			Address:     fmt.Sprintf("/lights/%d", id),
+			Description: fmt.Sprintf("resource, /lights/%d, not available", id),
+		}
+	}
+	return applyLightState(light, update)
+}
+
+// setGroupAction applies update to every light of a group. Group "0"
+// contains all lights. Like a real bridge, attributes a light does not
+// support are skipped rather than failing the request.
+func (f *fakeBridge) setGroupAction(username, groupID string, update StateUpdate) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err := f.authorize(username); err != nil {
+		return err
+	}
+
+	var ids []int
+	if groupID == allLightsGroup {
+		for id := range f.lights {
+			ids = append(ids, id)
+		}
+	} else {
+		group, ok := f.groups[groupID]
+		if !ok {
+			return &BridgeError{
+				Type:        bridgeErrResourceNotFound,
+				Address:     "/groups/" + groupID,
+				Description: fmt.Sprintf("resource, /groups/%s, not available", groupID),
+			}
+		}
+		for _, lightID := range group.Lights {
+			id, _ := strconv.Atoi(lightID)
+			ids = append(ids, id)
+		}
+	}
+
+	for _, id := range ids {
+		light, ok := f.lights[id]
+		if !ok {
+			continue
+		}
+		lightUpdate := update
+		if !lightSupportsColor(light.Type) {
+			lightUpdate.Xy, lightUpdate.Hue, lightUpdate.Sat, lightUpdate.Effect = nil, nil, nil, ""
+		}
+		if !lightSupportsCt(light.Type) {
+			lightUpdate.Ct = nil
+		}
+		applyLightState(light, lightUpdate)
+	}
+	return nil
+}
+
+// applyLightState changes the state of light, validating the update like a
+// real bridge. The caller holds the bridge mutex.
+func applyLightState(light *Light, update StateUpdate) error {
+	id := light.ID
+
+	// A real bridge refuses attribute changes on a light that is off
+	// unless the same request turns it on
+	turningOn := update.On != nil && *update.On
+	changesColor := update.Bri != nil || update.Hue != nil || update.Sat != nil || update.Xy != nil || update.Ct != nil || update.Effect != ""
+	if changesColor && !light.State.On && !turningOn {
+		return &BridgeError{
+			Type:        201,
+			Address:     fmt.Sprintf("/lights/%d/state", id),
+			Description: "parameter, not modifiable, device is set to off",
+		}
+	}
+
+	// Lights only accept the attributes their type supports
+	if (update.Xy != nil || update.Hue != nil || update.Sat != nil || update.Effect != "") && !lightSupportsColor(light.Type) {
+		return &BridgeError{
+			Type:        6,
+			Address:     fmt.Sprintf("/lights/%d/state/xy", id),
+			Description: "parameter, xy, not available",
+		}
+	}
+	if update.Ct != nil && !lightSupportsCt(light.Type) {
+		return &BridgeError{
+			Type:        6,
+			Address:     fmt.Sprintf("/lights/%d/state/ct", id),
+			Description: "parameter, ct, not available",
+		}
+	}
+
+	if update.On != nil {
+		light.State.On = *update.On
+	}
+	if update.Bri != nil {
+		light.State.Bri = *update.Bri
+	}
+	if update.Hue != nil {
+		light.State.Hue = *update.Hue
+		light.State.ColorMode = "hs"
+	}
+	if update.Sat != nil {
+		light.State.Sat = *update.Sat
+		light.State.ColorMode = "hs"
+	}
+	if len(update.Xy) == 2 {
+		// Like a real bridge, store the closest color the light can show
+		p := lightGamut(*light).clamp(xyPoint{float64(update.Xy[0]), float64(update.Xy[1])})
+		light.State.Xy = []float32{float32(p.X), float32(p.Y)}
+		light.State.ColorMode = "xy"
+	}
+	if update.Ct != nil {
+		light.State.Ct = *update.Ct
+		light.State.ColorMode = "ct"
+	}
+	if update.Effect != "" {
+		light.State.Effect = update.Effect
+	}
+	if update.Alert != "" {
+		// A real bridge resets "select" once the breath is done; the fake
+		// keeps the last alert so it can be inspected
+		light.State.Alert = update.Alert
+	}
+	return nil
+}
+
+func (f *fakeBridge) getGroups(username string) ([]BridgeGroup, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err := f.authorize(username); err != nil {
+		return nil, err
+	}
+
+	groups := make([]BridgeGroup, 0, len(f.groups))
+	for _, group := range f.groups {
+		copied := *group
+		copied.Lights = append([]string(nil), group.Lights...)
+		if group.Stream != nil {
+			stream := *group.Stream
+			copied.Stream = &stream
+		}
+		groups = append(groups, copied)
+	}
+	sortGroupsByID(groups)
+	return groups, nil
+}
+
+func (f *fakeBridge) createGroup(username string, group BridgeGroup) (string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err := f.authorize(username); err != nil {
+		return "", err
+	}
+
+	for _, lightID := range group.Lights {
+		id, err := strconv.Atoi(lightID)
+		if _, ok := f.lights[id]; err != nil || !ok {
+			return "", &BridgeError{
+				Type:        bridgeErrResourceNotFound,
+				Address:     "/groups/lights",
+				Description: fmt.Sprintf("resource, /lights/%s, not available", lightID),
+			}
+		}
+	}
+
+	if group.Type == "" {
+		group.Type = "LightGroup"
+	}
+	if group.Type == "Entertainment" && group.Stream == nil {
+		group.Stream = &StreamConfig{ProxyMode: "auto"}
+	}
+	group.Lights = append([]string(nil), group.Lights...)
+	return f.addGroup(group), nil
+}
+
+func (f *fakeBridge) updateGroup(username, id string, update BridgeGroup) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err := f.authorize(username); err != nil {
+		return err
+	}
+
+	group, ok := f.groups[id]
+	if !ok {
+		return &BridgeError{
+			Type:        bridgeErrResourceNotFound,
+			Address:     "/groups/" + id,
+			Description: fmt.Sprintf("resource, /groups/%s, not available", id),
+		}
+	}
+	for _, lightID := range update.Lights {
+		lightNum, err := strconv.Atoi(lightID)
+		if _, ok := f.lights[lightNum]; err != nil || !ok {
+			return &BridgeError{
+				Type:        bridgeErrResourceNotFound,
+				Address:     "/groups/" + id + "/lights",
+				Description: fmt.Sprintf("resource, /lights/%s, not available", lightID),
+			}
+		}
+	}
+
+	if update.Name != "" {
+		group.Name = update.Name
+	}
+	if update.Lights != nil {
+		group.Lights = append([]string(nil), update.Lights...)
+	}
+	return nil
+}
+
+func (f *fakeBridge) deleteGroup(username, id string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err := f.authorize(username); err != nil {
+		return err
+	}
+
+	if _, ok := f.groups[id]; !ok {
+		return &BridgeError{
+			Type:        bridgeErrResourceNotFound,
+			Address:     "/groups/" + id,
+			Description: fmt.Sprintf("resource, /groups/%s, not available", id),
+		}
+	}
+	delete(f.groups, id)
+	return nil
+}
+
+func (f *fakeBridge) setStreamActive(username, groupID string, active bool) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err := f.authorize(username); err != nil {
+		return err
+	}
+
+	group, ok := f.groups[groupID]
+	if !ok {
+		return &BridgeError{
+			Type:        bridgeErrResourceNotFound,
+			Address:     "/groups/" + groupID,
+			Description: fmt.Sprintf("resource, /groups/%s, not available", groupID),
+		}
+	}
+	if group.Type != "Entertainment" || group.Stream == nil {
+		return &BridgeError{
+			Type:        6,
+			Address:     "/groups/" + groupID + "/stream",
+			Description: "parameter, stream, not available",
+		}
+	}
+
+	group.Stream.Active = active
+	if active {
+		group.Stream.Owner = username
+	} else {
+		group.Stream.Owner = ""
+	}
+	return nil
+}
+
+func (f *fakeBridge) getScenes(username string) ([]BridgeScene, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err := f.authorize(username); err != nil {
+		return nil, err
+	}
+
+	scenes := make([]BridgeScene, 0, len(f.scenes))
+	for _, scene := range f.scenes {
+		copied := *scene
+		copied.Lights = append([]string(nil), scene.Lights...)
+		copied.LightStates = nil // like the bridge, only single scenes include light states
+		scenes = append(scenes, copied)
+	}
+	sortScenesByName(scenes)
+	return scenes, nil
+}
+
+func (f *fakeBridge) getScene(username, id string) (*BridgeScene, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err := f.authorize(username); err != nil {
+		return nil, err
+	}
+
+	scene, ok := f.scenes[id]
+	if !ok {
+		return nil, sceneNotAvailable(id)
+	}
+	copied := *scene
+	copied.Lights = append([]string(nil), scene.Lights...)
+	copied.LightStates = make(map[string]StateUpdate, len(scene.LightStates))
+	for lightID, state := range scene.LightStates {
+		copied.LightStates[lightID] = state
+	}
+	return &copied, nil
+}
+
+func (f *fakeBridge) createScene(username string, scene BridgeScene) (string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err := f.authorize(username); err != nil {
+		return "", err
+	}
+	if err := f.checkSceneLights(scene); err != nil {
+		return "", err
+	}
+	return f.addScene(scene), nil
+}
+
+func (f *fakeBridge) updateScene(username, id string, update BridgeScene) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err := f.authorize(username); err != nil {
+		return err
+	}
+
+	scene, ok := f.scenes[id]
+	if !ok {
+		return sceneNotAvailable(id)
+	}
+	if err := f.checkSceneLights(update); err != nil {
+		return err
+	}
+
+	if update.Name != "" {
+		scene.Name = update.Name
+	}
+	if update.Lights != nil {
+		scene.Lights = append([]string(nil), update.Lights...)
+	}
+	if update.LightStates != nil {
+		scene.LightStates = update.LightStates
+	}
+	return nil
+}
+
+// recallScene applies the stored light states of a scene
+func (f *fakeBridge) recallScene(username, groupID, sceneID string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err := f.authorize(username); err != nil {
+		return err
+	}
+
+	scene, ok := f.scenes[sceneID]
+	if !ok {
+		return sceneNotAvailable(sceneID)
+	}
+	if _, ok := f.groups[groupID]; !ok && groupID != allLightsGroup {
+		return &BridgeError{
+			Type:        bridgeErrResourceNotFound,
+			Address:     "/groups/" + groupID,
+			Description: fmt.Sprintf("resource, /groups/%s, not available", groupID),
+		}
+	}
+
+	for lightID, state := range scene.LightStates {
+		id, _ := strconv.Atoi(lightID)
+		if light, ok := f.lights[id]; ok {
+			applyLightState(light, state)
+		}
+	}
+	return nil
+}
+
+// checkSceneLights verifies that every light of a scene exists
+func (f *fakeBridge) checkSceneLights(scene BridgeScene) error {
+	for _, lightID := range scene.Lights {
+		id, err := strconv.Atoi(lightID)
+		if _, ok := f.lights[id]; err != nil || !ok {
+			return &BridgeError{
+				Type:        bridgeErrResourceNotFound,
+				Address:     "/scenes/lights",
+				Description: fmt.Sprintf("resource, /lights/%s, not available", lightID),
+			}
+		}
+	}
+	return nil
+}
+
+func sceneNotAvailable(id string) error {
+	return &BridgeError{
+		Type:        bridgeErrResourceNotFound,
+		Address:     "/scenes/" + id,
+		Description: fmt.Sprintf("resource, /scenes/%s, not available", id),
+	}
+}
+
+func (f *fakeBridge) createUser(deviceType string) (string, string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if !f.linkButton {
+		return "", "", &BridgeError{Type: bridgeErrLinkButtonPressed, Address: "", Description: "link button not pressed"}
+	}
+	if deviceType == "" {
+		return "", "", &BridgeError{Type: 5, Address: "/", Description: "invalid/missing parameters in body"}
+	}
+
+	username := randomHex(20)
+	clientKey := strings.ToUpper(randomHex(16))
+	now := time.Now().UTC()
+	f.users[username] = &fakeUser{clientKey: clientKey, name: deviceType, created: now, lastUsed: now}
+	return username, clientKey, nil
+}
+
+func (f *fakeBridge) deleteUser(username, target string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err := f.authorize(username); err != nil {
+		return err
+	}
+	if _, ok := f.users[target]; !ok {
+		return &BridgeError{
+			Type:        bridgeErrResourceNotFound,
+			Address:     "/config/whitelist/" + target,
+			Description: fmt.Sprintf("resource, /config/whitelist/%s, not available", target),
+		}
+	}
+	delete(f.users, target)
+	return nil
+}
+
+func (f *fakeBridge) getUsers(username string) ([]BridgeUser, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err := f.authorize(username); err != nil {
+		return nil, err
+	}
+	users := make([]BridgeUser, 0, len(f.users))
+	for id, user := range f.users {
+		users = append(users, BridgeUser{ID: id, Name: user.name, Created: user.created, LastUsed: user.lastUsed})
+	}
+	sortUsersByName(users)
+	return users, nil
+}
+
+// config returns the v1 config resource, including the whitelist for
+// authorized users
+func (f *fakeBridge) config(username string) map[string]interface{} {
+	f.mutex.Lock()
+	info := f.info
+	authorized := f.authorize(username) == nil
+	f.mutex.Unlock()
+
+	config := map[string]interface{}{
+		"name":       info.Name,
+		"bridgeid":   info.BridgeID,
+		"modelid":    info.ModelID,
+		"apiversion": info.APIVersion,
+		"swversion":  info.SwVersion,
+	}
+	if authorized {
+		users, _ := f.getUsers(username)
+		whitelist := make(map[string]v1WhitelistEntry, len(users))
+		for _, user := range users {
+			whitelist[user.ID] = newV1WhitelistEntry(user)
+		}
+		config["whitelist"] = whitelist
+	}
+	return config
+}
+
+// clientKey returns the DTLS pre-shared key of a whitelisted user
+func (f *fakeBridge) clientKey(username string) (string, bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	user, ok := f.users[username]
+	if !ok {
+		return "", false
+	}
+	return user.clientKey, true
+}
+
+func randomHex(n int) string {
+	buf := make([]byte, n)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// fakeClient is a BridgeClient bound to a fakeBridge and a username
+type fakeClient struct {
+	bridge   *fakeBridge
+	username string
+}
+
+func newFakeClient(bridge *fakeBridge, username string) *fakeClient {
+	return &fakeClient{bridge: bridge, username: username}
+}
+
+func (c *fakeClient) GetLights() ([]Light, error) {
+	return c.bridge.getLights(c.username)
+}
+
+func (c *fakeClient) SetLightState(id int, update StateUpdate) error {
+	return c.bridge.setLightState(c.username, id, update)
+}
+
+func (c *fakeClient) SetGroupAction(groupID string, update StateUpdate) error {
+	return c.bridge.setGroupAction(c.username, groupID, update)
+}
+
+func (c *fakeClient) GetGroups() ([]BridgeGroup, error) {
+	return c.bridge.getGroups(c.username)
+}
+
+func (c *fakeClient) CreateGroup(group BridgeGroup) (string, error) {
+	return c.bridge.createGroup(c.username, group)
+}
+
+func (c *fakeClient) UpdateGroup(id string, group BridgeGroup) error {
+	return c.bridge.updateGroup(c.username, id, group)
+}
+
+func (c *fakeClient) DeleteGroup(id string) error {
+	return c.bridge.deleteGroup(c.username, id)
+}
+
+func (c *fakeClient) SetStreamActive(groupID string, active bool) error {
+	return c.bridge.setStreamActive(c.username, groupID, active)
+}
+
+func (c *fakeClient) GetScenes() ([]BridgeScene, error) {
+	return c.bridge.getScenes(c.username)
+}
+
+func (c *fakeClient) GetScene(id string) (*BridgeScene, error) {
+	return c.bridge.getScene(c.username, id)
+}
+
+func (c *fakeClient) CreateScene(scene BridgeScene) (string, error) {
+	return c.bridge.createScene(c.username, scene)
+}
+
+func (c *fakeClient) UpdateScene(id string, scene BridgeScene) error {
+	return c.bridge.updateScene(c.username, id, scene)
+}
+
+func (c *fakeClient) RecallScene(groupID, sceneID string) error {
+	return c.bridge.recallScene(c.username, groupID, sceneID)
+}
+
+func (c *fakeClient) GetConfig() (*BridgeInfo, error) {
+	c.bridge.mutex.Lock()
+	defer c.bridge.mutex.Unlock()
+	info := c.bridge.info
+	return &info, nil
+}
+
+func (c *fakeClient) CreateUser(deviceType string) (string, string, error) {
+	return c.bridge.createUser(deviceType)
+}
+
+func (c *fakeClient) GetUsers() ([]BridgeUser, error) {
+	return c.bridge.getUsers(c.username)
+}
+
+func (c *fakeClient) DeleteUser(username string) error {
+	return c.bridge.deleteUser(c.username, username)
+}
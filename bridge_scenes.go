@@ -0,0 +1,347 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// bridgeSceneNames returns a unique name for every bridge scene. Apps
+// create scenes such as "Relax" once per room, so names used more than
+// once get the room name (or the scene ID) appended.
+func bridgeSceneNames(scenes []BridgeScene, groups []BridgeGroup) map[string]string {
+	counts := make(map[string]int)
+	for _, scene := range scenes {
+		counts[scene.Name]++
+	}
+
+	groupNames := make(map[string]string)
+	for _, group := range groups {
+		groupNames[group.ID] = group.Name
+	}
+
+	names := make(map[string]string, len(scenes))
+	used := make(map[string]bool)
+	for _, scene := range scenes {
+		name := scene.Name
+		if counts[scene.Name] > 1 {
+			if groupName, ok := groupNames[scene.Group]; ok && scene.Type == "GroupScene" {
+				name = fmt.Sprintf("%s (%s)", scene.Name, groupName)
+			}
+			if name == scene.Name || used[name] {
+				name = fmt.Sprintf("%s (%s)", scene.Name, scene.ID)
+			}
+		}
+		used[name] = true
+		names[scene.ID] = name
+	}
+	return names
+}
+
+// findBridgeScene returns the bridge scene with the given ID or name. A name
+// shared by several scenes must be written the way 'hue scene recall' lists it.
+func findBridgeScene(scenes []BridgeScene, names map[string]string, nameOrID string) (*BridgeScene, error) {
+	var matches []*BridgeScene
+	for i, scene := range scenes {
+		if scene.ID == nameOrID || names[scene.ID] == nameOrID {
+			return &scenes[i], nil
+		}
+		if strings.EqualFold(scene.Name, nameOrID) || strings.EqualFold(names[scene.ID], nameOrID) {
+			matches = append(matches, &scenes[i])
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, notFoundError("scene '%s' not found on the bridge", nameOrID)
+	case 1:
+		return matches[0], nil
+	}
+	candidates := make([]string, len(matches))
+	for i, scene := range matches {
+		candidates[i] = names[scene.ID]
+	}
+	return nil, fmt.Errorf("scene name '%s' is ambiguous, use one of: %s", nameOrID, strings.Join(candidates, ", "))
+}
+
+// loadBridgeScenes fetches the bridge scenes together with their unique names
+func loadBridgeScenes() ([]BridgeScene, map[string]string, error) {
+	scenes, err := bridge.GetScenes()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get bridge scenes: %w", err)
+	}
+	groups, err := bridge.GetGroups()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get groups: %w", err)
+	}
+	return scenes, bridgeSceneNames(scenes, groups), nil
+}
+
+// sceneLightStates folds the commands of a local scene into the final state
+// of every light, the way running the scene would leave them
+func sceneLightStates(scene *Scene) (map[string]StateUpdate, error) {
+	states := make(map[string]StateUpdate)
+	for _, command := range sceneCommands(scene) {
+		state, color, err := sceneCommandState(command, nil)
+		if err != nil {
+			return nil, fmt.Errorf("'%s': %w", command.Light, err)
+		}
+		lights, _, err := sceneCommandLights(command, color)
+		if err != nil {
+			return nil, err
+		}
+		for _, light := range lights {
+			id := strconv.Itoa(light.ID)
+			states[id] = mergeStateUpdates(states[id], sceneLightState(command, state, color, light).update)
+		}
+	}
+	return states, nil
+}
+
+// stateCommands turns the state of one light into scene commands
+func stateCommands(light string, state StateUpdate) []SceneCommand {
+	transition := ""
+	if state.TransitionTime != nil {
+		transition = (time.Duration(*state.TransitionTime) * 100 * time.Millisecond).String()
+	}
+	command := func(commandType string, values ...string) []SceneCommand {
+		if values == nil {
+			values = []string{}
+		}
+		return []SceneCommand{{Type: commandType, Light: light, Values: values, Transition: transition}}
+	}
+
+	var brightness []string
+	if state.Bri != nil {
+		brightness = []string{strconv.Itoa(int(*state.Bri))}
+	}
+
+	switch {
+	case state.On != nil && !*state.On:
+		return command("off")
+	case len(state.Xy) == 2:
+		return command("color", append([]string{fmt.Sprintf("xy:%.4f,%.4f", state.Xy[0], state.Xy[1])}, brightness...)...)
+	case state.Ct != nil:
+		return command("temp", append([]string{fmt.Sprintf("%dmired", *state.Ct)}, brightness...)...)
+	case state.Bri != nil:
+		return command("brightness", brightness...)
+	case state.On != nil:
+		return command("on")
+	}
+	return nil
+}
+
+var scenePushCmd = &cobra.Command{
+	Use:   "push [scene-name]",
+	Short: "Store a local scene on the bridge",
+	Long: `Store a local scene on the bridge as a bridge scene holding the final state of every light it
+changes. The scene can then be recalled with a single request, by 'hue scene recall' or from
+the Hue app, switches and schedules. Pushing again updates the bridge scene of the same name.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sceneName := args[0]
+
+		config, err := loadSceneConfig()
+		if os.IsNotExist(err) {
+			return notFoundError("scene '%s' not found", sceneName)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load scenes: %w", err)
+		}
+		scene := findScene(config, sceneName)
+		if scene == nil {
+			return notFoundError("scene '%s' not found", sceneName)
+		}
+		if err := checkStoredScene(config, scene); err != nil {
+			return err
+		}
+
+		states, err := sceneLightStates(scene)
+		if err != nil {
+			return err
+		}
+		if len(states) == 0 {
+			return fmt.Errorf("scene '%s' does not change any lights", sceneName)
+		}
+
+		lights := make([]string, 0, len(states))
+		for id := range states {
+			lights = append(lights, id)
+		}
+		sort.Slice(lights, func(i, j int) bool { return groupIDLess(lights[i], lights[j]) })
+		bridgeScene := BridgeScene{Name: scene.Name, Type: "LightScene", Lights: lights, LightStates: states}
+
+		scenes, err := bridge.GetScenes()
+		if err != nil {
+			return fmt.Errorf("failed to get bridge scenes: %w", err)
+		}
+		for _, existing := range scenes {
+			if existing.Name == scene.Name && existing.Type == "LightScene" {
+				if err := bridge.UpdateScene(existing.ID, bridgeScene); err != nil {
+					return fmt.Errorf("failed to update bridge scene: %w", err)
+				}
+				fmt.Printf("Bridge scene %s updated with %d lights from scene '%s'\n", existing.ID, len(lights), scene.Name)
+				return nil
+			}
+		}
+
+		id, err := bridge.CreateScene(bridgeScene)
+		if err != nil {
+			return fmt.Errorf("failed to create bridge scene: %w", err)
+		}
+		fmt.Printf("Scene '%s' pushed to bridge scene %s with %d lights\n", scene.Name, id, len(lights))
+		return nil
+	},
+}
+
+var scenePullCmd = &cobra.Command{
+	Use:   "pull [bridge-scene]",
+	Short: "Import bridge scenes as local scenes",
+	Long: `Import scenes stored on the bridge, such as those created in the Hue app, as local scenes.
+Without a name every bridge scene is imported. Local scenes with the same name are kept
+unless --force is given.
+
+Examples:
+  hue scene pull
+  hue scene pull "Relax (Living Room)"
+  hue scene pull Sunset --force`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		force, _ := cmd.Flags().GetBool("force")
+
+		scenes, names, err := loadBridgeScenes()
+		if err != nil {
+			return err
+		}
+		if len(args) == 1 {
+			scene, err := findBridgeScene(scenes, names, args[0])
+			if err != nil {
+				return err
+			}
+			scenes = []BridgeScene{*scene}
+		}
+
+		allLights, err := bridge.GetLights()
+		if err != nil {
+			return fmt.Errorf("failed to get lights: %w", err)
+		}
+		lightNames := make(map[string]string)
+		for _, light := range allLights {
+			lightNames[strconv.Itoa(light.ID)] = light.Name
+		}
+
+		unlock, err := lockConfig()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		config, err := loadSceneConfig()
+		if os.IsNotExist(err) {
+			// Create new config if file doesn't exist
+			config = &SceneConfig{Scenes: []Scene{}}
+		} else if err != nil {
+			return err
+		}
+
+		pulled := 0
+		for _, summary := range scenes {
+			name := names[summary.ID]
+			existing := findScene(config, name)
+			if existing != nil && !force {
+				fmt.Printf("Skipped '%s': a local scene with that name exists (use --force to replace it)\n", name)
+				continue
+			}
+
+			// The scene list leaves out light states
+			scene, err := bridge.GetScene(summary.ID)
+			if err != nil {
+				return fmt.Errorf("failed to get bridge scene '%s': %w", name, err)
+			}
+
+			ids := make([]string, 0, len(scene.LightStates))
+			for id := range scene.LightStates {
+				ids = append(ids, id)
+			}
+			sort.Slice(ids, func(i, j int) bool { return groupIDLess(ids[i], ids[j]) })
+
+			local := Scene{Name: name, Commands: []SceneCommand{}}
+			for _, id := range ids {
+				light := id
+				if lightName, ok := lightNames[id]; ok {
+					light = lightName
+				}
+				local.Commands = append(local.Commands, stateCommands(light, scene.LightStates[id])...)
+			}
+
+			if existing != nil {
+				*existing = local
+			} else {
+				config.Scenes = append(config.Scenes, local)
+			}
+			fmt.Printf("Pulled scene '%s' with %d commands\n", name, len(local.Commands))
+			pulled++
+		}
+
+		if pulled == 0 {
+			return nil
+		}
+		return saveSceneConfig(*config)
+	},
+}
+
+var sceneRecallCmd = &cobra.Command{
+	Use:   "recall [bridge-scene]",
+	Short: "Activate a scene stored on the bridge",
+	Long: `Activate a bridge scene by name or ID in a single request. Without a name the bridge scenes
+are listed. Scene names used in several rooms are listed with the room name appended.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		scenes, names, err := loadBridgeScenes()
+		if err != nil {
+			return err
+		}
+
+		if len(args) == 0 {
+			doc := bridgeScenesDocument{Scenes: []bridgeSceneDocument{}}
+			for _, scene := range scenes {
+				doc.Scenes = append(doc.Scenes, bridgeSceneDocument{ID: scene.ID, Name: names[scene.ID], Type: scene.Type, Group: scene.Group, Lights: scene.Lights})
+			}
+			return printOutput(doc, func(w io.Writer) {
+				if len(doc.Scenes) == 0 {
+					fmt.Fprintln(w, "No bridge scenes found")
+					return
+				}
+				fmt.Fprintln(w, "ID\tName\tType\tGroup\tLights")
+				for _, scene := range doc.Scenes {
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", scene.ID, scene.Name, scene.Type, scene.Group, strings.Join(scene.Lights, ","))
+				}
+			})
+		}
+
+		scene, err := findBridgeScene(scenes, names, args[0])
+		if err != nil {
+			return err
+		}
+
+		groupID := allLightsGroup
+		if scene.Type == "GroupScene" && scene.Group != "" {
+			groupID = scene.Group
+		}
+		if err := bridge.RecallScene(groupID, scene.ID); err != nil {
+			return fmt.Errorf("failed to recall scene '%s': %w", names[scene.ID], err)
+		}
+		fmt.Printf("Bridge scene '%s' recalled\n", names[scene.ID])
+		return nil
+	},
+}
+
+func init() {
+	scenePullCmd.Flags().BoolP("force", "f", false, "Replace local scenes with the same name")
+}
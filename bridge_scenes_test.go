@@ -0,0 +1,128 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBridgeSceneNames(t *testing.T) {
+	groups := []BridgeGroup{{ID: "1", Name: "Living Room"}, {ID: "2", Name: "Kitchen"}}
+	scenes := []BridgeScene{
+		{ID: "a", Name: "Relax", Type: "GroupScene", Group: "1"},
+		{ID: "b", Name: "Relax", Type: "GroupScene", Group: "2"},
+		{ID: "c", Name: "Relax", Type: "LightScene"},
+		{ID: "d", Name: "Sunset", Type: "LightScene"},
+		{ID: "e", Name: "Read", Type: "GroupScene", Group: "1"},
+		{ID: "f", Name: "Read", Type: "GroupScene", Group: "1"},
+	}
+	want := map[string]string{
+		"a": "Relax (Living Room)",
+		"b": "Relax (Kitchen)",
+		"c": "Relax (c)",
+		"d": "Sunset",
+		"e": "Read (Living Room)",
+		"f": "Read (f)",
+	}
+	if got := bridgeSceneNames(scenes, groups); !reflect.DeepEqual(got, want) {
+		t.Errorf("bridgeSceneNames = %v, want %v", got, want)
+	}
+}
+
+func TestFindBridgeScene(t *testing.T) {
+	scenes := []BridgeScene{{ID: "a", Name: "Relax"}, {ID: "b", Name: "Relax"}, {ID: "c", Name: "Sunset"}}
+	names := map[string]string{"a": "Relax (Living Room)", "b": "Relax (Kitchen)", "c": "Sunset"}
+	tests := []struct {
+		nameOrID string
+		want     string
+		code     int
+	}{
+		{nameOrID: "c", want: "c"},
+		{nameOrID: "sunset", want: "c"},
+		{nameOrID: "Relax (Kitchen)", want: "b"},
+		{nameOrID: "relax (living room)", want: "a"},
+		{nameOrID: "Relax", code: exitError},
+		{nameOrID: "Party", code: exitNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.nameOrID, func(t *testing.T) {
+			scene, err := findBridgeScene(scenes, names, test.nameOrID)
+			checkExitCode(t, err, test.code)
+			if scene != nil && scene.ID != test.want {
+				t.Errorf("scene = %s, want %s", scene.ID, test.want)
+			}
+		})
+	}
+}
+
+func TestScenePushAndPull(t *testing.T) {
+	fake := useFakeBridge(t)
+	local := Scene{Name: "evening", Commands: []SceneCommand{
+		{Type: "on", Light: "Desk Lamp", Values: []string{}},
+		{Type: "off", Light: "Hallway", Values: []string{}},
+		{Type: "temp", Light: "Bedroom Ambiance", Values: []string{"370mired", "100"}},
+	}}
+	if err := saveSceneConfig(SceneConfig{Scenes: []Scene{local}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := scenePushCmd.RunE(scenePushCmd, []string{"evening"}); err != nil {
+		t.Fatalf("push: %v", err)
+	}
+	scenes, err := fake.getScenes("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pushed *BridgeScene
+	for _, scene := range scenes {
+		if scene.Name == "evening" {
+			pushed, _ = fake.getScene("test", scene.ID)
+		}
+	}
+	if pushed == nil || pushed.Type != "LightScene" || !reflect.DeepEqual(pushed.Lights, []string{"3", "4", "5"}) {
+		t.Fatalf("bridge scene = %+v, want a light scene of lights 3, 4 and 5", pushed)
+	}
+
+	// Pulling the pushed scene back into an empty scene file restores it
+	if err := saveSceneConfig(SceneConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := scenePullCmd.RunE(scenePullCmd, []string{"evening"}); err != nil {
+		t.Fatalf("pull: %v", err)
+	}
+	config, err := loadSceneConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Scenes) != 1 || !reflect.DeepEqual(config.Scenes[0], local) {
+		t.Errorf("pulled scenes = %+v, want %+v", config.Scenes, local)
+	}
+}
+
+func TestSceneRecallCmd(t *testing.T) {
+	tests := []struct {
+		name   string
+		scene  string
+		wantOn []int
+		code   int
+	}{
+		{name: "group scene", scene: "Relax", wantOn: []int{1, 3}},
+		{name: "light scene", scene: "sunset", wantOn: []int{1}},
+		{name: "unknown scene", scene: "Party", code: exitNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := useFakeBridge(t)
+			checkExitCode(t, sceneRecallCmd.RunE(sceneRecallCmd, []string{test.scene}), test.code)
+
+			on := map[int]bool{}
+			for _, id := range test.wantOn {
+				on[id] = true
+			}
+			for id := 1; id <= 5; id++ {
+				if got := fakeLight(t, fake, id).State.On; got != on[id] {
+					t.Errorf("light %d on = %t, want %t", id, got, on[id])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// bridgeClients holds one HTTP client per bridge host, so connections and
+// their verified certificates are reused
+var bridgeClients = struct {
+	sync.Mutex
+	clients map[string]*http.Client
+}{clients: map[string]*http.Client{}}
+
+// bridgeHTTPClient returns the HTTP client for requests to host. Bridges use
+// self-signed certificates, so instead of the system roots the certificate is
+// checked by verifyBridgeCertificate.
+func bridgeHTTPClient(host string) *http.Client {
+	bridgeClients.Lock()
+	defer bridgeClients.Unlock()
+
+	if client, ok := bridgeClients.clients[host]; ok {
+		return client
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{
+		InsecureSkipVerify: true, // replaced by VerifyConnection
+		VerifyConnection: func(state tls.ConnectionState) error {
+			return verifyBridgeCertificate(host, state.PeerCertificates[0])
+		},
+	}
+	client := &http.Client{Transport: transport}
+	bridgeClients.clients[host] = client
+	return client
+}
+
+// bridgeUsesTLS reports whether requests to host go over HTTPS. Hosts given
+// with an explicit http:// prefix use plain HTTP.
+func bridgeUsesTLS(host string) bool {
+	return !strings.HasPrefix(host, "http://")
+}
+
+// certificateFingerprint returns the hex SHA-256 of a certificate
+func certificateFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// bridgeTrust returns the bridge ID and pinned certificate fingerprint saved
+// for host, preferring the active profile. known is false when no profile
+// uses host. A host from HUE_BRIDGE_HOST stands in for the active profile's
+// host, so it is expected to be that profile's bridge, or the one HUE_BRIDGE_ID
+// names.
+func bridgeTrust(host string) (id, fingerprint string, known bool) {
+	profiles, err := loadProfileConfig()
+	if err == nil {
+		if profile, ok := profiles.Profiles[activeProfile(profiles)]; ok && profile.Host == host {
+			return profile.ID, profile.CertFingerprint, true
+		}
+		for _, name := range profileNames(profiles) {
+			if profile := profiles.Profiles[name]; profile.Host == host {
+				return profile.ID, profile.CertFingerprint, true
+			}
+		}
+	}
+
+	if host != os.Getenv(envBridgeHost) {
+		return "", "", false
+	}
+	if err == nil {
+		if profile, ok := profiles.Profiles[activeProfile(profiles)]; ok {
+			id, fingerprint = profile.ID, profile.CertFingerprint
+		}
+	}
+	if envID := strings.ToUpper(os.Getenv(envBridgeID)); envID != "" && envID != strings.ToUpper(id) {
+		id, fingerprint = envID, ""
+	}
+	return id, fingerprint, false
+}
+
+// sessionPins holds the certificate each bridge host presented first in this
+// run. Every later connection to the host must present the same one, so
+// nothing can step in between checking a bridge and sending it a username.
+var sessionPins = struct {
+	sync.Mutex
+	pins    map[string]string // host to fingerprint
+	pending map[string]pendingPin
+}{pins: map[string]string{}, pending: map[string]pendingPin{}}
+
+// pendingPin is a certificate to pin in the profiles of a host that have
+// none yet, saved by savePendingPins
+type pendingPin struct {
+	fingerprint string
+	bridgeID    string // bridge ID from the certificate, for profiles without one
+}
+
+// verifyBridgeCertificate checks the certificate of the bridge at host
+// against the certificate it presented earlier in this run, or else the
+// pinned fingerprint. Without a pin the certificate must be issued to the
+// bridge ID and is pinned from then on (trust on first use). Hosts without
+// a bridge ID to check against are only trusted once 'hue auth' checked
+// their certificate with trustBridge.
+func verifyBridgeCertificate(host string, cert *x509.Certificate) error {
+	fingerprint := certificateFingerprint(cert)
+
+	sessionPins.Lock()
+	defer sessionPins.Unlock()
+	if pinned, ok := sessionPins.pins[host]; ok {
+		return checkFingerprint(host, fingerprint, pinned)
+	}
+
+	id, pinned, known := bridgeTrust(host)
+	switch {
+	case pinned != "":
+		if err := checkFingerprint(host, fingerprint, pinned); err != nil {
+			return err
+		}
+	case isBridgeID(id):
+		if err := checkCertificateID(cert, id); err != nil {
+			return err
+		}
+		if known {
+			sessionPins.pending[host] = pendingPin{fingerprint: fingerprint}
+		}
+	case known:
+		// Profiles saved before certificates were checked may hold the IP
+		// address instead of the bridge ID
+		fmt.Fprintf(os.Stderr, "Warning: the profile for %s has no bridge ID to check the certificate against; trusting the certificate it presents now (%s)\n", host, fingerprint)
+		sessionPins.pending[host] = pendingPin{fingerprint: fingerprint, bridgeID: certificateBridgeID(cert)}
+	default:
+		return untrustedCertificateError("no profile knows the bridge at %s, so its certificate (%s) cannot be checked; "+
+			"set %s to its bridge ID or run 'hue auth --ip %s'", host, fingerprint, envBridgeID, host)
+	}
+	sessionPins.pins[host] = fingerprint
+	return nil
+}
+
+func checkFingerprint(host, fingerprint, pinned string) error {
+	if fingerprint != pinned {
+		return untrustedCertificateError("the certificate of the bridge at %s does not match the pinned one (got %s, expected %s); "+
+			"if the bridge was reset or replaced run 'hue profile trust', otherwise something is intercepting the connection",
+			host, fingerprint, pinned)
+	}
+	return nil
+}
+
+// certificateError returns the certificate error in err without the request
+// URL around it, which would show the username
+func certificateError(err error) error {
+	var cliErr *cliError
+	if errors.As(err, &cliErr) {
+		return cliErr
+	}
+	return err
+}
+
+// isBridgeID reports whether id looks like a bridge ID (16 hex digits)
+// rather than the IP address older versions stored when none was known
+func isBridgeID(id string) bool {
+	_, err := hex.DecodeString(id)
+	return len(id) == 16 && err == nil
+}
+
+// certificateBridgeID returns the bridge ID a certificate was issued to, or ""
+func certificateBridgeID(cert *x509.Certificate) string {
+	if id := strings.ToUpper(cert.Subject.CommonName); isBridgeID(id) {
+		return id
+	}
+	return ""
+}
+
+// checkCertificateID checks that a bridge certificate was issued to bridge id.
+// Bridges use their lowercase ID as the certificate's common name.
+func checkCertificateID(cert *x509.Certificate, id string) error {
+	if !strings.EqualFold(cert.Subject.CommonName, id) {
+		return untrustedCertificateError("the bridge certificate was issued to '%s', not to bridge %s", cert.Subject.CommonName, id)
+	}
+	return nil
+}
+
+// applyPendingPins pins the certificates verified in this run in every
+// profile of their host that has no pin yet. It reports whether config changed.
+func applyPendingPins(config *ProfileConfig) bool {
+	sessionPins.Lock()
+	defer sessionPins.Unlock()
+
+	changed := false
+	for name, profile := range config.Profiles {
+		pin, ok := sessionPins.pending[profile.Host]
+		if !ok || profile.CertFingerprint != "" {
+			continue
+		}
+		profile.CertFingerprint = pin.fingerprint
+		if !isBridgeID(profile.ID) && pin.bridgeID != "" {
+			profile.ID = pin.bridgeID
+		}
+		config.Profiles[name] = profile
+		changed = true
+		fmt.Fprintf(os.Stderr, "Pinned the certificate of the bridge at %s for profile '%s' (%s)\n", profile.Host, name, pin.fingerprint)
+	}
+	return changed
+}
+
+// savePendingPins saves the certificates first seen in this run. It runs
+// after the command, so it cannot be overwritten by a command saving a
+// config it loaded before the connection was made.
+func savePendingPins() error {
+	sessionPins.Lock()
+	pending := len(sessionPins.pending)
+	sessionPins.Unlock()
+	if pending == 0 {
+		return nil
+	}
+
+	unlock, err := lockConfig()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	profiles, err := loadProfileConfig()
+	if err != nil {
+		return err
+	}
+	if !applyPendingPins(profiles) {
+		return nil
+	}
+	return saveProfileConfig(*profiles)
+}
+
+// fetchBridgeCertificate connects to the bridge at host and returns its
+// certificate without checking it
+func fetchBridgeCertificate(host string) (*x509.Certificate, error) {
+	address := strings.TrimPrefix(host, "https://")
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, "443")
+	}
+
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	conn, err := tls.DialWithDialer(dialer, "tcp", address, &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.ConnectionState().PeerCertificates[0], nil
+}
+
+// trustBridge returns the fingerprint to pin for the bridge at host after
+// checking that its certificate belongs to bridge id, and holds every later
+// connection of this run to that certificate. The returned bridge ID is id,
+// or the one from the certificate when id is not a bridge ID. Plain HTTP
+// hosts have no certificate and return "".
+func trustBridge(host, id string) (fingerprint, bridgeID string, err error) {
+	if !bridgeUsesTLS(host) {
+		return "", id, nil
+	}
+	cert, err := fetchBridgeCertificate(host)
+	if err != nil {
+		return "", "", unreachableError("failed to read the bridge certificate: %w", err)
+	}
+	fingerprint = certificateFingerprint(cert)
+
+	sessionPins.Lock()
+	defer sessionPins.Unlock()
+	if pinned, ok := sessionPins.pins[host]; ok {
+		if err := checkFingerprint(host, fingerprint, pinned); err != nil {
+			return "", "", err
+		}
+	}
+	if isBridgeID(id) {
+		if err := checkCertificateID(cert, id); err != nil {
+			return "", "", err
+		}
+	} else {
+		fmt.Fprintf(os.Stderr, "Warning: no bridge ID to check the certificate of %s against; trusting the certificate it presents now\n", host)
+		if certID := certificateBridgeID(cert); certID != "" {
+			id = certID
+		}
+	}
+	sessionPins.pins[host] = fingerprint
+	return fingerprint, id, nil
+}
+
+var profileTrustCmd = &cobra.Command{
+	Use:   "trust",
+	Short: "Pin the current certificate of the profile's bridge",
+	Long: `Replace the pinned certificate of the current profile's bridge (or --profile) with the one it
+presents now, after checking that it was issued to the profile's bridge ID. Use this after
+the bridge was reset or its certificate was renewed. Profiles saved with the bridge's IP
+address instead of its ID get the ID from the certificate.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		unlock, err := lockConfig()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		profiles, err := loadProfileConfig()
+		if err != nil {
+			return notAuthorizedError("no saved bridge configuration found; run 'hue auth' first")
+		}
+		name := activeProfile(profiles)
+		profile, ok := profiles.Profiles[name]
+		if !ok {
+			return notFoundError("profile '%s' not found", name)
+		}
+		if !bridgeUsesTLS(profile.Host) {
+			return fmt.Errorf("profile '%s' uses plain HTTP, there is no certificate to pin", name)
+		}
+
+		fingerprint, bridgeID, err := trustBridge(profile.Host, profile.ID)
+		if err != nil {
+			return err
+		}
+		if fingerprint == profile.CertFingerprint && bridgeID == profile.ID {
+			fmt.Printf("The certificate of profile '%s' is already pinned (%s)\n", name, fingerprint)
+			return nil
+		}
+
+		profile.CertFingerprint = fingerprint
+		profile.ID = bridgeID
+		profiles.Profiles[name] = profile
+		if err := saveProfileConfig(*profiles); err != nil {
+			return fmt.Errorf("failed to save configuration: %w", err)
+		}
+		fmt.Printf("Pinned certificate %s for profile '%s'\n", fingerprint, name)
+		return nil
+	},
+}
+
+func init() {
+	profileCmd.AddCommand(profileTrustCmd)
+}
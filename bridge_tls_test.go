@@ -0,0 +1,205 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// useSessionPins starts the test without any certificates seen in this run
+func useSessionPins(t *testing.T) {
+	t.Helper()
+	reset := func() {
+		sessionPins.Lock()
+		sessionPins.pins = map[string]string{}
+		sessionPins.pending = map[string]pendingPin{}
+		sessionPins.Unlock()
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+// testCertificate returns a certificate issued to bridgeID, as the mock
+// bridge presents
+func testCertificate(t *testing.T, bridgeID string) (tls.Certificate, *x509.Certificate) {
+	t.Helper()
+	cert, err := bridgeCertificate(bridgeID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, parsed
+}
+
+// startMockBridge serves fake over HTTPS with a certificate issued to its
+// bridge ID, and returns the host to reach it at and the certificate
+func startMockBridge(t *testing.T, fake *fakeBridge) (string, *x509.Certificate) {
+	t.Helper()
+	cert, parsed := testCertificate(t, fake.info.BridgeID)
+	server := httptest.NewUnstartedServer(&mockBridgeServer{bridge: fake})
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return strings.TrimPrefix(server.URL, "https://"), parsed
+}
+
+func TestVerifyBridgeCertificate(t *testing.T) {
+	const host, envHost, bridgeID = "192.168.1.2", "10.0.0.2", "001788FFFE000000"
+	_, cert := testCertificate(t, bridgeID)
+	_, otherCert := testCertificate(t, "001788FFFE111111")
+
+	tests := []struct {
+		name    string
+		profile *BridgeConfig
+		host    string              // host connected to, the profile's host if empty
+		env     map[string]string   // bridge variables set
+		certs   []*x509.Certificate // presented in order, all but the last must be accepted
+		code    int
+		pending bool
+	}{
+		{name: "pin matches", profile: &BridgeConfig{ID: bridgeID, CertFingerprint: certificateFingerprint(cert)}, certs: []*x509.Certificate{cert}},
+		{name: "pin mismatch", profile: &BridgeConfig{ID: bridgeID, CertFingerprint: certificateFingerprint(otherCert)}, certs: []*x509.Certificate{cert}, code: exitUntrusted},
+		{name: "bridge ID matches", profile: &BridgeConfig{ID: bridgeID}, certs: []*x509.Certificate{cert}, pending: true},
+		{name: "bridge ID mismatch", profile: &BridgeConfig{ID: bridgeID}, certs: []*x509.Certificate{otherCert}, code: exitUntrusted},
+		{name: "IP address instead of an ID", profile: &BridgeConfig{ID: host}, certs: []*x509.Certificate{otherCert}, pending: true},
+		{name: "certificate changes during the run", profile: &BridgeConfig{ID: host}, certs: []*x509.Certificate{cert, otherCert}, code: exitUntrusted, pending: true},
+		{name: "unknown host", profile: &BridgeConfig{ID: bridgeID}, host: envHost, certs: []*x509.Certificate{cert}, code: exitUntrusted},
+		{name: "unknown host without a config file", host: envHost, certs: []*x509.Certificate{cert}, code: exitUntrusted},
+		{
+			name:    "environment host matches the profile's pin",
+			profile: &BridgeConfig{ID: bridgeID, CertFingerprint: certificateFingerprint(cert)},
+			host:    envHost,
+			env:     map[string]string{envBridgeHost: envHost},
+			certs:   []*x509.Certificate{cert},
+		},
+		{
+			name:    "environment host does not match the profile's pin",
+			profile: &BridgeConfig{ID: bridgeID, CertFingerprint: certificateFingerprint(otherCert)},
+			host:    envHost,
+			env:     map[string]string{envBridgeHost: envHost},
+			certs:   []*x509.Certificate{cert},
+			code:    exitUntrusted,
+		},
+		{
+			name:  "environment bridge ID matches",
+			host:  envHost,
+			env:   map[string]string{envBridgeHost: envHost, envBridgeID: strings.ToLower(bridgeID)},
+			certs: []*x509.Certificate{cert, cert},
+		},
+		{
+			name:  "environment bridge ID mismatch",
+			host:  envHost,
+			env:   map[string]string{envBridgeHost: envHost, envBridgeID: bridgeID},
+			certs: []*x509.Certificate{otherCert},
+			code:  exitUntrusted,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useFakeBridge(t)
+			useSessionPins(t)
+			for _, name := range []string{envBridgeHost, envBridgeID} {
+				t.Setenv(name, test.env[name])
+			}
+			if test.profile != nil {
+				profile := *test.profile
+				profile.Host, profile.Username = host, "test"
+				if err := saveBridgeConfig(profile); err != nil {
+					t.Fatal(err)
+				}
+			}
+			target := test.host
+			if target == "" {
+				target = host
+			}
+
+			var err error
+			for _, cert := range test.certs {
+				if err = verifyBridgeCertificate(target, cert); err != nil {
+					break
+				}
+			}
+			checkExitCode(t, err, test.code)
+			if _, pending := sessionPins.pending[target]; pending != test.pending {
+				t.Errorf("certificate pending to be pinned = %t, want %t", pending, test.pending)
+			}
+		})
+	}
+}
+
+func TestCertificatePinnedOnFirstUse(t *testing.T) {
+	useFakeBridge(t)
+	useSessionPins(t)
+	fake := newFakeBridge()
+	fake.openAccess = true
+	host, cert := startMockBridge(t, fake)
+
+	// Saved by an older version with the IP address as the ID
+	if err := saveBridgeConfig(BridgeConfig{Host: host, Username: "test", ID: "127.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newBridgeClient(host, "test").GetLights(); err != nil {
+		t.Fatalf("GetLights: %v", err)
+	}
+	if err := savePendingPins(); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := loadSavedBridgeConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.ID != fake.info.BridgeID || config.CertFingerprint != certificateFingerprint(cert) {
+		t.Errorf("profile = %+v, want bridge ID %s and the server's certificate pinned", config, fake.info.BridgeID)
+	}
+}
+
+func TestPinMismatchFailsRequests(t *testing.T) {
+	useFakeBridge(t)
+	useSessionPins(t)
+	fake := newFakeBridge()
+	fake.openAccess = true
+	host, _ := startMockBridge(t, fake)
+	_, other := testCertificate(t, fake.info.BridgeID)
+
+	if err := saveBridgeConfig(BridgeConfig{Host: host, Username: "test", ID: fake.info.BridgeID, CertFingerprint: certificateFingerprint(other)}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := newBridgeClient(host, "test").GetLights()
+	checkExitCode(t, err, exitUntrusted)
+	if strings.Contains(err.Error(), "/api/test") {
+		t.Errorf("error shows the username: %v", err)
+	}
+}
+
+func TestAuthIPPinsCertificate(t *testing.T) {
+	useFakeBridge(t)
+	useSessionPins(t)
+	fake := newFakeBridge()
+	fake.openAccess = true
+	host, cert := startMockBridge(t, fake)
+
+	if err := authCmd.ParseFlags([]string{"--ip", host, "--username", "test"}); err != nil {
+		t.Fatal(err)
+	}
+	defer authCmd.Flags().Set("ip", "")
+	defer authCmd.Flags().Set("username", "")
+	var err error
+	captureStdout(t, func() { err = authCmd.RunE(authCmd, nil) })
+	if err != nil {
+		t.Fatalf("auth: %v", err)
+	}
+
+	config, err := loadSavedBridgeConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Host != host || config.ID != fake.info.BridgeID || config.CertFingerprint != certificateFingerprint(cert) {
+		t.Errorf("profile = %+v, want bridge %s at %s with the server's certificate pinned", config, fake.info.BridgeID, host)
+	}
+}
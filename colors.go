@@ -0,0 +1,656 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Kinds of lightColor
+const (
+	colorKindRGB = "rgb"
+	colorKindXY  = "xy"
+	colorKindCt  = "ct"
+)
+
+// maxAliasDepth limits how many aliases may refer to each other
+const maxAliasDepth = 8
+
+// lightColor is a color parsed from the command line
+type lightColor struct {
+	Kind    string  // colorKindRGB, colorKindXY or colorKindCt
+	R, G, B uint8   // for colorKindRGB
+	X, Y    float64 // for colorKindXY
+	Mired   uint16  // for colorKindCt
+	Bri     int     // brightness given with the color (hex alpha), -1 if none
+}
+
+// parseColor parses a color given as a user alias, a CSS/X11 color name,
+// a hex code (RRGGBB, RRGGBBAA, RGB, RGBA, optionally prefixed with #),
+// rgb(r, g, b), hsl(h, s%, l%), hsv(h, s%, v%), xy:x,y or ct:<temperature>
+func parseColor(value string) (lightColor, error) {
+	return parseColorWithAliases(value, loadColorAliases(), 0)
+}
+
+func parseColorWithAliases(value string, aliases map[string]string, depth int) (lightColor, error) {
+	text := strings.ToLower(strings.TrimSpace(value))
+	if text == "" {
+		return lightColor{}, fmt.Errorf("empty color")
+	}
+
+	if alias, ok := aliases[text]; ok {
+		if depth >= maxAliasDepth {
+			return lightColor{}, fmt.Errorf("color alias '%s' refers to itself", text)
+		}
+		return parseColorWithAliases(alias, aliases, depth+1)
+	}
+
+	switch {
+	case strings.HasPrefix(text, "xy:"):
+		return parseXYColor(strings.TrimPrefix(text, "xy:"))
+	case strings.HasPrefix(text, "ct:"):
+		mired, err := parseColorTemperature(strings.TrimPrefix(text, "ct:"))
+		if err != nil {
+			return lightColor{}, err
+		}
+		return lightColor{Kind: colorKindCt, Mired: mired, Bri: -1}, nil
+	case strings.HasPrefix(text, "rgb(") || strings.HasPrefix(text, "hsl(") || strings.HasPrefix(text, "hsv("):
+		return parseColorFunction(text)
+	}
+
+	if rgb, ok := namedColors[text]; ok {
+		return rgbColor(rgb[0], rgb[1], rgb[2]), nil
+	}
+
+	hex := strings.TrimPrefix(text, "#")
+	if isHexColor(hex) {
+		r, g, b, a := parseHexColor(hex)
+		c := rgbColor(uint8(r), uint8(g), uint8(b))
+		if a >= 0 {
+			c.Bri = a
+		}
+		return c, nil
+	}
+
+	return lightColor{}, fmt.Errorf("unknown color '%s' (use a color name, hex code, rgb(), hsl(), hsv(), xy:x,y or ct:2700K)", value)
+}
+
+func rgbColor(r, g, b uint8) lightColor {
+	return lightColor{Kind: colorKindRGB, R: r, G: g, B: b, Bri: -1}
+}
+
+func parseXYColor(text string) (lightColor, error) {
+	parts := strings.Split(text, ",")
+	if len(parts) != 2 {
+		return lightColor{}, fmt.Errorf("xy color must be written as xy:x,y")
+	}
+	x, err1 := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	y, err2 := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err1 != nil || err2 != nil || x < 0 || x > 1 || y < 0 || y > 1 {
+		return lightColor{}, fmt.Errorf("xy coordinates must be numbers between 0 and 1")
+	}
+	return lightColor{Kind: colorKindXY, X: x, Y: y, Bri: -1}, nil
+}
+
+// parseColorFunction parses rgb(), hsl() and hsv() with comma or space
+// separated arguments
+func parseColorFunction(text string) (lightColor, error) {
+	open := strings.Index(text, "(")
+	if !strings.HasSuffix(text, ")") {
+		return lightColor{}, fmt.Errorf("missing ')' in color '%s'", text)
+	}
+	name := text[:open]
+	args := strings.FieldsFunc(text[open+1:len(text)-1], func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+	if len(args) != 3 {
+		return lightColor{}, fmt.Errorf("%s() takes 3 arguments", name)
+	}
+
+	if name == "rgb" {
+		var channels [3]uint8
+		for i, arg := range args {
+			value, err := parseColorComponent(arg, 255)
+			if err != nil {
+				return lightColor{}, fmt.Errorf("invalid rgb() value '%s'", arg)
+			}
+			channels[i] = uint8(math.Round(value))
+		}
+		return rgbColor(channels[0], channels[1], channels[2]), nil
+	}
+
+	hue, err := strconv.ParseFloat(strings.TrimSuffix(args[0], "deg"), 64)
+	if err != nil {
+		return lightColor{}, fmt.Errorf("invalid %s() hue '%s'", name, args[0])
+	}
+	s, err1 := parseColorComponent(args[1], 1)
+	v, err2 := parseColorComponent(args[2], 1)
+	if err1 != nil || err2 != nil {
+		return lightColor{}, fmt.Errorf("%s() saturation and %s must be percentages", name, map[string]string{"hsl": "lightness", "hsv": "value"}[name])
+	}
+
+	if name == "hsl" {
+		return rgbColor(hslToRGB(hue, s, v)), nil
+	}
+	return rgbColor(hsvToRGB(hue, s, v)), nil
+}
+
+// parseColorComponent parses a number between 0 and scale, or a percentage
+// of scale
+func parseColorComponent(arg string, scale float64) (float64, error) {
+	if percent, ok := strings.CutSuffix(arg, "%"); ok {
+		value, err := strconv.ParseFloat(percent, 64)
+		if err != nil || value < 0 || value > 100 {
+			return 0, fmt.Errorf("invalid percentage")
+		}
+		return value / 100 * scale, nil
+	}
+	value, err := strconv.ParseFloat(arg, 64)
+	if err != nil || value < 0 || value > scale {
+		return 0, fmt.Errorf("out of range")
+	}
+	return value, nil
+}
+
+// hslToRGB converts HSL color space to RGB
+// h: 0-360, s: 0-1, l: 0-1
+func hslToRGB(h, s, l float64) (uint8, uint8, uint8) {
+	v := l + s*math.Min(l, 1-l)
+	sv := 0.0
+	if v > 0 {
+		sv = 2 * (1 - l/v)
+	}
+	return hsvToRGB(h, sv, v)
+}
+
+// String describes the color for command output
+func (c lightColor) String() string {
+	switch c.Kind {
+	case colorKindXY:
+		return fmt.Sprintf("xy(%.4f, %.4f)", c.X, c.Y)
+	case colorKindCt:
+		return fmt.Sprintf("%dK", miredToKelvin(c.Mired))
+	default:
+		return fmt.Sprintf("RGB(%d, %d, %d)", c.R, c.G, c.B)
+	}
+}
+
+// capability names what a light needs to show the color, for messages
+func (c lightColor) capability() string {
+	if c.Kind == colorKindCt {
+		return "color temperature"
+	}
+	return "colors"
+}
+
+// capableLights splits lights into those that can show the color and
+// those that cannot
+func (c lightColor) capableLights(lights []Light) (capable, skipped []Light) {
+	if c.Kind == colorKindCt {
+		return temperatureLights(lights)
+	}
+	return colorLights(lights)
+}
+
+// applyTo adds the color to state, converted for what light can show
+func (c lightColor) applyTo(state stateBuilder, light Light) stateBuilder {
+	switch c.Kind {
+	case colorKindCt:
+		return state.Ct(lightMired(light, c.Mired))
+	case colorKindXY:
+		p := lightGamut(light).clamp(xyPoint{c.X, c.Y})
+		return state.Xy([]float32{float32(p.X), float32(p.Y)})
+	default:
+		return state.Xy(rgbToLightXY(c.R, c.G, c.B, light))
+	}
+}
+
+// parseColorArgs parses the color arguments of the color command and of
+// color scene commands: either "red green blue [brightness]" or
+// "<color> [brightness]". Functional colors split by the shell, such as
+// rgb(255, 0, 0), are joined back together. The returned brightness is -1
+// when none was given.
+func parseColorArgs(values []string) (lightColor, int, error) {
+	if len(values) >= 3 && len(values) <= 4 && allIntegers(values[:3]) {
+		r, _ := strconv.Atoi(values[0])
+		g, _ := strconv.Atoi(values[1])
+		b, _ := strconv.Atoi(values[2])
+		if r < 0 || r > 255 || g < 0 || g > 255 || b < 0 || b > 255 {
+			return lightColor{}, -1, fmt.Errorf("RGB values must be numbers between 0 and 255")
+		}
+		brightness, err := parseColorBrightness(values[3:])
+		return rgbColor(uint8(r), uint8(g), uint8(b)), brightness, err
+	}
+
+	if len(values) == 0 {
+		return lightColor{}, -1, fmt.Errorf("a color must be provided")
+	}
+
+	spec := values[0]
+	rest := values[1:]
+	if strings.Contains(spec, "(") && !strings.Contains(spec, ")") {
+		for len(rest) > 0 && !strings.Contains(spec, ")") {
+			spec += " " + rest[0]
+			rest = rest[1:]
+		}
+	}
+	if len(rest) > 1 {
+		return lightColor{}, -1, fmt.Errorf("too many arguments after color '%s'", spec)
+	}
+
+	color, err := parseColor(spec)
+	if err != nil {
+		return lightColor{}, -1, err
+	}
+
+	brightness, err := parseColorBrightness(rest)
+	if err != nil {
+		return lightColor{}, -1, err
+	}
+	if brightness < 0 && color.Bri >= 0 {
+		brightness = color.Bri
+	}
+	if brightness > 254 {
+		brightness = 254 // Just to not break the user script if they provide 255 or FF as that feels more natural
+	}
+	return color, brightness, nil
+}
+
+func parseColorBrightness(values []string) (int, error) {
+	if len(values) == 0 {
+		return -1, nil
+	}
+	brightness, err := strconv.Atoi(values[0])
+	if err != nil || brightness < 0 || brightness > 255 {
+		return -1, fmt.Errorf("brightness value must be a number between 0 and 254")
+	}
+	if brightness > 254 {
+		brightness = 254
+	}
+	return brightness, nil
+}
+
+func allIntegers(values []string) bool {
+	for _, value := range values {
+		if _, err := strconv.Atoi(value); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// declaredColorAliases holds the aliases of a file 'hue apply' is checking,
+// so its scenes may use them before they are saved
+var declaredColorAliases map[string]string
+
+// loadColorAliases returns the user's color aliases, or none if the config
+// cannot be read
+func loadColorAliases() map[string]string {
+	aliases := map[string]string{}
+	if config, err := loadProfileConfig(); err == nil {
+		for name, value := range config.Colors {
+			aliases[name] = value
+		}
+	}
+	for name, value := range declaredColorAliases {
+		aliases[strings.ToLower(name)] = value
+	}
+	return aliases
+}
+
+// Color alias commands
+var colorsCmd = &cobra.Command{
+	Use:   "colors",
+	Short: "Manage color aliases",
+	Long: `Manage named color aliases that can be used anywhere a color is accepted.
+
+Colors can be written as:
+  coral, rebeccapurple      CSS/X11 color names
+  FF7F50, #ff7f50, F75      hex codes (RRGGBB, RRGGBBAA, RGB, RGBA)
+  rgb(255, 127, 80)         RGB, 0-255 or percentages
+  hsl(16, 100%, 66%)        hue, saturation, lightness
+  hsv(16, 69%, 100%)        hue, saturation, value
+  xy:0.31,0.32              CIE xy coordinates
+  ct:2700K                  color temperature (Kelvin, mireds or a preset)`,
+}
+
+func init() {
+	colorsCmd.AddCommand(colorsListCmd)
+	colorsCmd.AddCommand(colorsSetCmd)
+	colorsCmd.AddCommand(colorsRemoveCmd)
+
+	colorsRemoveCmd.Flags().BoolP("force", "f", false, "Remove the alias even if scenes use it")
+}
+
+var colorsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List color aliases",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		all, _ := cmd.Flags().GetBool("all")
+		aliases := loadColorAliases()
+
+		doc := colorsDocument{Aliases: aliases}
+		if all {
+			doc.Named = make(map[string]string, len(namedColors))
+			for name, rgb := range namedColors {
+				doc.Named[name] = fmt.Sprintf("#%02X%02X%02X", rgb[0], rgb[1], rgb[2])
+			}
+		}
+
+		return printOutput(doc, func(w io.Writer) {
+			if len(aliases) == 0 && !all {
+				fmt.Fprintln(w, "No color aliases defined")
+				fmt.Fprintln(w, "Create one with: hue colors set <name> <color>")
+				return
+			}
+			fmt.Fprintln(w, "Name\tColor")
+			for _, name := range sortedKeys(aliases) {
+				fmt.Fprintf(w, "%s\t%s\n", name, aliases[name])
+			}
+			for _, name := range sortedKeys(doc.Named) {
+				fmt.Fprintf(w, "%s\t%s\n", name, doc.Named[name])
+			}
+		})
+	},
+}
+
+func init() {
+	colorsListCmd.Flags().BoolP("all", "a", false, "Also list the built-in color names")
+}
+
+var colorsSetCmd = &cobra.Command{
+	Use:   "set [name] [color]",
+	Short: "Define a color alias",
+	Long: `Define a color alias. The color may use any supported notation, including other aliases.
+
+Examples:
+  hue colors set reading ct:3000K
+  hue colors set brand "#1DB954"
+  hue colors set sunset "hsl(20, 90%, 55%)"`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := strings.ToLower(args[0])
+		value := strings.Join(args[1:], " ")
+
+		if strings.ContainsAny(name, " :(),#") {
+			return fmt.Errorf("invalid alias name '%s'", args[0])
+		}
+
+		unlock, err := lockConfig()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		config, err := loadProfileConfig()
+		if os.IsNotExist(err) {
+			config = &ProfileConfig{Profiles: map[string]BridgeConfig{}}
+		} else if err != nil {
+			return fmt.Errorf("failed to read configuration: %w", err)
+		}
+		if config.Colors == nil {
+			config.Colors = map[string]string{}
+		}
+		config.Colors[name] = value
+
+		// Resolving the new alias also rejects aliases that refer to themselves
+		if _, err := parseColorWithAliases(name, config.Colors, 0); err != nil {
+			return err
+		}
+
+		if err := saveProfileConfig(*config); err != nil {
+			return fmt.Errorf("failed to save configuration: %w", err)
+		}
+
+		fmt.Printf("Color alias '%s' set to %s\n", name, value)
+		return nil
+	},
+}
+
+var colorsRemoveCmd = &cobra.Command{
+	Use:   "remove [name]",
+	Short: "Remove a color alias",
+	Long: `Remove a color alias. An alias that scenes of the current profile use, directly or through
+another alias, is only removed with --force; those scenes then fail until they are changed.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := strings.ToLower(args[0])
+		force, _ := cmd.Flags().GetBool("force")
+
+		unlock, err := lockConfig()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		config, err := loadProfileConfig()
+		if err != nil || config.Colors[name] == "" {
+			return notFoundError("color alias '%s' not found", name)
+		}
+
+		scenes, err := scenesUsingAlias(name, config.Colors)
+		if err != nil {
+			return fmt.Errorf("failed to load scenes: %w", err)
+		}
+		if len(scenes) > 0 && !force {
+			return fmt.Errorf("color alias '%s' is used by scenes %s (use --force to remove it anyway)", name, strings.Join(scenes, ", "))
+		}
+
+		delete(config.Colors, name)
+		if err := saveProfileConfig(*config); err != nil {
+			return fmt.Errorf("failed to save configuration: %w", err)
+		}
+
+		fmt.Printf("Color alias '%s' removed\n", name)
+		return nil
+	},
+}
+
+// scenesUsingAlias returns the names of the scenes with a color command
+// that resolves through alias
+func scenesUsingAlias(alias string, aliases map[string]string) ([]string, error) {
+	config, err := loadSceneConfig()
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for i := range config.Scenes {
+		for _, command := range sceneCommands(&config.Scenes[i]) {
+			if command.Type == "color" && len(command.Values) > 0 && colorUsesAlias(command.Values[0], alias, aliases) {
+				names = append(names, config.Scenes[i].Name)
+				break
+			}
+		}
+	}
+	return names, nil
+}
+
+// colorUsesAlias reports whether value is alias or an alias that refers to
+// it. Alias names have no spaces, so only the first value of a color
+// command can be one.
+func colorUsesAlias(value, alias string, aliases map[string]string) bool {
+	text := strings.ToLower(strings.TrimSpace(value))
+	for depth := 0; depth <= maxAliasDepth; depth++ {
+		if text == alias {
+			return true
+		}
+		next, ok := aliases[text]
+		if !ok {
+			return false
+		}
+		text = strings.ToLower(strings.TrimSpace(next))
+	}
+	return false
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// namedColors are the CSS Color Module Level 4 named colors, which follow
+// the X11 color names
+var namedColors = map[string][3]uint8{
+	"aliceblue":            {240, 248, 255},
+	"antiquewhite":         {250, 235, 215},
+	"aqua":                 {0, 255, 255},
+	"aquamarine":           {127, 255, 212},
+	"azure":                {240, 255, 255},
+	"beige":                {245, 245, 220},
+	"bisque":               {255, 228, 196},
+	"black":                {0, 0, 0},
+	"blanchedalmond":       {255, 235, 205},
+	"blue":                 {0, 0, 255},
+	"blueviolet":           {138, 43, 226},
+	"brown":                {165, 42, 42},
+	"burlywood":            {222, 184, 135},
+	"cadetblue":            {95, 158, 160},
+	"chartreuse":           {127, 255, 0},
+	"chocolate":            {210, 105, 30},
+	"coral":                {255, 127, 80},
+	"cornflowerblue":       {100, 149, 237},
+	"cornsilk":             {255, 248, 220},
+	"crimson":              {220, 20, 60},
+	"cyan":                 {0, 255, 255},
+	"darkblue":             {0, 0, 139},
+	"darkcyan":             {0, 139, 139},
+	"darkgoldenrod":        {184, 134, 11},
+	"darkgray":             {169, 169, 169},
+	"darkgreen":            {0, 100, 0},
+	"darkgrey":             {169, 169, 169},
+	"darkkhaki":            {189, 183, 107},
+	"darkmagenta":          {139, 0, 139},
+	"darkolivegreen":       {85, 107, 47},
+	"darkorange":           {255, 140, 0},
+	"darkorchid":           {153, 50, 204},
+	"darkred":              {139, 0, 0},
+	"darksalmon":           {233, 150, 122},
+	"darkseagreen":         {143, 188, 143},
+	"darkslateblue":        {72, 61, 139},
+	"darkslategray":        {47, 79, 79},
+	"darkslategrey":        {47, 79, 79},
+	"darkturquoise":        {0, 206, 209},
+	"darkviolet":           {148, 0, 211},
+	"deeppink":             {255, 20, 147},
+	"deepskyblue":          {0, 191, 255},
+	"dimgray":              {105, 105, 105},
+	"dimgrey":              {105, 105, 105},
+	"dodgerblue":           {30, 144, 255},
+	"firebrick":            {178, 34, 34},
+	"floralwhite":          {255, 250, 240},
+	"forestgreen":          {34, 139, 34},
+	"fuchsia":              {255, 0, 255},
+	"gainsboro":            {220, 220, 220},
+	"ghostwhite":           {248, 248, 255},
+	"gold":                 {255, 215, 0},
+	"goldenrod":            {218, 165, 32},
+	"gray":                 {128, 128, 128},
+	"green":                {0, 128, 0},
+	"greenyellow":          {173, 255, 47},
+	"grey":                 {128, 128, 128},
+	"honeydew":             {240, 255, 240},
+	"hotpink":              {255, 105, 180},
+	"indianred":            {205, 92, 92},
+	"indigo":               {75, 0, 130},
+	"ivory":                {255, 255, 240},
+	"khaki":                {240, 230, 140},
+	"lavender":             {230, 230, 250},
+	"lavenderblush":        {255, 240, 245},
+	"lawngreen":            {124, 252, 0},
+	"lemonchiffon":         {255, 250, 205},
+	"lightblue":            {173, 216, 230},
+	"lightcoral":           {240, 128, 128},
+	"lightcyan":            {224, 255, 255},
+	"lightgoldenrodyellow": {250, 250, 210},
+	"lightgray":            {211, 211, 211},
+	"lightgreen":           {144, 238, 144},
+	"lightgrey":            {211, 211, 211},
+	"lightpink":            {255, 182, 193},
+	"lightsalmon":          {255, 160, 122},
+	"lightseagreen":        {32, 178, 170},
+	"lightskyblue":         {135, 206, 250},
+	"lightslategray":       {119, 136, 153},
+	"lightslategrey":       {119, 136, 153},
+	"lightsteelblue":       {176, 196, 222},
+	"lightyellow":          {255, 255, 224},
+	"lime":                 {0, 255, 0},
+	"limegreen":            {50, 205, 50},
+	"linen":                {250, 240, 230},
+	"magenta":              {255, 0, 255},
+	"maroon":               {128, 0, 0},
+	"mediumaquamarine":     {102, 205, 170},
+	"mediumblue":           {0, 0, 205},
+	"mediumorchid":         {186, 85, 211},
+	"mediumpurple":         {147, 112, 219},
+	"mediumseagreen":       {60, 179, 113},
+	"mediumslateblue":      {123, 104, 238},
+	"mediumspringgreen":    {0, 250, 154},
+	"mediumturquoise":      {72, 209, 204},
+	"mediumvioletred":      {199, 21, 133},
+	"midnightblue":         {25, 25, 112},
+	"mintcream":            {245, 255, 250},
+	"mistyrose":            {255, 228, 225},
+	"moccasin":             {255, 228, 181},
+	"navajowhite":          {255, 222, 173},
+	"navy":                 {0, 0, 128},
+	"oldlace":              {253, 245, 230},
+	"olive":                {128, 128, 0},
+	"olivedrab":            {107, 142, 35},
+	"orange":               {255, 165, 0},
+	"orangered":            {255, 69, 0},
+	"orchid":               {218, 112, 214},
+	"palegoldenrod":        {238, 232, 170},
+	"palegreen":            {152, 251, 152},
+	"paleturquoise":        {175, 238, 238},
+	"palevioletred":        {219, 112, 147},
+	"papayawhip":           {255, 239, 213},
+	"peachpuff":            {255, 218, 185},
+	"peru":                 {205, 133, 63},
+	"pink":                 {255, 192, 203},
+	"plum":                 {221, 160, 221},
+	"powderblue":           {176, 224, 230},
+	"purple":               {128, 0, 128},
+	"rebeccapurple":        {102, 51, 153},
+	"red":                  {255, 0, 0},
+	"rosybrown":            {188, 143, 143},
+	"royalblue":            {65, 105, 225},
+	"saddlebrown":          {139, 69, 19},
+	"salmon":               {250, 128, 114},
+	"sandybrown":           {244, 164, 96},
+	"seagreen":             {46, 139, 87},
+	"seashell":             {255, 245, 238},
+	"sienna":               {160, 82, 45},
+	"silver":               {192, 192, 192},
+	"skyblue":              {135, 206, 235},
+	"slateblue":            {106, 90, 205},
+	"slategray":            {112, 128, 144},
+	"slategrey":            {112, 128, 144},
+	"snow":                 {255, 250, 250},
+	"springgreen":          {0, 255, 127},
+	"steelblue":            {70, 130, 180},
+	"tan":                  {210, 180, 140},
+	"teal":                 {0, 128, 128},
+	"thistle":              {216, 191, 216},
+	"tomato":               {255, 99, 71},
+	"turquoise":            {64, 224, 208},
+	"violet":               {238, 130, 238},
+	"wheat":                {245, 222, 179},
+	"white":                {255, 255, 255},
+	"whitesmoke":           {245, 245, 245},
+	"yellow":               {255, 255, 0},
+	"yellowgreen":          {154, 205, 50},
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestParseColor(t *testing.T) {
+	aliases := map[string]string{
+		"brand":   "#1db954",
+		"accent":  "brand",
+		"reading": "ct:3000K",
+		"loop":    "loop",
+	}
+	tests := []struct {
+		value   string
+		want    lightColor
+		wantErr bool
+	}{
+		{value: "coral", want: rgbColor(255, 127, 80)},
+		{value: "RebeccaPurple", want: rgbColor(102, 51, 153)},
+		{value: "#FF7F50", want: rgbColor(255, 127, 80)},
+		{value: "ff7f50", want: rgbColor(255, 127, 80)},
+		{value: "F75", want: rgbColor(255, 119, 85)},
+		{value: "#ff000080", want: lightColor{Kind: colorKindRGB, R: 255, Bri: 128}},
+		{value: "rgb(255, 127, 80)", want: rgbColor(255, 127, 80)},
+		{value: "rgb(100% 0% 50%)", want: rgbColor(255, 0, 128)},
+		{value: "hsl(0, 100%, 50%)", want: rgbColor(255, 0, 0)},
+		{value: "hsl(120deg 100% 50%)", want: rgbColor(0, 255, 0)},
+		{value: "hsv(240, 100%, 100%)", want: rgbColor(0, 0, 255)},
+		{value: "xy:0.31,0.32", want: lightColor{Kind: colorKindXY, X: 0.31, Y: 0.32, Bri: -1}},
+		{value: "ct:2700K", want: lightColor{Kind: colorKindCt, Mired: 370, Bri: -1}},
+		{value: "ct:250mired", want: lightColor{Kind: colorKindCt, Mired: 250, Bri: -1}},
+		{value: "brand", want: rgbColor(29, 185, 84)},
+		{value: "Accent", want: rgbColor(29, 185, 84)},
+		{value: "reading", want: lightColor{Kind: colorKindCt, Mired: 333, Bri: -1}},
+		{value: "", wantErr: true},
+		{value: "notacolor", wantErr: true},
+		{value: "xy:1.5,0.3", wantErr: true},
+		{value: "xy:0.3", wantErr: true},
+		{value: "rgb(1, 2)", wantErr: true},
+		{value: "rgb(300, 0, 0)", wantErr: true},
+		{value: "hsl(0, 100, 50%", wantErr: true},
+		{value: "ct:hot", wantErr: true},
+		{value: "loop", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			got, err := parseColorWithAliases(test.value, aliases, 0)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("parseColor(%q) = %+v, want an error", test.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseColor(%q): %v", test.value, err)
+			}
+			if got != test.want {
+				t.Errorf("parseColor(%q) = %+v, want %+v", test.value, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseColorArgs(t *testing.T) {
+	tests := []struct {
+		name           string
+		values         []string
+		want           lightColor
+		wantBrightness int
+		wantErr        bool
+	}{
+		{name: "rgb values", values: []string{"255", "0", "0"}, want: rgbColor(255, 0, 0), wantBrightness: -1},
+		{name: "rgb values with brightness", values: []string{"0", "0", "255", "100"}, want: rgbColor(0, 0, 255), wantBrightness: 100},
+		{name: "color", values: []string{"coral"}, want: rgbColor(255, 127, 80), wantBrightness: -1},
+		{name: "color with brightness", values: []string{"coral", "200"}, want: rgbColor(255, 127, 80), wantBrightness: 200},
+		{name: "function split by the shell", values: []string{"rgb(255,", "0,", "0)", "50"}, want: rgbColor(255, 0, 0), wantBrightness: 50},
+		{name: "no color", values: []string{}, wantErr: true},
+		{name: "rgb out of range", values: []string{"300", "0", "0"}, wantErr: true},
+		{name: "brightness out of range", values: []string{"coral", "300"}, wantErr: true},
+		{name: "too many arguments", values: []string{"coral", "1", "2"}, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useFakeBridge(t)
+			got, brightness, err := parseColorArgs(test.values)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("parseColorArgs(%q) = %+v, %d, want an error", test.values, got, brightness)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseColorArgs(%q): %v", test.values, err)
+			}
+			if got != test.want || brightness != test.wantBrightness {
+				t.Errorf("parseColorArgs(%q) = %+v, %d, want %+v, %d", test.values, got, brightness, test.want, test.wantBrightness)
+			}
+		})
+	}
+}
+
+func TestColorsRemoveCmd(t *testing.T) {
+	tests := []struct {
+		name  string
+		alias string
+		force bool
+		kept  bool
+		code  int
+	}{
+		{name: "unused alias", alias: "spare"},
+		{name: "used by a scene", alias: "brand", kept: true, code: exitError},
+		{name: "used through another alias", alias: "green", kept: true, code: exitError},
+		{name: "used by a scene with force", alias: "brand", force: true},
+		{name: "unknown alias", alias: "missing", code: exitNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useFakeBridge(t)
+			if err := saveProfileConfig(ProfileConfig{Colors: map[string]string{"green": "#1DB954", "brand": "green", "spare": "red"}}); err != nil {
+				t.Fatal(err)
+			}
+			if err := saveSceneConfig(SceneConfig{Scenes: []Scene{
+				{Name: "focus", Steps: []SceneStep{{Commands: []SceneCommand{{Type: "color", Light: "1", Values: []string{"Brand", "200"}}}}}},
+			}}); err != nil {
+				t.Fatal(err)
+			}
+
+			colorsRemoveCmd.Flags().Set("force", strconv.FormatBool(test.force))
+			defer colorsRemoveCmd.Flags().Set("force", "false")
+			var err error
+			captureStdout(t, func() { err = colorsRemoveCmd.RunE(colorsRemoveCmd, []string{test.alias}) })
+			checkExitCode(t, err, test.code)
+
+			config, err := loadProfileConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, kept := config.Colors[test.alias]; test.alias != "missing" && kept != test.kept {
+				t.Errorf("alias kept = %t, want %t", kept, test.kept)
+			}
+		})
+	}
+}
+
+func TestColorsSetCmdUnreadableConfig(t *testing.T) {
+	useFakeBridge(t)
+	if err := os.WriteFile(configFile, []byte("{not json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	checkExitCode(t, colorsSetCmd.RunE(colorsSetCmd, []string{"brand", "#1DB954"}), exitError)
+	if data, _ := os.ReadFile(configFile); string(data) != "{not json" {
+		t.Errorf("config file = %q, want it left alone", data)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Environment variables overriding the config directory and the bridge
+// credentials, for containers and other setups without a writable home
+const (
+	envConfigDir  = "HUE_CONFIG_DIR"
+	envBridgeHost = "HUE_BRIDGE_HOST"
+	envBridgeID   = "HUE_BRIDGE_ID"
+	envUsername   = "HUE_USERNAME"
+	envClientKey  = "HUE_CLIENTKEY"
+	envAPI        = "HUE_API"
+)
+
+// legacyPrefix starts the names of the files older versions kept in the
+// home directory, such as ~/.hue-scenes.json
+const legacyPrefix = ".hue-"
+
+// defaultConfigDir returns HUE_CONFIG_DIR if set, otherwise hue-cli in the
+// user's config directory ($XDG_CONFIG_HOME or ~/.config on Linux)
+func defaultConfigDir() (string, error) {
+	if dir := os.Getenv(envConfigDir); dir != "" {
+		return dir, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "hue-cli"), nil
+}
+
+// setupConfigDir points configDir and configFile at the config directory,
+// creating it and moving the files of older versions into it
+func setupConfigDir() error {
+	dir, err := defaultConfigDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	configDir = dir
+	configFile = filepath.Join(dir, "config.json")
+
+	if os.Getenv(envConfigDir) != "" {
+		return nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	return migrateLegacyFiles(homeDir)
+}
+
+// migrateLegacyFiles moves ~/.hue-*.json (and their backups) into the config
+// directory, dropping the ".hue-" prefix. Files already present in the
+// config directory are never replaced.
+func migrateLegacyFiles(homeDir string) error {
+	legacy, _ := filepath.Glob(filepath.Join(homeDir, legacyPrefix+"*.json*"))
+	if len(legacy) == 0 {
+		return nil
+	}
+
+	unlock, err := lockConfig()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	moved := 0
+	for _, path := range legacy {
+		name := strings.TrimPrefix(filepath.Base(path), legacyPrefix)
+		target := filepath.Join(configDir, name)
+		if _, err := os.Stat(target); err == nil {
+			fmt.Fprintf(os.Stderr, "Warning: not moving %s, %s already exists\n", path, target)
+			continue
+		}
+		if err := moveFile(path, target); err != nil {
+			if os.IsNotExist(err) {
+				// Moved by another hue process in the meantime
+				continue
+			}
+			return fmt.Errorf("failed to move %s to %s: %w", path, configDir, err)
+		}
+		moved++
+	}
+
+	if moved > 0 {
+		fmt.Fprintf(os.Stderr, "Moved %d config files from %s to %s\n", moved, homeDir, configDir)
+	}
+	return nil
+}
+
+// moveFile renames a file, copying it when the target is on another file system
+func moveFile(from, to string) error {
+	if err := os.Rename(from, to); err == nil {
+		return nil
+	}
+	data, err := os.ReadFile(from)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(to, data, 0600); err != nil {
+		return err
+	}
+	return os.Remove(from)
+}
+
+// applyEnvOverrides replaces the bridge settings of config with those set in
+// the environment and returns the names of the variables used
+func applyEnvOverrides(config *BridgeConfig) []string {
+	var used []string
+	for _, override := range []struct {
+		name  string
+		field *string
+	}{
+		{envBridgeHost, &config.Host},
+		{envBridgeID, &config.ID},
+		{envUsername, &config.Username},
+		{envClientKey, &config.ClientKey},
+		{envAPI, &config.API},
+	} {
+		if value := os.Getenv(override.name); value != "" {
+			*override.field = value
+			used = append(used, override.name)
+		}
+	}
+	return used
+}
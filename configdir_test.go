@@ -0,0 +1,118 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDefaultConfigDir(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "/xdg")
+	t.Setenv(envConfigDir, "")
+	if dir, err := defaultConfigDir(); err != nil || dir != "/xdg/hue-cli" {
+		t.Errorf("defaultConfigDir = %s (%v), want /xdg/hue-cli", dir, err)
+	}
+
+	t.Setenv(envConfigDir, "/etc/hue")
+	if dir, err := defaultConfigDir(); err != nil || dir != "/etc/hue" {
+		t.Errorf("defaultConfigDir = %s (%v), want %s", dir, err, "/etc/hue")
+	}
+}
+
+func TestMigrateLegacyFiles(t *testing.T) {
+	useFakeBridge(t)
+	home := t.TempDir()
+	for name, data := range map[string]string{
+		".hue-config.json":     "config",
+		".hue-config.json.bak": "backup",
+		".hue-scenes.json":     "old scenes",
+		".hue-groups.json":     "groups",
+		".bashrc":              "unrelated",
+	} {
+		if err := os.WriteFile(filepath.Join(home, name), []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(configDir, "scenes.json"), []byte("new scenes"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := migrateLegacyFiles(home); err != nil {
+		t.Fatalf("migrateLegacyFiles: %v", err)
+	}
+
+	want := map[string]string{
+		"config.json":     "config",
+		"config.json.bak": "backup",
+		"groups.json":     "groups",
+		"scenes.json":     "new scenes",
+	}
+	for name, data := range want {
+		if got, err := os.ReadFile(filepath.Join(configDir, name)); err != nil || string(got) != data {
+			t.Errorf("%s = %q (%v), want %q", name, got, err, data)
+		}
+	}
+	var left []string
+	entries, _ := os.ReadDir(home)
+	for _, entry := range entries {
+		left = append(left, entry.Name())
+	}
+	// A file that would replace one in the config directory stays behind
+	if wantLeft := []string{".bashrc", ".hue-scenes.json"}; !reflect.DeepEqual(left, wantLeft) {
+		t.Errorf("home directory holds %v, want %v", left, wantLeft)
+	}
+}
+
+func TestLoadBridgeConfigEnvOverrides(t *testing.T) {
+	tests := []struct {
+		name  string
+		saved *BridgeConfig
+		env   map[string]string
+		want  BridgeConfig
+		fails bool
+	}{
+		{
+			name:  "saved profile",
+			saved: &BridgeConfig{Host: "192.168.1.2", Username: "saved"},
+			want:  BridgeConfig{Host: "192.168.1.2", Username: "saved"},
+		},
+		{
+			name:  "variables take precedence",
+			saved: &BridgeConfig{Host: "192.168.1.2", Username: "saved", ClientKey: "key"},
+			env:   map[string]string{envUsername: "from-env", envAPI: apiV2},
+			want:  BridgeConfig{Host: "192.168.1.2", Username: "from-env", ClientKey: "key", API: apiV2},
+		},
+		{
+			name: "variables without a config file",
+			env:  map[string]string{envBridgeHost: "10.0.0.2", envBridgeID: "001788FFFE000000", envUsername: "from-env"},
+			want: BridgeConfig{Host: "10.0.0.2", ID: "001788FFFE000000", Username: "from-env"},
+		},
+		{
+			name:  "host alone is not enough",
+			env:   map[string]string{envBridgeHost: "10.0.0.2"},
+			fails: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useFakeBridge(t)
+			for _, name := range []string{envBridgeHost, envBridgeID, envUsername, envClientKey, envAPI} {
+				t.Setenv(name, test.env[name])
+			}
+			if test.saved != nil {
+				if err := saveBridgeConfig(*test.saved); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			config, err := loadBridgeConfig()
+			if (err != nil) != test.fails {
+				t.Fatalf("loadBridgeConfig error = %v, want failure %t", err, test.fails)
+			}
+			if err == nil && !reflect.DeepEqual(config, test.want) {
+				t.Errorf("config = %+v, want %+v", config, test.want)
+			}
+		})
+	}
+}
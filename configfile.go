@@ -0,0 +1,179 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// lockTimeout is how long a command waits for another hue process to
+	// release the config lock
+	lockTimeout = 10 * time.Second
+	// staleLockAge is the age after which a lock is taken to be left behind
+	// by a crashed process. Held locks are touched every staleLockAge/3.
+	staleLockAge = 30 * time.Second
+)
+
+var configLock struct {
+	sync.Mutex
+	depth int
+	owner string // contents of the lock file while this process holds it
+	stop  chan struct{}
+}
+
+// lockFile is the lock shared by all config files of all profiles
+func lockFile() string {
+	return filepath.Join(configDir, "hue.lock")
+}
+
+// lockConfig takes the config lock so that load-modify-save sequences of
+// concurrent hue processes do not overwrite each other's changes. The lock
+// can be taken again by the same process; call the returned function to
+// release it.
+func lockConfig() (func(), error) {
+	configLock.Lock()
+	defer configLock.Unlock()
+
+	if configLock.depth == 0 {
+		owner, err := acquireLockFile(lockFile())
+		if err != nil {
+			return nil, err
+		}
+		configLock.owner = owner
+		configLock.stop = make(chan struct{})
+		go refreshLockFile(lockFile(), configLock.stop)
+	}
+	configLock.depth++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			configLock.Lock()
+			defer configLock.Unlock()
+			configLock.depth--
+			if configLock.depth == 0 {
+				close(configLock.stop)
+				removeLockFile(lockFile(), configLock.owner, false)
+			}
+		})
+	}, nil
+}
+
+// acquireLockFile creates the lock file, waiting while another process holds
+// it and taking it over when its owner stopped refreshing it. It returns the
+// contents written to the file: the process ID and a random token, which
+// tell this lock apart from any later one.
+func acquireLockFile(path string) (string, error) {
+	token := make([]byte, 8)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	owner := fmt.Sprintf("%d %x\n", os.Getpid(), token)
+
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			_, err = file.WriteString(owner)
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+			return owner, err
+		}
+		if !os.IsExist(err) {
+			return "", fmt.Errorf("failed to lock config: %w", err)
+		}
+
+		data, readErr := os.ReadFile(path)
+		if info, err := os.Stat(path); readErr == nil && err == nil && time.Since(info.ModTime()) > staleLockAge {
+			removeLockFile(path, string(data), true)
+			continue
+		}
+		if time.Now().After(deadline) {
+			owner := "another hue process"
+			if fields := strings.Fields(string(data)); len(fields) > 0 {
+				if pid, err := strconv.Atoi(fields[0]); err == nil {
+					owner = fmt.Sprintf("hue process %d", pid)
+				}
+			}
+			return "", fmt.Errorf("config is locked by %s (remove %s if no other hue command is running)", owner, path)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// removeLockFile removes the lock file at path if it still holds owner and,
+// for a stale lock, has not been refreshed since. The file is first renamed
+// to a name no other process uses, so the check and the removal concern the
+// same file even when another process takes the lock in between; a file
+// that fails the check is linked back unless a new lock exists by then.
+func removeLockFile(path, owner string, stale bool) bool {
+	claimed := fmt.Sprintf("%s.%d-%d", path, os.Getpid(), time.Now().UnixNano())
+	if err := os.Rename(path, claimed); err != nil {
+		return false
+	}
+	defer os.Remove(claimed)
+
+	data, err := os.ReadFile(claimed)
+	ours := err == nil && string(data) == owner
+	if info, err := os.Stat(claimed); stale && (err != nil || time.Since(info.ModTime()) <= staleLockAge) {
+		ours = false
+	}
+	if !ours {
+		os.Link(claimed, path)
+	}
+	return ours
+}
+
+// refreshLockFile keeps the lock from looking stale while it is held
+func refreshLockFile(path string, stop chan struct{}) {
+	ticker := time.NewTicker(staleLockAge / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			now := time.Now()
+			os.Chtimes(path, now, now)
+		}
+	}
+}
+
+// writeConfigFile replaces a config file without ever leaving it partly
+// written: the data goes to a temporary file in the same directory which is
+// then renamed over the file. The previous version is kept as <file>.bak.
+func writeConfigFile(path string, data []byte) error {
+	temp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+
+	_, err = temp.Write(data)
+	if err == nil {
+		err = temp.Sync()
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(temp.Name(), 0600)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	if previous, err := os.ReadFile(path); err == nil {
+		if err := os.WriteFile(path+".bak", previous, 0600); err != nil {
+			return fmt.Errorf("failed to back up %s: %w", path, err)
+		}
+	}
+	return os.Rename(temp.Name(), path)
+}
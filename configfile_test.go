@@ -0,0 +1,190 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWriteConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "scenes.json")
+
+	for _, data := range []string{"first", "second", "third"} {
+		if err := writeConfigFile(path, []byte(data)); err != nil {
+			t.Fatalf("writeConfigFile(%s): %v", data, err)
+		}
+	}
+
+	for file, want := range map[string]string{path: "third", path + ".bak": "second"} {
+		data, err := os.ReadFile(file)
+		if err != nil || string(data) != want {
+			t.Errorf("%s = %q (%v), want %q", filepath.Base(file), data, err, want)
+		}
+		if info, err := os.Stat(file); err == nil && info.Mode().Perm() != 0600 {
+			t.Errorf("%s mode = %v, want 0600", filepath.Base(file), info.Mode().Perm())
+		}
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Errorf("directory holds %d files, want the file and its backup", len(entries))
+	}
+}
+
+func TestLockConfigIsReentrant(t *testing.T) {
+	useFakeBridge(t)
+
+	unlockOuter, err := lockConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlockInner, err := lockConfig()
+	if err != nil {
+		t.Fatalf("taking the lock again: %v", err)
+	}
+	unlockInner()
+	unlockInner()
+	if _, err := os.Stat(lockFile()); err != nil {
+		t.Errorf("lock file was removed while the outer lock is held: %v", err)
+	}
+	unlockOuter()
+	if _, err := os.Stat(lockFile()); !os.IsNotExist(err) {
+		t.Errorf("lock file still exists after unlocking: %v", err)
+	}
+}
+
+func TestAcquireLockFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		age     time.Duration
+		release time.Duration // when the other process releases the lock, 0 for never
+		wait    time.Duration // minimum time acquiring should take
+	}{
+		{name: "free"},
+		{name: "released by its owner", release: 200 * time.Millisecond, wait: 200 * time.Millisecond},
+		{name: "left behind by a crashed process", age: 2 * staleLockAge},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "hue.lock")
+			if test.name != "free" {
+				if err := os.WriteFile(path, []byte("99999\n"), 0600); err != nil {
+					t.Fatal(err)
+				}
+				old := time.Now().Add(-test.age)
+				os.Chtimes(path, old, old)
+			}
+			if test.release > 0 {
+				time.AfterFunc(test.release, func() { os.Remove(path) })
+			}
+
+			start := time.Now()
+			owner, err := acquireLockFile(path)
+			if err != nil {
+				t.Fatalf("acquireLockFile: %v", err)
+			}
+			if elapsed := time.Since(start); elapsed < test.wait {
+				t.Errorf("lock taken after %s, want at least %s", elapsed, test.wait)
+			}
+			data, _ := os.ReadFile(path)
+			if fields := strings.Fields(string(data)); string(data) != owner || len(fields) != 2 || fields[0] != strconv.Itoa(os.Getpid()) {
+				t.Errorf("lock file holds %q, want this process (%q)", data, owner)
+			}
+		})
+	}
+}
+
+func TestRemoveLockFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		age     time.Duration
+		stale   bool
+		removed bool
+	}{
+		{name: "own lock", content: "1 aa\n", removed: true},
+		{name: "lock of another process", content: "2 bb\n"},
+		{name: "stale lock", content: "1 aa\n", age: 2 * staleLockAge, stale: true, removed: true},
+		{name: "refreshed since it looked stale", content: "1 aa\n", stale: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "hue.lock")
+			if err := os.WriteFile(path, []byte(test.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			old := time.Now().Add(-test.age)
+			os.Chtimes(path, old, old)
+
+			if removed := removeLockFile(path, "1 aa\n", test.stale); removed != test.removed {
+				t.Errorf("removed = %t, want %t", removed, test.removed)
+			}
+			data, err := os.ReadFile(path)
+			if test.removed != os.IsNotExist(err) || (!test.removed && string(data) != test.content) {
+				t.Errorf("lock file = %q (%v) after removing, want removed %t", data, err, test.removed)
+			}
+			if entries, _ := os.ReadDir(dir); len(entries) > 1 {
+				t.Errorf("directory holds %d files, want no claimed lock left behind", len(entries))
+			}
+		})
+	}
+}
+
+// TestLockFileExcludesOtherProcesses runs load-modify-save sequences as
+// separate processes would, each taking the lock file itself, also when they
+// all find a stale lock and race to take it over
+func TestLockFileExcludesOtherProcesses(t *testing.T) {
+	for _, stale := range []bool{false, true} {
+		t.Run("stale lock "+strconv.FormatBool(stale), func(t *testing.T) {
+			dir := t.TempDir()
+			lock := filepath.Join(dir, "hue.lock")
+			counter := filepath.Join(dir, "counter")
+			if err := writeConfigFile(counter, []byte("0")); err != nil {
+				t.Fatal(err)
+			}
+			if stale {
+				if err := os.WriteFile(lock, []byte("99999 00\n"), 0600); err != nil {
+					t.Fatal(err)
+				}
+				old := time.Now().Add(-2 * staleLockAge)
+				os.Chtimes(lock, old, old)
+			}
+
+			const workers, rounds = 4, 10
+			var wg sync.WaitGroup
+			for i := 0; i < workers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < rounds; j++ {
+						owner, err := acquireLockFile(lock)
+						if err != nil {
+							t.Error(err)
+							return
+						}
+						data, _ := os.ReadFile(counter)
+						n, _ := strconv.Atoi(string(data))
+						if err := writeConfigFile(counter, []byte(strconv.Itoa(n+1))); err != nil {
+							t.Error(err)
+						}
+						if !removeLockFile(lock, owner, false) {
+							t.Error("the lock was taken over while it was held")
+						}
+					}
+				}()
+			}
+			wg.Wait()
+
+			data, _ := os.ReadFile(counter)
+			if string(data) != strconv.Itoa(workers*rounds) {
+				t.Errorf("counter = %s, want %d", data, workers*rounds)
+			}
+		})
+	}
+}
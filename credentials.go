@@ -0,0 +1,477 @@
+package main
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// Ways the bridge credentials in the config file can be encrypted. Without
+// encryption they are stored as plain text.
+const (
+	encryptPassphrase = "passphrase" // key derived from a passphrase
+	encryptKeyring    = "keyring"    // random key kept in a separate key file
+)
+
+const (
+	envPassphrase  = "HUE_PASSPHRASE"
+	envKeyringFile = "HUE_KEYRING_FILE"
+)
+
+// pbkdf2Iterations is the PBKDF2-SHA256 work factor for passphrase keys
+const pbkdf2Iterations = 600000
+
+// credentials is the content of an encrypted BridgeConfig.Secret
+type credentials struct {
+	Username  string `json:"username"`
+	ClientKey string `json:"clientkey,omitempty"`
+}
+
+// keyVerifier is sealed with the credential key and stored in the config
+// file, so that a mistyped passphrase is rejected instead of encrypting new
+// credentials with a key that does not open the others
+const keyVerifier = "hue-cli credentials"
+
+// credentialKeyCache holds the key once it has been derived or read, so the
+// passphrase is asked for at most once per command
+var credentialKeyCache []byte
+
+// keyringFile returns HUE_KEYRING_FILE, or keyring.key in the config directory
+func keyringFile() string {
+	if file := os.Getenv(envKeyringFile); file != "" {
+		return file
+	}
+	return filepath.Join(configDir, "keyring.key")
+}
+
+// credentialKey returns the AES-256 key for the encryption set in config
+func credentialKey(config *ProfileConfig) ([]byte, error) {
+	if credentialKeyCache != nil {
+		return credentialKeyCache, nil
+	}
+
+	var key []byte
+	switch config.Encryption {
+	case encryptPassphrase:
+		salt, err := base64.StdEncoding.DecodeString(config.Salt)
+		if err != nil || len(salt) == 0 {
+			return nil, fmt.Errorf("config file has no valid salt for the passphrase")
+		}
+		passphrase, err := readPassphrase("Passphrase for the bridge credentials: ")
+		if err != nil {
+			return nil, err
+		}
+		key, err = pbkdf2.Key(sha256.New, passphrase, salt, pbkdf2Iterations, 32)
+		if err != nil {
+			return nil, err
+		}
+	case encryptKeyring:
+		data, err := os.ReadFile(keyringFile())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read keyring: %w", err)
+		}
+		key, err = hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("keyring %s does not hold a valid key", keyringFile())
+		}
+	default:
+		return nil, fmt.Errorf("unknown credential encryption '%s'", config.Encryption)
+	}
+	if err := checkCredentialKey(config, key); err != nil {
+		return nil, err
+	}
+
+	credentialKeyCache = key
+	return key, nil
+}
+
+// checkCredentialKey verifies key against the verifier, or against the
+// credentials already encrypted by versions that did not store one
+func checkCredentialKey(config *ProfileConfig, key []byte) error {
+	wrongKey := notAuthorizedError("wrong passphrase or keyring: it does not decrypt the credentials already stored in %s", configFile)
+	if config.Verifier != "" {
+		if plaintext, err := openSecret(key, config.Verifier); err != nil || string(plaintext) != keyVerifier {
+			return wrongKey
+		}
+		return nil
+	}
+	for _, profile := range config.Profiles {
+		if profile.Secret == "" {
+			continue
+		}
+		if _, err := openSecret(key, profile.Secret); err != nil {
+			return wrongKey
+		}
+	}
+	return nil
+}
+
+// stdin reads answers that are not typed on a terminal. Every prompt shares
+// it, so lines piped in for several prompts are not lost in the buffer of
+// the first one.
+var stdin = bufio.NewReader(os.Stdin)
+
+// readPassphrase returns HUE_PASSPHRASE, or asks for the passphrase on the terminal
+func readPassphrase(prompt string) (string, error) {
+	if passphrase := os.Getenv(envPassphrase); passphrase != "" {
+		return passphrase, nil
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+	var passphrase string
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		data, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		passphrase = string(data)
+	} else {
+		line, err := stdin.ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("no passphrase given (set %s when not running on a terminal)", envPassphrase)
+		}
+		passphrase = strings.TrimRight(line, "\r\n")
+	}
+
+	if passphrase == "" {
+		return "", fmt.Errorf("the passphrase must not be empty")
+	}
+	return passphrase, nil
+}
+
+// encryptCredentials seals the credentials with AES-GCM
+func encryptCredentials(key []byte, creds credentials) (string, error) {
+	plaintext, err := json.Marshal(creds)
+	if err != nil {
+		return "", err
+	}
+	return sealSecret(key, plaintext)
+}
+
+func decryptCredentials(key []byte, secret string) (credentials, error) {
+	var creds credentials
+	plaintext, err := openSecret(key, secret)
+	if err != nil {
+		credentialKeyCache = nil
+		return creds, err
+	}
+	err = json.Unmarshal(plaintext, &creds)
+	return creds, err
+}
+
+// sealSecret encrypts plaintext with AES-GCM and returns base64 of nonce and
+// ciphertext
+func sealSecret(key, plaintext []byte) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, plaintext, nil)), nil
+}
+
+func openSecret(key []byte, secret string) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := base64.StdEncoding.DecodeString(secret)
+	if err != nil || len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("encrypted credentials are damaged")
+	}
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, notAuthorizedError("failed to decrypt the bridge credentials: wrong passphrase or keyring")
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealProfiles encrypts the credentials of every profile that holds them in
+// plain text, if the config uses encryption. The profiles map is copied so
+// the caller's configs keep their credentials.
+func sealProfiles(config *ProfileConfig) error {
+	if config.Encryption == "" {
+		return nil
+	}
+
+	profiles := make(map[string]BridgeConfig, len(config.Profiles))
+	for name, profile := range config.Profiles {
+		if profile.Username != "" || profile.ClientKey != "" {
+			key, err := credentialKey(config)
+			if err != nil {
+				return err
+			}
+			secret, err := encryptCredentials(key, credentials{Username: profile.Username, ClientKey: profile.ClientKey})
+			if err != nil {
+				return err
+			}
+			profile.Username, profile.ClientKey, profile.Secret = "", "", secret
+		}
+		profiles[name] = profile
+	}
+	config.Profiles = profiles
+
+	// Files encrypted before verifiers were stored get one once the key is known
+	if config.Verifier == "" && credentialKeyCache != nil {
+		verifier, err := sealSecret(credentialKeyCache, []byte(keyVerifier))
+		if err != nil {
+			return err
+		}
+		config.Verifier = verifier
+	}
+	return nil
+}
+
+// openProfile decrypts the credentials of profile if they are encrypted
+func openProfile(config *ProfileConfig, profile BridgeConfig) (BridgeConfig, error) {
+	if profile.Secret == "" {
+		return profile, nil
+	}
+	key, err := credentialKey(config)
+	if err != nil {
+		return profile, notAuthorizedError("failed to read the bridge credentials: %w", err)
+	}
+	creds, err := decryptCredentials(key, profile.Secret)
+	if err != nil {
+		return profile, err
+	}
+	profile.Username, profile.ClientKey, profile.Secret = creds.Username, creds.ClientKey, ""
+	return profile, nil
+}
+
+// redact hides all but the first and last four characters of a secret
+func redact(secret string) string {
+	if len(secret) <= 12 {
+		return strings.Repeat("*", len(secret))
+	}
+	return secret[:4] + strings.Repeat("*", len(secret)-8) + secret[len(secret)-4:]
+}
+
+var configEncryptCmd = &cobra.Command{
+	Use:   "encrypt",
+	Short: "Encrypt the bridge credentials in the config file",
+	Long: `Encrypt the usernames and client keys of all profiles. With --keyring a random key is
+written to a key file (keyring.key in the config directory, or HUE_KEYRING_FILE) that can
+be kept apart from the config file; otherwise the key is derived from a passphrase, which
+is asked for when a command needs the credentials or read from HUE_PASSPHRASE.
+
+Examples:
+  hue config encrypt
+  HUE_KEYRING_FILE=/run/secrets/hue.key hue config encrypt --keyring`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		useKeyring, _ := cmd.Flags().GetBool("keyring")
+
+		unlock, err := lockConfig()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		config, err := loadProfileConfig()
+		if err != nil {
+			return notAuthorizedError("no saved bridge configuration found; run 'hue auth' first")
+		}
+		if config.Encryption != "" {
+			return fmt.Errorf("credentials are already encrypted with a %s; run 'hue config decrypt' first", config.Encryption)
+		}
+
+		encryption := encryptPassphrase
+		if useKeyring {
+			encryption = encryptKeyring
+			if _, err := os.Stat(keyringFile()); err == nil {
+				return fmt.Errorf("keyring %s already exists; remove it or set %s to another file", keyringFile(), envKeyringFile)
+			}
+			key := make([]byte, 32)
+			if _, err := rand.Read(key); err != nil {
+				return err
+			}
+			if err := os.WriteFile(keyringFile(), []byte(hex.EncodeToString(key)+"\n"), 0600); err != nil {
+				return fmt.Errorf("failed to write keyring: %w", err)
+			}
+			credentialKeyCache = key
+		} else {
+			passphrase, err := readPassphrase("New passphrase: ")
+			if err != nil {
+				return err
+			}
+			if os.Getenv(envPassphrase) == "" {
+				again, err := readPassphrase("Repeat passphrase: ")
+				if err != nil {
+					return err
+				}
+				if again != passphrase {
+					return fmt.Errorf("the passphrases do not match")
+				}
+			}
+			salt := make([]byte, 16)
+			if _, err := rand.Read(salt); err != nil {
+				return err
+			}
+			config.Salt = base64.StdEncoding.EncodeToString(salt)
+			if credentialKeyCache, err = pbkdf2.Key(sha256.New, passphrase, salt, pbkdf2Iterations, 32); err != nil {
+				return err
+			}
+		}
+
+		config.Encryption = encryption
+		if err := saveProfileConfig(*config); err != nil {
+			return fmt.Errorf("failed to save configuration: %w", err)
+		}
+		fmt.Printf("Encrypted the credentials of all profiles with a %s\n", encryption)
+		if useKeyring {
+			fmt.Printf("Keyring: %s (keep it safe, the credentials cannot be read without it)\n", keyringFile())
+		}
+		return nil
+	},
+}
+
+var configDecryptCmd = &cobra.Command{
+	Use:   "decrypt",
+	Short: "Store the bridge credentials in plain text again",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		unlock, err := lockConfig()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		config, err := loadProfileConfig()
+		if err != nil {
+			return notAuthorizedError("no saved bridge configuration found; run 'hue auth' first")
+		}
+		if config.Encryption == "" {
+			return fmt.Errorf("credentials are not encrypted")
+		}
+
+		for name, profile := range config.Profiles {
+			if config.Profiles[name], err = openProfile(config, profile); err != nil {
+				return err
+			}
+		}
+		encryption := config.Encryption
+		config.Encryption, config.Salt, config.Verifier = "", "", ""
+		if err := saveProfileConfig(*config); err != nil {
+			return fmt.Errorf("failed to save configuration: %w", err)
+		}
+
+		fmt.Println("The credentials of all profiles are stored in plain text")
+		if encryption == encryptKeyring {
+			fmt.Printf("The keyring %s is no longer needed\n", keyringFile())
+		}
+		return nil
+	},
+}
+
+var authRotateCmd = &cobra.Command{
+	Use:   "rotate",
+	Short: "Replace the bridge user with a new one",
+	Long: `Create a new bridge user (press the link button when asked), check that it works, save it
+and delete the old user from the bridge. Use this when the username may have leaked.
+
+Bridges that do not allow removing users through the API keep the old user; remove it
+from your Hue account.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if os.Getenv(envUsername) != "" {
+			return fmt.Errorf("the credentials come from %s; rotate them where that variable is set", envUsername)
+		}
+
+		wait, err := linkWaitFlag(cmd)
+		if err != nil {
+			return err
+		}
+
+		old, err := loadSavedBridgeConfig()
+		if err != nil {
+			return notAuthorizedError("no saved bridge configuration found for profile '%s'; run 'hue auth' first", currentProfile())
+		}
+
+		// Pairing can take the whole --wait, so the config is only locked
+		// to save the result
+		fmt.Printf("Creating a new user on the bridge at %s\n", old.Host)
+		username, clientKey, err := waitForLinkButton(old.Host, old.Device, wait)
+		if err != nil {
+			return err
+		}
+
+		client := newBridgeClient(old.Host, username)
+		if _, err := client.GetLights(); err != nil {
+			return fmt.Errorf("the new user does not work, keeping the old one: %w", err)
+		}
+
+		if err := saveRotatedUser(old, username, clientKey); err != nil {
+			return err
+		}
+		fmt.Printf("New user %s saved to profile '%s'\n", redact(username), currentProfile())
+
+		err = client.DeleteUser(old.Username)
+		if errors.Is(err, errUserDeletionUnsupported) {
+			return partialFailureError("the old user %s is still valid: %v", redact(old.Username), err)
+		}
+		if err != nil {
+			return partialFailureError("failed to delete the old user %s from the bridge: %v", redact(old.Username), err)
+		}
+		fmt.Printf("Old user %s deleted from the bridge\n", redact(old.Username))
+		return nil
+	},
+}
+
+// saveRotatedUser replaces the credentials of the current profile, unless
+// another hue process changed them while the new user was created
+func saveRotatedUser(old BridgeConfig, username, clientKey string) error {
+	unlock, err := lockConfig()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	current, err := loadSavedBridgeConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	if current.Host != old.Host || current.Username != old.Username {
+		return fmt.Errorf("profile '%s' changed while the new user was created; the new user %s was not saved", currentProfile(), redact(username))
+	}
+
+	current.Username, current.ClientKey = username, clientKey
+	if err := saveBridgeConfig(current); err != nil {
+		return fmt.Errorf("failed to save configuration: %w", err)
+	}
+	return nil
+}
+
+func init() {
+	configCmd.AddCommand(configEncryptCmd)
+	configCmd.AddCommand(configDecryptCmd)
+	authCmd.AddCommand(authRotateCmd)
+
+	configEncryptCmd.Flags().Bool("keyring", false, "Keep a random key in a key file instead of using a passphrase")
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// useCredentialKey clears the cached credential key before and after the
+// test, as every command starts without one
+func useCredentialKey(t *testing.T) {
+	t.Helper()
+	credentialKeyCache = nil
+	t.Cleanup(func() { credentialKeyCache = nil })
+}
+
+func TestSealAndOpenSecret(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	otherKey := bytes.Repeat([]byte{2}, 32)
+
+	secret, err := sealSecret(key, []byte("username"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := sealSecret(key, []byte("username"))
+	if secret == again {
+		t.Error("sealing the same value twice gave the same secret")
+	}
+
+	if plaintext, err := openSecret(key, secret); err != nil || string(plaintext) != "username" {
+		t.Errorf("openSecret = %q, %v; want username", plaintext, err)
+	}
+	_, err = openSecret(otherKey, secret)
+	checkExitCode(t, err, exitNotAuthorized)
+	if _, err := openSecret(key, secret[:10]); err == nil {
+		t.Error("a damaged secret was opened")
+	}
+}
+
+func TestConfigEncryptKeyring(t *testing.T) {
+	useFakeBridge(t)
+	useCredentialKey(t)
+	t.Setenv(envKeyringFile, filepath.Join(t.TempDir(), "hue.key"))
+	saved := BridgeConfig{Host: "192.168.1.2", Username: "plain-username", ClientKey: "PLAINCLIENTKEY"}
+	if err := saveBridgeConfig(saved); err != nil {
+		t.Fatal(err)
+	}
+
+	configEncryptCmd.Flags().Set("keyring", "true")
+	defer configEncryptCmd.Flags().Set("keyring", "false")
+	if err := configEncryptCmd.RunE(configEncryptCmd, nil); err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("plain-username")) || bytes.Contains(data, []byte("PLAINCLIENTKEY")) {
+		t.Errorf("config file holds the credentials in plain text:\n%s", data)
+	}
+
+	credentialKeyCache = nil
+	if config, err := loadSavedBridgeConfig(); err != nil || config != saved {
+		t.Errorf("loadSavedBridgeConfig = %+v, %v; want %+v", config, err, saved)
+	}
+
+	// Without the keyring the credentials cannot be read
+	credentialKeyCache = nil
+	t.Setenv(envKeyringFile, filepath.Join(t.TempDir(), "missing.key"))
+	_, err = loadSavedBridgeConfig()
+	checkExitCode(t, err, exitNotAuthorized)
+}
+
+func TestConfigEncryptPassphrase(t *testing.T) {
+	useFakeBridge(t)
+	useCredentialKey(t)
+	t.Setenv(envPassphrase, "correct horse")
+	saved := BridgeConfig{Host: "192.168.1.2", Username: "plain-username"}
+	if err := saveBridgeConfig(saved); err != nil {
+		t.Fatal(err)
+	}
+	if err := configEncryptCmd.RunE(configEncryptCmd, nil); err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	checkExitCode(t, configEncryptCmd.RunE(configEncryptCmd, nil), exitError)
+
+	credentialKeyCache = nil
+	t.Setenv(envPassphrase, "wrong horse")
+	_, err := loadSavedBridgeConfig()
+	checkExitCode(t, err, exitNotAuthorized)
+
+	credentialKeyCache = nil
+	t.Setenv(envPassphrase, "correct horse")
+	if err := configDecryptCmd.RunE(configDecryptCmd, nil); err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	data, _ := os.ReadFile(configFile)
+	if !bytes.Contains(data, []byte("plain-username")) {
+		t.Errorf("config file after decrypt = %s, want the username in plain text", data)
+	}
+}
+
+func TestConfigEncryptPipedPassphrase(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		code  int
+	}{
+		{name: "both lines", input: "secretpw\nsecretpw\n"},
+		{name: "mismatch", input: "secretpw\nsecretpx\n", code: exitError},
+		{name: "only one line", input: "secretpw\n", code: exitError},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useFakeBridge(t)
+			useCredentialKey(t)
+			t.Setenv(envPassphrase, "")
+			previous := stdin
+			stdin = bufio.NewReader(strings.NewReader(test.input))
+			defer func() { stdin = previous }()
+			saved := BridgeConfig{Host: "192.168.1.2", Username: "plain-username"}
+			if err := saveBridgeConfig(saved); err != nil {
+				t.Fatal(err)
+			}
+
+			checkExitCode(t, configEncryptCmd.RunE(configEncryptCmd, nil), test.code)
+			if test.code != exitOK {
+				return
+			}
+			credentialKeyCache = nil
+			t.Setenv(envPassphrase, "secretpw")
+			if config, err := loadSavedBridgeConfig(); err != nil || config != saved {
+				t.Errorf("loadSavedBridgeConfig = %+v, %v; want %+v", config, err, saved)
+			}
+		})
+	}
+}
+
+func TestWrongPassphraseIsNotUsedForNewCredentials(t *testing.T) {
+	useFakeBridge(t)
+	useCredentialKey(t)
+	t.Setenv(envPassphrase, "correct horse")
+	if err := saveBridgeConfig(BridgeConfig{Host: "192.168.1.2", Username: "first"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := configEncryptCmd.RunE(configEncryptCmd, nil); err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+
+	credentialKeyCache = nil
+	t.Setenv(envPassphrase, "wrong horse")
+	useProfile(t, "office")
+	checkExitCode(t, saveBridgeConfig(BridgeConfig{Host: "192.168.1.3", Username: "second"}), exitNotAuthorized)
+
+	credentialKeyCache = nil
+	t.Setenv(envPassphrase, "correct horse")
+	if err := saveBridgeConfig(BridgeConfig{Host: "192.168.1.3", Username: "second"}); err != nil {
+		t.Fatalf("saving with the right passphrase: %v", err)
+	}
+	credentialKeyCache = nil
+	if config, err := loadSavedBridgeConfig(); err != nil || config.Username != "second" {
+		t.Errorf("office profile = %+v, %v", config, err)
+	}
+}
+
+func TestAuthRotateCmd(t *testing.T) {
+	useFakeBridge(t)
+	useCredentialKey(t)
+	fake := defaultFakeBridge()
+	old, _, err := fake.createUser("hue_cli#rotate-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := saveBridgeConfig(BridgeConfig{Host: fakeBridgeHost, Username: old}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := authRotateCmd.ParseFlags([]string{"--wait", "2s"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := authRotateCmd.RunE(authRotateCmd, nil); err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+
+	config, err := loadSavedBridgeConfig()
+	if err != nil || config.Username == old || config.Username == "" || config.ClientKey == "" {
+		t.Fatalf("config after rotate = %+v, %v; want a new user", config, err)
+	}
+	users, err := fake.getUsers(config.Username)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, user := range users {
+		if user.ID == old {
+			t.Errorf("old user %s is still on the bridge", old)
+		}
+	}
+}
+
+func TestRedact(t *testing.T) {
+	tests := map[string]string{
+		"":                         "",
+		"short":                    "*****",
+		"abcdefghijklmnopqrstuvwx": "abcd****************uvwx",
+	}
+	for secret, want := range tests {
+		if got := redact(secret); got != want {
+			t.Errorf("redact(%q) = %q, want %q", secret, got, want)
+		}
+	}
+}
@@ -0,0 +1,510 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/amimof/huego"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	mdnsAddress    = "224.0.0.251:5353"
+	mdnsService    = "_hue._tcp.local."
+	ssdpAddress    = "239.255.255.250:1900"
+	probeWorkers   = 64
+	maxProbeSubnet = 24 // never probe more than a /24 per interface
+)
+
+// discoveredBridge is a bridge found on the network, with the details its
+// unauthenticated /api/config endpoint reports
+type discoveredBridge struct {
+	Host       string   `json:"host" yaml:"host"`
+	ID         string   `json:"id" yaml:"id"`
+	Name       string   `json:"name,omitempty" yaml:"name,omitempty"`
+	ModelID    string   `json:"model_id,omitempty" yaml:"model_id,omitempty"`
+	APIVersion string   `json:"api_version,omitempty" yaml:"api_version,omitempty"`
+	SwVersion  string   `json:"sw_version,omitempty" yaml:"sw_version,omitempty"`
+	Methods    []string `json:"methods" yaml:"methods"` // discovery methods that found the bridge
+}
+
+// discoveryHit is a bridge address reported by a discovery method. The ID
+// is filled in when the method advertises it.
+type discoveryHit struct {
+	host string
+	id   string
+}
+
+// discoveryMethod is one way of looking for bridges
+type discoveryMethod struct {
+	name  string
+	label string
+	run   func(timeout time.Duration) ([]discoveryHit, error)
+}
+
+// discoveryResult is the outcome of running a discoveryMethod
+type discoveryResult struct {
+	method discoveryMethod
+	hits   []discoveryHit
+	err    error
+}
+
+// discoveryOptions selects the discovery methods to run
+type discoveryOptions struct {
+	timeout time.Duration
+	cloud   bool
+	scan    bool
+	subnet  string // CIDR to probe instead of the local interfaces' networks
+}
+
+// discoveryMethods returns the methods enabled by opts. mDNS and SSDP work
+// without internet access; the cloud service does not.
+func discoveryMethods(opts discoveryOptions) []discoveryMethod {
+	methods := []discoveryMethod{
+		{name: "mdns", label: "mDNS (_hue._tcp)", run: discoverMDNS},
+		{name: "ssdp", label: "SSDP (UPnP M-SEARCH)", run: discoverSSDP},
+	}
+	if opts.cloud {
+		methods = append(methods, discoveryMethod{name: "cloud", label: "Philips discovery service", run: discoverCloud})
+	}
+	if opts.scan || opts.subnet != "" {
+		subnet := opts.subnet
+		methods = append(methods, discoveryMethod{name: "scan", label: "Subnet probe", run: func(timeout time.Duration) ([]discoveryHit, error) {
+			return discoverSubnet(subnet, timeout)
+		}})
+	}
+	return methods
+}
+
+// runDiscovery runs all methods concurrently and returns their results in
+// the order of methods
+func runDiscovery(methods []discoveryMethod, timeout time.Duration) []discoveryResult {
+	results := make([]discoveryResult, len(methods))
+	var wg sync.WaitGroup
+	for i, method := range methods {
+		wg.Add(1)
+		go func(i int, method discoveryMethod) {
+			defer wg.Done()
+			hits, err := method.run(timeout)
+			results[i] = discoveryResult{method: method, hits: hits, err: err}
+		}(i, method)
+	}
+	wg.Wait()
+	return results
+}
+
+// collectBridges merges the hits of all results by host and queries each
+// bridge for its details
+func collectBridges(results []discoveryResult, timeout time.Duration) []discoveredBridge {
+	byHost := make(map[string]*discoveredBridge)
+	var hosts []string
+	for _, result := range results {
+		for _, hit := range result.hits {
+			found, exists := byHost[hit.host]
+			if !exists {
+				found = &discoveredBridge{Host: hit.host}
+				byHost[hit.host] = found
+				hosts = append(hosts, hit.host)
+			}
+			if found.ID == "" {
+				found.ID = hit.id
+			}
+			if !containsString(found.Methods, result.method.name) {
+				found.Methods = append(found.Methods, result.method.name)
+			}
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, host := range hosts {
+		wg.Add(1)
+		go func(found *discoveredBridge) {
+			defer wg.Done()
+			info, err := probeBridge(found.Host, timeout)
+			if err != nil {
+				return
+			}
+			found.ID = info.ID
+			found.Name = info.Name
+			found.ModelID = info.ModelID
+			found.APIVersion = info.APIVersion
+			found.SwVersion = info.SwVersion
+		}(byHost[host])
+	}
+	wg.Wait()
+
+	sort.Strings(hosts)
+
+	// A bridge reachable at several addresses is reported once
+	bridges := make([]discoveredBridge, 0, len(hosts))
+	byID := make(map[string]int)
+	for _, host := range hosts {
+		found := *byHost[host]
+		if i, exists := byID[found.ID]; exists && found.ID != "" {
+			for _, method := range found.Methods {
+				if !containsString(bridges[i].Methods, method) {
+					bridges[i].Methods = append(bridges[i].Methods, method)
+				}
+			}
+			continue
+		}
+		byID[found.ID] = len(bridges)
+		bridges = append(bridges, found)
+	}
+	return bridges
+}
+
+// discoverBridges runs the methods selected by opts and returns every bridge found
+func discoverBridges(opts discoveryOptions) []discoveredBridge {
+	return collectBridges(runDiscovery(discoveryMethods(opts), opts.timeout), opts.timeout)
+}
+
+// probeBridge reads the unauthenticated config of the bridge at host. It
+// fails if host does not look like a Hue bridge. The config holds no secrets,
+// so the certificate is not checked here; 'hue auth' checks it before pairing.
+func probeBridge(host string, timeout time.Duration) (*discoveredBridge, error) {
+	client := &http.Client{Timeout: timeout, Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	resp, err := client.Get(buildBridgeURL(host, "/api/config"))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var config struct {
+		Name       string `json:"name"`
+		BridgeID   string `json:"bridgeid"`
+		ModelID    string `json:"modelid"`
+		APIVersion string `json:"apiversion"`
+		SwVersion  string `json:"swversion"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&config); err != nil {
+		return nil, fmt.Errorf("not a Hue bridge: %v", err)
+	}
+	if config.BridgeID == "" {
+		return nil, fmt.Errorf("not a Hue bridge: no bridge ID in config")
+	}
+
+	return &discoveredBridge{
+		Host:       host,
+		ID:         strings.ToUpper(config.BridgeID),
+		Name:       config.Name,
+		ModelID:    config.ModelID,
+		APIVersion: config.APIVersion,
+		SwVersion:  config.SwVersion,
+	}, nil
+}
+
+// discoverMDNS sends a one-shot mDNS query for _hue._tcp services and
+// collects the answers until timeout
+func discoverMDNS(timeout time.Duration) ([]discoveryHit, error) {
+	service := dnsmessage.MustNewName(mdnsService)
+	query := dnsmessage.Message{
+		Questions: []dnsmessage.Question{{Name: service, Type: dnsmessage.TypePTR, Class: dnsmessage.ClassINET}},
+	}
+	packet, err := query.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	responses, err := multicastQuery(mdnsAddress, packet, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	var hits []discoveryHit
+	for _, response := range responses {
+		if hit, ok := parseMDNSResponse(response.data, response.from); ok {
+			hits = append(hits, hit)
+		}
+	}
+	return hits, nil
+}
+
+// parseMDNSResponse extracts a bridge address from an mDNS response. The
+// address comes from the A record of the advertised host, falling back to
+// the sender of the response.
+func parseMDNSResponse(data []byte, from *net.UDPAddr) (discoveryHit, bool) {
+	var msg dnsmessage.Message
+	if err := msg.Unpack(data); err != nil {
+		return discoveryHit{}, false
+	}
+
+	isHue := false
+	var target, id string
+	addresses := make(map[string]string)
+	records := append(msg.Answers, msg.Additionals...)
+	for _, record := range records {
+		name := strings.ToLower(record.Header.Name.String())
+		switch body := record.Body.(type) {
+		case *dnsmessage.PTRResource:
+			if name == mdnsService {
+				isHue = true
+			}
+		case *dnsmessage.SRVResource:
+			if strings.HasSuffix(name, mdnsService) {
+				isHue = true
+				target = strings.ToLower(body.Target.String())
+			}
+		case *dnsmessage.TXTResource:
+			for _, entry := range body.TXT {
+				if value, ok := strings.CutPrefix(entry, "bridgeid="); ok {
+					id = strings.ToUpper(value)
+				}
+			}
+		case *dnsmessage.AResource:
+			addresses[name] = net.IP(body.A[:]).String()
+		}
+	}
+	if !isHue {
+		return discoveryHit{}, false
+	}
+
+	host := addresses[target]
+	if host == "" {
+		host = from.IP.String()
+	}
+	return discoveryHit{host: host, id: id}, true
+}
+
+// discoverSSDP sends an SSDP M-SEARCH and keeps the responses of Hue bridges
+func discoverSSDP(timeout time.Duration) ([]discoveryHit, error) {
+	request := "M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: " + ssdpAddress + "\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		"MX: 2\r\n" +
+		"ST: ssdp:all\r\n\r\n"
+
+	responses, err := multicastQuery(ssdpAddress, []byte(request), timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	var hits []discoveryHit
+	seen := make(map[string]bool)
+	for _, response := range responses {
+		hit, ok := parseSSDPResponse(response.data, response.from)
+		if ok && !seen[hit.host] {
+			seen[hit.host] = true
+			hits = append(hits, hit)
+		}
+	}
+	return hits, nil
+}
+
+// parseSSDPResponse recognizes a bridge by its hue-bridgeid header or its
+// IpBridge server string
+func parseSSDPResponse(data []byte, from *net.UDPAddr) (discoveryHit, bool) {
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), nil)
+	if err != nil {
+		return discoveryHit{}, false
+	}
+	resp.Body.Close()
+
+	id := resp.Header.Get("hue-bridgeid")
+	if id == "" && !strings.Contains(resp.Header.Get("Server"), "IpBridge") {
+		return discoveryHit{}, false
+	}
+
+	host := from.IP.String()
+	if location, err := url.Parse(resp.Header.Get("Location")); err == nil && location.Hostname() != "" {
+		host = location.Hostname()
+	}
+	return discoveryHit{host: host, id: strings.ToUpper(id)}, true
+}
+
+// discoverCloud asks the Philips discovery service, which needs internet access
+func discoverCloud(timeout time.Duration) ([]discoveryHit, error) {
+	bridges, err := huego.DiscoverAll()
+	if err != nil {
+		return nil, err
+	}
+
+	hits := make([]discoveryHit, 0, len(bridges))
+	for _, b := range bridges {
+		hits = append(hits, discoveryHit{host: b.Host, id: strings.ToUpper(b.ID)})
+	}
+	return hits, nil
+}
+
+// discoverSubnet probes /api/config on every address of subnet, or of the
+// networks of the local interfaces when subnet is empty
+func discoverSubnet(subnet string, timeout time.Duration) ([]discoveryHit, error) {
+	var networks []*net.IPNet
+	if subnet != "" {
+		network, err := parseProbeSubnet(subnet)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	} else {
+		local, err := localNetworks()
+		if err != nil {
+			return nil, err
+		}
+		networks = local
+	}
+
+	addresses := make(chan string)
+	go func() {
+		defer close(addresses)
+		for _, network := range networks {
+			for _, ip := range subnetHosts(network) {
+				addresses <- ip
+			}
+		}
+	}()
+
+	var mutex sync.Mutex
+	var hits []discoveryHit
+	var wg sync.WaitGroup
+	for i := 0; i < probeWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for address := range addresses {
+				info, err := probeBridge(address, timeout)
+				if err != nil {
+					continue
+				}
+				mutex.Lock()
+				hits = append(hits, discoveryHit{host: address, id: info.ID})
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return hits, nil
+}
+
+// localNetworks returns the IPv4 networks of the interfaces that are up,
+// narrowed to a /24 around the interface address
+func localNetworks() ([]*net.IPNet, error) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var networks []*net.IPNet
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok || ipNet.IP.To4() == nil {
+				continue
+			}
+			if ones, _ := ipNet.Mask.Size(); ones < maxProbeSubnet {
+				mask := net.CIDRMask(maxProbeSubnet, 32)
+				ipNet = &net.IPNet{IP: ipNet.IP.Mask(mask), Mask: mask}
+			}
+			networks = append(networks, ipNet)
+		}
+	}
+	return networks, nil
+}
+
+// parseProbeSubnet parses the network given with --subnet. Networks larger
+// than a /24 are rejected rather than probed only in part.
+func parseProbeSubnet(subnet string) (*net.IPNet, error) {
+	_, network, err := net.ParseCIDR(subnet)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subnet '%s': %v", subnet, err)
+	}
+	if network.IP.To4() == nil {
+		return nil, fmt.Errorf("invalid subnet '%s': only IPv4 networks can be probed", subnet)
+	}
+	if ones, _ := network.Mask.Size(); ones < maxProbeSubnet {
+		return nil, fmt.Errorf("subnet '%s' is larger than a /%d; probe the /%d the bridge is in, e.g. %s/%d",
+			subnet, maxProbeSubnet, maxProbeSubnet, network.IP, maxProbeSubnet)
+	}
+	return network, nil
+}
+
+// subnetHosts lists the host addresses of an IPv4 network of at most a /24,
+// skipping the network and broadcast addresses. Larger networks have none.
+func subnetHosts(network *net.IPNet) []string {
+	base := network.IP.Mask(network.Mask).To4()
+	if base == nil {
+		return nil
+	}
+	ones, bits := network.Mask.Size()
+	if bits-ones > 32-maxProbeSubnet {
+		return nil
+	}
+	size := 1 << uint(bits-ones)
+
+	start := uint32(base[0])<<24 | uint32(base[1])<<16 | uint32(base[2])<<8 | uint32(base[3])
+	var hosts []string
+	for i := 0; i < size; i++ {
+		if size > 2 && (i == 0 || i == size-1) {
+			continue
+		}
+		n := start + uint32(i)
+		hosts = append(hosts, net.IPv4(byte(n>>24), byte(n>>16), byte(n>>8), byte(n)).String())
+	}
+	return hosts
+}
+
+// multicastResponse is a datagram received in reply to a multicast query
+type multicastResponse struct {
+	data []byte
+	from *net.UDPAddr
+}
+
+// multicastQuery sends packet to a multicast group from an ephemeral port
+// and collects the unicast replies until timeout
+func multicastQuery(group string, packet []byte, timeout time.Duration) ([]multicastResponse, error) {
+	groupAddr, err := net.ResolveUDPAddr("udp4", group)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4zero})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.WriteToUDP(packet, groupAddr); err != nil {
+		return nil, err
+	}
+
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	var responses []multicastResponse
+	buf := make([]byte, 9000)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			// The read deadline ends the collection
+			break
+		}
+		data := make([]byte, n)
+		copy(data, buf[:n])
+		responses = append(responses, multicastResponse{data: data, from: from})
+	}
+	return responses, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"net"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// mdnsPacket builds an mDNS response advertising a bridge called name
+func mdnsPacket(t *testing.T, service, name string, txt []string, address [4]byte) []byte {
+	t.Helper()
+	instance := dnsmessage.MustNewName(name + "." + service)
+	host := dnsmessage.MustNewName(name + ".local.")
+	header := func(name dnsmessage.Name, recordType dnsmessage.Type) dnsmessage.ResourceHeader {
+		return dnsmessage.ResourceHeader{Name: name, Type: recordType, Class: dnsmessage.ClassINET, TTL: 120}
+	}
+
+	msg := dnsmessage.Message{
+		Header: dnsmessage.Header{Response: true, Authoritative: true},
+		Answers: []dnsmessage.Resource{
+			{Header: header(dnsmessage.MustNewName(service), dnsmessage.TypePTR), Body: &dnsmessage.PTRResource{PTR: instance}},
+		},
+		Additionals: []dnsmessage.Resource{
+			{Header: header(instance, dnsmessage.TypeSRV), Body: &dnsmessage.SRVResource{Target: host, Port: 443}},
+			{Header: header(instance, dnsmessage.TypeTXT), Body: &dnsmessage.TXTResource{TXT: txt}},
+			{Header: header(host, dnsmessage.TypeA), Body: &dnsmessage.AResource{A: address}},
+		},
+	}
+	packet, err := msg.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return packet
+}
+
+func TestParseMDNSResponse(t *testing.T) {
+	from := &net.UDPAddr{IP: net.IPv4(192, 168, 1, 50), Port: 5353}
+	tests := []struct {
+		name   string
+		packet []byte
+		want   discoveryHit
+		ok     bool
+	}{
+		{
+			name:   "bridge with A record",
+			packet: mdnsPacket(t, mdnsService, "Philips-Hue", []string{"bridgeid=001788fffe000000", "modelid=BSB002"}, [4]byte{192, 168, 1, 2}),
+			want:   discoveryHit{host: "192.168.1.2", id: "001788FFFE000000"},
+			ok:     true,
+		},
+		{
+			name:   "other service",
+			packet: mdnsPacket(t, "_ipp._tcp.local.", "Printer", nil, [4]byte{192, 168, 1, 3}),
+		},
+		{
+			name:   "not DNS",
+			packet: []byte("hello"),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := parseMDNSResponse(test.packet, from)
+			if ok != test.ok || got != test.want {
+				t.Errorf("parseMDNSResponse = %+v, %t; want %+v, %t", got, ok, test.want, test.ok)
+			}
+		})
+	}
+}
+
+func TestParseMDNSResponseWithoutAddress(t *testing.T) {
+	msg := dnsmessage.Message{
+		Header: dnsmessage.Header{Response: true},
+		Answers: []dnsmessage.Resource{{
+			Header: dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName(mdnsService), Type: dnsmessage.TypePTR, Class: dnsmessage.ClassINET},
+			Body:   &dnsmessage.PTRResource{PTR: dnsmessage.MustNewName("Philips-Hue." + mdnsService)},
+		}},
+	}
+	packet, err := msg.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without an A record the sender of the response is the bridge
+	from := &net.UDPAddr{IP: net.IPv4(192, 168, 1, 50), Port: 5353}
+	got, ok := parseMDNSResponse(packet, from)
+	if !ok || got.host != "192.168.1.50" {
+		t.Errorf("parseMDNSResponse = %+v, %t; want host 192.168.1.50", got, ok)
+	}
+}
+
+func TestParseSSDPResponse(t *testing.T) {
+	from := &net.UDPAddr{IP: net.IPv4(192, 168, 1, 50), Port: 1900}
+	tests := []struct {
+		name     string
+		response string
+		want     discoveryHit
+		ok       bool
+	}{
+		{
+			name: "bridge id header",
+			response: "HTTP/1.1 200 OK\r\n" +
+				"CACHE-CONTROL: max-age=100\r\n" +
+				"LOCATION: http://192.168.1.2:80/description.xml\r\n" +
+				"SERVER: Hue/1.0 UPnP/1.0 IpBridge/1.60.0\r\n" +
+				"hue-bridgeid: 001788fffe000000\r\n" +
+				"ST: upnp:rootdevice\r\n\r\n",
+			want: discoveryHit{host: "192.168.1.2", id: "001788FFFE000000"},
+			ok:   true,
+		},
+		{
+			name: "older bridge without id",
+			response: "HTTP/1.1 200 OK\r\n" +
+				"SERVER: FreeRTOS/7.4.2 UPnP/1.0 IpBridge/1.10.0\r\n" +
+				"ST: upnp:rootdevice\r\n\r\n",
+			want: discoveryHit{host: "192.168.1.50"},
+			ok:   true,
+		},
+		{
+			name: "other device",
+			response: "HTTP/1.1 200 OK\r\n" +
+				"LOCATION: http://192.168.1.9:1400/xml/device_description.xml\r\n" +
+				"SERVER: Linux UPnP/1.0 Sonos/70.3\r\n\r\n",
+		},
+		{
+			name:     "not HTTP",
+			response: "garbage",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := parseSSDPResponse([]byte(test.response), from)
+			if ok != test.ok || got != test.want {
+				t.Errorf("parseSSDPResponse = %+v, %t; want %+v, %t", got, ok, test.want, test.ok)
+			}
+		})
+	}
+}
+
+func TestSubnetHosts(t *testing.T) {
+	tests := []struct {
+		subnet string
+		count  int
+		first  string
+		last   string
+	}{
+		{subnet: "192.168.1.0/24", count: 254, first: "192.168.1.1", last: "192.168.1.254"},
+		{subnet: "192.168.1.77/24", count: 254, first: "192.168.1.1", last: "192.168.1.254"},
+		{subnet: "10.0.0.8/29", count: 6, first: "10.0.0.9", last: "10.0.0.14"},
+		{subnet: "10.0.0.8/31", count: 2, first: "10.0.0.8", last: "10.0.0.9"},
+		{subnet: "10.0.0.8/32", count: 1, first: "10.0.0.8", last: "10.0.0.8"},
+		{subnet: "10.0.0.0/16"},
+		{subnet: "10.0.0.0/8"},
+	}
+	for _, test := range tests {
+		t.Run(test.subnet, func(t *testing.T) {
+			_, network, err := net.ParseCIDR(test.subnet)
+			if err != nil {
+				t.Fatal(err)
+			}
+			hosts := subnetHosts(network)
+			if test.count == 0 {
+				if len(hosts) != 0 {
+					t.Errorf("subnetHosts(%s) = %d hosts, want none", test.subnet, len(hosts))
+				}
+				return
+			}
+			if len(hosts) != test.count || hosts[0] != test.first || hosts[len(hosts)-1] != test.last {
+				t.Errorf("subnetHosts(%s) = %d hosts from %v, want %d from %s to %s", test.subnet, len(hosts), hosts[:min(len(hosts), 2)], test.count, test.first, test.last)
+			}
+		})
+	}
+}
+
+func TestParseProbeSubnet(t *testing.T) {
+	tests := []struct {
+		subnet string
+		want   string
+	}{
+		{subnet: "192.168.1.0/24", want: "192.168.1.0/24"},
+		{subnet: "192.168.1.77/24", want: "192.168.1.0/24"},
+		{subnet: "10.0.0.8/29", want: "10.0.0.8/29"},
+		{subnet: "10.0.0.0/23"},
+		{subnet: "10.0.0.0/16"},
+		{subnet: "fd00::/120"},
+		{subnet: "192.168.1.1"},
+	}
+	for _, test := range tests {
+		t.Run(test.subnet, func(t *testing.T) {
+			network, err := parseProbeSubnet(test.subnet)
+			got := ""
+			if err == nil {
+				got = network.String()
+			}
+			if got != test.want {
+				t.Errorf("parseProbeSubnet(%s) = %q (%v), want %q", test.subnet, got, err, test.want)
+			}
+		})
+	}
+}
@@ -1,41 +1,37 @@
 package main
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
-	"net/http"
 	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
-	"github.com/amimof/huego"
 	"github.com/spf13/cobra"
 )
 
 // Entertainment area represents a configured entertainment area
 type EntertainmentArea struct {
-	ID        string              `json:"id,omitempty"`
-	Name      string              `json:"name"`
-	Type      string              `json:"type"` // "entertainment"
-	Lights    []string            `json:"lights"`
-	Locations map[string]Location `json:"locations,omitempty"` // light ID -> location
-	Stream    *StreamConfig       `json:"stream,omitempty"`
+	ID        string              `json:"id,omitempty" yaml:"id,omitempty"`
+	Name      string              `json:"name" yaml:"name"`
+	Type      string              `json:"type" yaml:"type"` // "entertainment"
+	Lights    []string            `json:"lights" yaml:"lights"`
+	Locations map[string]Location `json:"locations,omitempty" yaml:"locations,omitempty"` // light ID -> location
+	Stream    *StreamConfig       `json:"stream,omitempty" yaml:"stream,omitempty"`
 }
 
 type Location struct {
-	X float32 `json:"x"` // -1.0 to 1.0
-	Y float32 `json:"y"` // -1.0 to 1.0
-	Z float32 `json:"z"` // -1.0 to 1.0
+	X float32 `json:"x" yaml:"x"` // -1.0 to 1.0
+	Y float32 `json:"y" yaml:"y"` // -1.0 to 1.0
+	Z float32 `json:"z" yaml:"z"` // -1.0 to 1.0
 }
 
 type StreamConfig struct {
-	ProxyMode string `json:"proxymode"` // "auto" or "manual"
-	ProxyNode string `json:"proxynode,omitempty"`
-	Active    bool   `json:"active"`
-	Owner     string `json:"owner,omitempty"`
+	ProxyMode string `json:"proxymode" yaml:"proxymode"` // "auto" or "manual"
+	ProxyNode string `json:"proxynode,omitempty" yaml:"proxynode,omitempty"`
+	Active    bool   `json:"active" yaml:"active"`
+	Owner     string `json:"owner,omitempty" yaml:"owner,omitempty"`
 }
 
 // EntertainmentConfig stores entertainment area configurations
@@ -45,11 +41,6 @@ type EntertainmentConfig struct {
 
 var entertainmentFile string
 
-func init() {
-	homeDir, _ := os.UserHomeDir()
-	entertainmentFile = filepath.Join(homeDir, ".hue-entertainment.json")
-}
-
 // Entertainment commands
 var entertainCmd = &cobra.Command{
 	Use:   "entertain",
@@ -84,15 +75,14 @@ Examples:
   hue entertain area create "Gaming Setup" 1 2 3
   hue entertain area create "TV Backlight" 4 5 6 7`,
 	Args: cobra.MinimumNArgs(2),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		areaName := args[0]
 		lightIDs := args[1:]
 
 		// Validate lights exist
 		allLights, err := bridge.GetLights()
 		if err != nil {
-			fmt.Printf("Error getting lights: %v\n", err)
-			return
+			return fmt.Errorf("failed to get lights: %w", err)
 		}
 
 		validLightIDs := []string{}
@@ -111,15 +101,13 @@ Examples:
 		}
 
 		if len(validLightIDs) == 0 {
-			fmt.Println("No valid lights found")
-			return
+			return notFoundError("no valid lights found")
 		}
 
 		// Create entertainment configuration on the bridge
 		groupID, err := createEntertainmentGroup(areaName, validLightIDs)
 		if err != nil {
-			fmt.Printf("Error creating entertainment area: %v\n", err)
-			return
+			return fmt.Errorf("failed to create entertainment area: %w", err)
 		}
 
 		// Save local configuration
@@ -136,6 +124,7 @@ Examples:
 
 		fmt.Printf("Entertainment area '%s' created (ID: %s) with %d lights\n", areaName, groupID, len(validLightIDs))
 		fmt.Println("Use 'hue entertain stream start' to begin streaming")
+		return nil
 	},
 }
 
@@ -143,14 +132,13 @@ var entertainAreaDeleteCmd = &cobra.Command{
 	Use:   "delete [area-name-or-id]",
 	Short: "Delete an entertainment area",
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		areaIdentifier := args[0]
 
 		// Find area
 		config, err := loadEntertainmentConfig()
 		if err != nil {
-			fmt.Printf("Error loading configuration: %v\n", err)
-			return
+			return fmt.Errorf("failed to load configuration: %w", err)
 		}
 
 		var areaID string
@@ -162,14 +150,12 @@ var entertainAreaDeleteCmd = &cobra.Command{
 		}
 
 		if areaID == "" {
-			fmt.Printf("Entertainment area '%s' not found\n", areaIdentifier)
-			return
+			return notFoundError("entertainment area '%s' not found", areaIdentifier)
 		}
 
 		// Delete from bridge
 		if err := deleteEntertainmentGroup(areaID); err != nil {
-			fmt.Printf("Error deleting from bridge: %v\n", err)
-			return
+			return fmt.Errorf("failed to delete from bridge: %w", err)
 		}
 
 		// Remove from local config
@@ -178,42 +164,37 @@ var entertainAreaDeleteCmd = &cobra.Command{
 		}
 
 		fmt.Printf("Entertainment area deleted\n")
+		return nil
 	},
 }
 
 var entertainAreaListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List entertainment areas",
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		// Fetch from bridge
 		areas, err := getEntertainmentAreasFromBridge()
 		if err != nil {
-			fmt.Printf("Error fetching areas from bridge: %v\n", err)
-			return
-		}
-
-		if len(areas) == 0 {
-			fmt.Println("No entertainment areas configured on bridge")
-			fmt.Println("Create one with: hue entertain area create [name] [light-ids...]")
-			return
+			return fmt.Errorf("failed to fetch areas from bridge: %w", err)
 		}
 
-		// Sync to local config
-		config := &EntertainmentConfig{Areas: areas}
-		if err := saveEntertainmentConfig(*config); err != nil {
-			fmt.Printf("Warning: Failed to save local config: %v\n", err)
+		if len(areas) > 0 {
+			if err := syncEntertainmentAreas(areas); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: Failed to save local config: %v\n", err)
+			}
 		}
 
-		fmt.Println("Entertainment Areas:")
-		for _, area := range areas {
-			fmt.Printf("  %s (ID: %s)\n", area.Name, area.ID)
-			fmt.Printf("    Lights: %s\n", strings.Join(area.Lights, ", "))
-			if area.Stream != nil && area.Stream.Active {
-				fmt.Printf("    Status: STREAMING (Owner: %s)\n", area.Stream.Owner)
-			} else {
-				fmt.Printf("    Status: Inactive\n")
+		return printOutput(areasDocument{Areas: areas}, func(w io.Writer) {
+			if len(areas) == 0 {
+				fmt.Fprintln(w, "No entertainment areas configured on bridge")
+				fmt.Fprintln(w, "Create one with: hue entertain area create [name] [light-ids...]")
+				return
 			}
-		}
+			fmt.Fprintln(w, "ID\tName\tLights\tStatus")
+			for _, area := range areas {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", area.ID, area.Name, strings.Join(area.Lights, ", "), streamStatus(area))
+			}
+		})
 	},
 }
 
@@ -244,7 +225,7 @@ Example:
   hue entertain stream server "Lucas Room"
   hue entertain stream server "Lucas Room" 9000`,
 	Args: cobra.RangeArgs(1, 2),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		areaIdentifier := args[0]
 		port := 8080
 
@@ -255,8 +236,7 @@ Example:
 		// Find the entertainment area
 		areas, err := getEntertainmentAreasFromBridge()
 		if err != nil {
-			fmt.Printf("Error loading entertainment areas: %v\n", err)
-			return
+			return fmt.Errorf("failed to load entertainment areas: %w", err)
 		}
 
 		var area *EntertainmentArea
@@ -268,17 +248,16 @@ Example:
 		}
 
 		if area == nil {
-			fmt.Printf("Entertainment area '%s' not found\n", areaIdentifier)
 			fmt.Println("Use 'hue entertain list' to see available areas")
-			return
+			return notFoundError("entertainment area '%s' not found", areaIdentifier)
 		}
 
 		fmt.Printf("Starting WebSocket server for '%s'...\n", area.Name)
 
 		if err := StartWebSocketServer(area, port); err != nil {
-			fmt.Printf("Error starting server: %v\n", err)
-			return
+			return fmt.Errorf("failed to start server: %w", err)
 		}
+		return nil
 	},
 }
 
@@ -289,7 +268,7 @@ var entertainStreamStartCmd = &cobra.Command{
 	
 Note: Only one application can stream to an area at a time.`,
 	Args: cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		areaIdentifier := args[0]
 
 		// Try to find area locally first, then fetch from bridge
@@ -310,8 +289,7 @@ Note: Only one application can stream to an area at a time.`,
 		if area == nil {
 			areas, err := getEntertainmentAreasFromBridge()
 			if err != nil {
-				fmt.Printf("Error fetching areas from bridge: %v\n", err)
-				return
+				return fmt.Errorf("failed to fetch areas from bridge: %w", err)
 			}
 
 			for i, a := range areas {
@@ -323,22 +301,21 @@ Note: Only one application can stream to an area at a time.`,
 		}
 
 		if area == nil {
-			fmt.Printf("Entertainment area '%s' not found\n", areaIdentifier)
 			fmt.Println("Use 'hue entertain list' to see available areas")
-			return
+			return notFoundError("entertainment area '%s' not found", areaIdentifier)
 		}
 
 		fmt.Printf("Starting streaming session for '%s'...\n", area.Name)
 
 		// Activate streaming on the bridge
 		if err := activateStreaming(area.ID); err != nil {
-			fmt.Printf("Error activating streaming: %v\n", err)
-			return
+			return fmt.Errorf("failed to activate streaming: %w", err)
 		}
 
 		fmt.Printf("Streaming activated for area '%s'\n", area.Name)
 		fmt.Println("\nStream is active. Use 'hue entertain stream effect' to send light data.")
 		fmt.Println("Streaming will auto-deactivate after 10 seconds of inactivity.")
+		return nil
 	},
 }
 
@@ -357,7 +334,7 @@ Examples:
   hue entertain stream effect "Gaming Setup" rainbow
   hue entertain stream effect "TV Backlight" wave`,
 	Args: cobra.ExactArgs(2),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		areaIdentifier := args[0]
 		effectName := args[1]
 
@@ -379,8 +356,7 @@ Examples:
 		if area == nil {
 			areas, err := getEntertainmentAreasFromBridge()
 			if err != nil {
-				fmt.Printf("Error fetching areas from bridge: %v\n", err)
-				return
+				return fmt.Errorf("failed to fetch areas from bridge: %w", err)
 			}
 
 			for i, a := range areas {
@@ -392,9 +368,8 @@ Examples:
 		}
 
 		if area == nil {
-			fmt.Printf("Entertainment area '%s' not found\n", areaIdentifier)
 			fmt.Println("Use 'hue entertain list' to see available areas")
-			return
+			return notFoundError("entertainment area '%s' not found", areaIdentifier)
 		}
 
 		duration, _ := cmd.Flags().GetInt("duration")
@@ -403,11 +378,11 @@ Examples:
 
 		// Start streaming
 		if err := streamEffect(area, effectName, duration); err != nil {
-			fmt.Printf("Error streaming effect: %v\n", err)
-			return
+			return fmt.Errorf("failed to stream effect: %w", err)
 		}
 
 		fmt.Println("Effect completed")
+		return nil
 	},
 }
 
@@ -418,262 +393,98 @@ func init() {
 var entertainListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all entertainment areas",
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		// Fetch from bridge
 		areas, err := getEntertainmentAreasFromBridge()
 		if err != nil {
-			fmt.Printf("Error fetching areas from bridge: %v\n", err)
-			return
-		}
-
-		if len(areas) == 0 {
-			fmt.Println("No entertainment areas found on bridge")
-			fmt.Println("Create one with: hue entertain area create [name] [light-ids...]")
-			return
+			return fmt.Errorf("failed to fetch areas from bridge: %w", err)
 		}
 
-		// Sync to local config
-		config := &EntertainmentConfig{Areas: areas}
-		if err := saveEntertainmentConfig(*config); err != nil {
-			fmt.Printf("Warning: Failed to save local config: %v\n", err)
+		if len(areas) > 0 {
+			if err := syncEntertainmentAreas(areas); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: Failed to save local config: %v\n", err)
+			}
 		}
 
-		fmt.Println("Entertainment areas:")
-		for _, area := range areas {
-			fmt.Printf("  %s (ID: %s, Lights: %d)\n", area.Name, area.ID, len(area.Lights))
-			if area.Stream != nil && area.Stream.Active {
-				fmt.Printf("    Status: STREAMING (Owner: %s)\n", area.Stream.Owner)
+		return printOutput(areasDocument{Areas: areas}, func(w io.Writer) {
+			if len(areas) == 0 {
+				fmt.Fprintln(w, "No entertainment areas found on bridge")
+				fmt.Fprintln(w, "Create one with: hue entertain area create [name] [light-ids...]")
+				return
 			}
-		}
+			fmt.Fprintln(w, "ID\tName\tLights\tStatus")
+			for _, area := range areas {
+				fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", area.ID, area.Name, len(area.Lights), streamStatus(area))
+			}
+		})
 	},
 }
 
 // Helper functions
 
-// buildBridgeURL constructs a URL for the bridge API, handling http:// prefix
-func buildBridgeURL(host, path string) string {
-	if strings.HasPrefix(host, "http://") || strings.HasPrefix(host, "https://") {
-		return fmt.Sprintf("%s%s", host, path)
+// streamStatus describes the streaming state of an area for table output
+func streamStatus(area EntertainmentArea) string {
+	if area.Stream != nil && area.Stream.Active {
+		return fmt.Sprintf("STREAMING (Owner: %s)", area.Stream.Owner)
 	}
-	return fmt.Sprintf("http://%s%s", host, path)
+	return "Inactive"
 }
 
 func createEntertainmentGroup(name string, lightIDs []string) (string, error) {
-	bridgeConfig, err := loadBridgeConfig()
-	if err != nil {
-		return "", err
-	}
-
-	// Create group payload
-	payload := map[string]interface{}{
-		"name":   name,
-		"type":   "Entertainment",
-		"lights": lightIDs,
-		"class":  "TV", // Default class
-	}
-
-	data, _ := json.Marshal(payload)
-	url := buildBridgeURL(bridgeConfig.Host, fmt.Sprintf("/api/%s/groups", bridgeConfig.Username))
-
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(data))
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-	var result []map[string]interface{}
-	if err := json.Unmarshal(body, &result); err != nil {
-		return "", err
-	}
-
-	if len(result) > 0 {
-		if success, ok := result[0]["success"].(map[string]interface{}); ok {
-			if id, ok := success["id"].(string); ok {
-				return id, nil
-			}
-		}
-		if errMsg, ok := result[0]["error"].(map[string]interface{}); ok {
-			return "", fmt.Errorf("%v", errMsg["description"])
-		}
-	}
-
-	return "", fmt.Errorf("unexpected response: %s", string(body))
+	return bridge.CreateGroup(BridgeGroup{
+		Name:   name,
+		Type:   "Entertainment",
+		Lights: lightIDs,
+		Class:  "TV", // Default class
+	})
 }
 
 func deleteEntertainmentGroup(groupID string) error {
-	bridgeConfig, err := loadBridgeConfig()
-	if err != nil {
-		return err
-	}
-
-	url := buildBridgeURL(bridgeConfig.Host, fmt.Sprintf("/api/%s/groups/%s", bridgeConfig.Username, groupID))
-	req, _ := http.NewRequest("DELETE", url, nil)
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	return nil
+	return bridge.DeleteGroup(groupID)
 }
 
 func activateStreaming(groupID string) error {
-	bridgeConfig, err := loadBridgeConfig()
-	if err != nil {
-		return err
-	}
-
-	payload := map[string]interface{}{
-		"stream": map[string]bool{
-			"active": true,
-		},
+	err := bridge.SetStreamActive(groupID, true)
+	if bridgeErr, ok := err.(*BridgeError); ok && bridgeErr.Type == bridgeErrUnauthorized {
+		// Unauthorized user - needs link button press for streaming
+		fmt.Println("⚠️  Streaming requires additional authorization")
+		fmt.Println("\nTo enable streaming:")
+		fmt.Println("1. Press the link button on your Hue bridge")
+		fmt.Println("2. Run this command again within 30 seconds")
+		fmt.Println("\nNote: Standard API access is different from streaming API access.")
+		fmt.Println("This is a one-time setup for Entertainment streaming.")
+		return notAuthorizedError("unauthorized - link button press required")
 	}
-
-	data, _ := json.Marshal(payload)
-	url := buildBridgeURL(bridgeConfig.Host, fmt.Sprintf("/api/%s/groups/%s", bridgeConfig.Username, groupID))
-
-	req, _ := http.NewRequest("PUT", url, bytes.NewBuffer(data))
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-	var result []map[string]interface{}
-	if err := json.Unmarshal(body, &result); err != nil {
-		return err
-	}
-
-	// Check for errors in response
-	if len(result) > 0 {
-		if errMsg, ok := result[0]["error"].(map[string]interface{}); ok {
-			errorType, _ := errMsg["type"].(float64)
-			description := errMsg["description"]
-
-			// Error type 1 = unauthorized user - needs link button press for streaming
-			if errorType == 1 {
-				fmt.Println("⚠️  Streaming requires additional authorization")
-				fmt.Println("\nTo enable streaming:")
-				fmt.Println("1. Press the link button on your Hue bridge")
-				fmt.Println("2. Run this command again within 30 seconds")
-				fmt.Println("\nNote: Standard API access is different from streaming API access.")
-				fmt.Println("This is a one-time setup for Entertainment streaming.")
-				return fmt.Errorf("unauthorized - link button press required")
-			}
-			return fmt.Errorf("%v", description)
-		}
-	}
-
-	return nil
+	return err
 }
 
 func getEntertainmentAreasFromBridge() ([]EntertainmentArea, error) {
-	bridgeConfig, err := loadBridgeConfig()
+	groups, err := bridge.GetGroups()
 	if err != nil {
 		return nil, err
 	}
 
-	// Get all groups from the bridge
-	url := buildBridgeURL(bridgeConfig.Host, fmt.Sprintf("/api/%s/groups", bridgeConfig.Username))
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get groups: %v", err)
-	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-	var groups map[string]interface{}
-	if err := json.Unmarshal(body, &groups); err != nil {
-		return nil, err
-	}
-
-	var areas []EntertainmentArea
-	for groupID, groupData := range groups {
-		groupMap, ok := groupData.(map[string]interface{})
-		if !ok {
-			continue
-		}
-
+	areas := []EntertainmentArea{}
+	for _, group := range groups {
 		// Check if this is an entertainment group
-		groupType, _ := groupMap["type"].(string)
-		if groupType != "Entertainment" {
+		if group.Type != "Entertainment" {
 			continue
 		}
 
-		// Extract group information
-		name, _ := groupMap["name"].(string)
-
-		// Extract lights array
-		var lightIDs []string
-		if lightsData, ok := groupMap["lights"].([]interface{}); ok {
-			for _, lightID := range lightsData {
-				if id, ok := lightID.(string); ok {
-					lightIDs = append(lightIDs, id)
-				}
-			}
-		}
-
-		// Extract stream configuration if present
-		var streamConfig *StreamConfig
-		if streamData, ok := groupMap["stream"].(map[string]interface{}); ok {
-			active, _ := streamData["active"].(bool)
-			owner, _ := streamData["owner"].(string)
-			proxyMode, _ := streamData["proxymode"].(string)
-
-			streamConfig = &StreamConfig{
-				Active:    active,
-				Owner:     owner,
-				ProxyMode: proxyMode,
-			}
-		}
-
-		area := EntertainmentArea{
-			ID:     groupID,
-			Name:   name,
+		areas = append(areas, EntertainmentArea{
+			ID:     group.ID,
+			Name:   group.Name,
 			Type:   "entertainment",
-			Lights: lightIDs,
-			Stream: streamConfig,
-		}
-
-		areas = append(areas, area)
+			Lights: group.Lights,
+			Stream: group.Stream,
+		})
 	}
 
 	return areas, nil
 }
 
 func deactivateStreaming(groupID string) error {
-	bridgeConfig, err := loadBridgeConfig()
-	if err != nil {
-		return err
-	}
-
-	payload := map[string]interface{}{
-		"stream": map[string]bool{
-			"active": false,
-		},
-	}
-
-	data, _ := json.Marshal(payload)
-	url := buildBridgeURL(bridgeConfig.Host, fmt.Sprintf("/api/%s/groups/%s", bridgeConfig.Username, groupID))
-
-	req, _ := http.NewRequest("PUT", url, bytes.NewBuffer(data))
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	return nil
+	return bridge.SetStreamActive(groupID, false)
 }
 
 func streamEffect(area *EntertainmentArea, effectName string, durationSec int) error {
@@ -721,6 +532,15 @@ func streamEffectHTTP(area *EntertainmentArea, effectName string, durationSec in
 	}
 }
 
+// sendToArea applies the same state to every light of an area. The lights
+// are addressed one by one: group requests are limited to one per second,
+// far slower than an effect frame.
+func sendToArea(lights []Light, state stateBuilder) {
+	for _, light := range lights {
+		state.post(light)
+	}
+}
+
 func runRainbowEffect(area *EntertainmentArea, durationSec int) error {
 	lights, err := bridge.GetLights()
 	if err != nil {
@@ -728,7 +548,7 @@ func runRainbowEffect(area *EntertainmentArea, durationSec int) error {
 	}
 
 	// Filter to area lights
-	var areaLights []huego.Light
+	var areaLights []Light
 	for _, light := range lights {
 		for _, idStr := range area.Lights {
 			if fmt.Sprintf("%d", light.ID) == idStr {
@@ -746,11 +566,7 @@ func runRainbowEffect(area *EntertainmentArea, durationSec int) error {
 	for time.Since(startTime) < time.Duration(durationSec)*time.Second {
 		<-ticker.C
 
-		for _, light := range areaLights {
-			light.Hue(uint16(hue))
-			light.Sat(254)
-			light.Bri(254)
-		}
+		sendToArea(areaLights, newState().On(true).Hue(uint16(hue)).Sat(254).Bri(254))
 
 		hue = (hue + 500) % 65535
 	}
@@ -764,7 +580,7 @@ func runPulseEffect(area *EntertainmentArea, durationSec int) error {
 		return err
 	}
 
-	var areaLights []huego.Light
+	var areaLights []Light
 	for _, light := range lights {
 		for _, idStr := range area.Lights {
 			if fmt.Sprintf("%d", light.ID) == idStr {
@@ -787,9 +603,7 @@ func runPulseEffect(area *EntertainmentArea, durationSec int) error {
 			brightness = uint8((1.0 + float64(100-step%100)/50.0) * 127)
 		}
 
-		for _, light := range areaLights {
-			light.Bri(brightness)
-		}
+		sendToArea(areaLights, newState().On(true).Bri(brightness))
 
 		step++
 	}
@@ -803,7 +617,7 @@ func runWaveEffect(area *EntertainmentArea, durationSec int) error {
 		return err
 	}
 
-	var areaLights []huego.Light
+	var areaLights []Light
 	for _, light := range lights {
 		for _, idStr := range area.Lights {
 			if fmt.Sprintf("%d", light.ID) == idStr {
@@ -823,9 +637,7 @@ func runWaveEffect(area *EntertainmentArea, durationSec int) error {
 
 		for i, light := range areaLights {
 			hue := uint16((step*1000 + i*10000) % 65535)
-			light.Hue(hue)
-			light.Sat(254)
-			light.Bri(254)
+			newState().On(true).Hue(hue).Sat(254).Bri(254).post(light)
 		}
 
 		step++
@@ -840,7 +652,7 @@ func runRandomEffect(area *EntertainmentArea, durationSec int) error {
 		return err
 	}
 
-	var areaLights []huego.Light
+	var areaLights []Light
 	for _, light := range lights {
 		for _, idStr := range area.Lights {
 			if fmt.Sprintf("%d", light.ID) == idStr {
@@ -859,9 +671,7 @@ func runRandomEffect(area *EntertainmentArea, durationSec int) error {
 
 		for _, light := range areaLights {
 			hue := uint16(time.Now().UnixNano() % 65535)
-			light.Hue(hue)
-			light.Sat(254)
-			light.Bri(254)
+			newState().On(true).Hue(hue).Sat(254).Bri(254).post(light)
 		}
 	}
 
@@ -885,10 +695,48 @@ func saveEntertainmentConfig(config EntertainmentConfig) error {
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(entertainmentFile, data, 0600)
+	return writeConfigFile(entertainmentFile, data)
+}
+
+// syncEntertainmentAreas replaces the local entertainment config with the
+// areas on the bridge. The bridge does not report light locations, so those
+// set locally are kept for the lights still in their area.
+func syncEntertainmentAreas(areas []EntertainmentArea) error {
+	unlock, err := lockConfig()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	local, err := loadEntertainmentConfig()
+	if err != nil {
+		return err
+	}
+	for i := range areas {
+		for _, existing := range local.Areas {
+			if existing.ID != areas[i].ID || len(existing.Locations) == 0 {
+				continue
+			}
+			for _, light := range areas[i].Lights {
+				if location, ok := existing.Locations[light]; ok {
+					if areas[i].Locations == nil {
+						areas[i].Locations = map[string]Location{}
+					}
+					areas[i].Locations[light] = location
+				}
+			}
+		}
+	}
+	return saveEntertainmentConfig(EntertainmentConfig{Areas: areas})
 }
 
 func saveEntertainmentArea(area EntertainmentArea) error {
+	unlock, err := lockConfig()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	config, err := loadEntertainmentConfig()
 	if err != nil {
 		return err
@@ -912,6 +760,12 @@ func saveEntertainmentArea(area EntertainmentArea) error {
 }
 
 func removeEntertainmentArea(identifier string) error {
+	unlock, err := lockConfig()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	config, err := loadEntertainmentConfig()
 	if err != nil {
 		return err
@@ -7,12 +7,17 @@ import (
 	"encoding/hex"
 	"fmt"
 	"net"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/pion/dtls/v2"
 )
 
+// entertainmentStreamPort is the UDP port bridges take Entertainment
+// streams on. It cannot be configured on the bridge.
+const entertainmentStreamPort = 2100
+
 // RGB represents an RGB color
 type RGB struct {
 	R, G, B uint8
@@ -53,8 +58,12 @@ func createDTLSStreamConnection(area *EntertainmentArea) (*DTLSStream, error) {
 	host = strings.TrimPrefix(host, "http://")
 	host = strings.TrimPrefix(host, "https://")
 
-	// Entertainment streaming port is 2100
-	addr := fmt.Sprintf("%s:2100", host)
+	// Drop the REST API port, e.g. when talking to 'hue mock-bridge'
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	addr := net.JoinHostPort(host, strconv.Itoa(entertainmentStreamPort))
 
 	// Resolve the address
 	udpAddr, err := net.ResolveUDPAddr("udp", addr)
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+// fakeGroupByName returns the bridge group called name, or nil
+func fakeGroupByName(t *testing.T, fake *fakeBridge, name string) *BridgeGroup {
+	t.Helper()
+	groups, err := fake.getGroups("test")
+	if err != nil {
+		t.Fatalf("getGroups: %v", err)
+	}
+	for _, group := range groups {
+		if group.Name == name {
+			return &group
+		}
+	}
+	return nil
+}
+
+func TestEntertainAreaCreate(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		wantLights []string
+		code       int
+	}{
+		{name: "lights", args: []string{"Desk", "1", "3"}, wantLights: []string{"1", "3"}},
+		{name: "unknown lights are skipped", args: []string{"Desk", "2", "99"}, wantLights: []string{"2"}},
+		{name: "no valid lights", args: []string{"Desk", "99"}, code: exitNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := useFakeBridge(t)
+			checkExitCode(t, entertainAreaCreateCmd.RunE(entertainAreaCreateCmd, test.args), test.code)
+
+			group := fakeGroupByName(t, fake, "Desk")
+			if test.code != exitOK {
+				if group != nil {
+					t.Errorf("area was created on the bridge: %+v", group)
+				}
+				return
+			}
+			if group == nil || group.Type != "Entertainment" || !reflect.DeepEqual(group.Lights, test.wantLights) {
+				t.Fatalf("bridge group = %+v, want an entertainment group with lights %v", group, test.wantLights)
+			}
+
+			config, err := loadEntertainmentConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(config.Areas) != 1 || config.Areas[0].ID != group.ID || !reflect.DeepEqual(config.Areas[0].Lights, test.wantLights) {
+				t.Errorf("saved areas = %+v, want area %s with lights %v", config.Areas, group.ID, test.wantLights)
+			}
+		})
+	}
+}
+
+func TestEntertainAreaDelete(t *testing.T) {
+	tests := []struct {
+		name string
+		area string
+		code int
+	}{
+		{name: "by name", area: "TV Area"},
+		{name: "by id", area: "3"},
+		{name: "unknown area", area: "Garage", code: exitNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := useFakeBridge(t)
+			// Listing the areas saves the bridge's areas locally
+			if err := entertainAreaListCmd.RunE(entertainAreaListCmd, nil); err != nil {
+				t.Fatal(err)
+			}
+			checkExitCode(t, entertainAreaDeleteCmd.RunE(entertainAreaDeleteCmd, []string{test.area}), test.code)
+
+			deleted := fakeGroupByName(t, fake, "TV Area") == nil
+			if deleted != (test.code == exitOK) {
+				t.Errorf("area deleted from the bridge = %t", deleted)
+			}
+		})
+	}
+}
+
+func TestEntertainListKeepsLocations(t *testing.T) {
+	useFakeBridge(t)
+	// TV Area (3) holds lights 1 and 3 on the fake bridge
+	if err := saveEntertainmentConfig(EntertainmentConfig{Areas: []EntertainmentArea{{
+		ID:     "3",
+		Name:   "TV Area",
+		Lights: []string{"1", "3", "4"},
+		Locations: map[string]Location{
+			"1": {X: -0.5, Y: 0.5},
+			"4": {X: 0.5},
+		},
+	}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, cmd := range []*cobra.Command{entertainListCmd, entertainAreaListCmd} {
+		var err error
+		captureStdout(t, func() { err = cmd.RunE(cmd, nil) })
+		if err != nil {
+			t.Fatalf("%s: %v", cmd.CommandPath(), err)
+		}
+		config, err := loadEntertainmentConfig()
+		if err != nil || len(config.Areas) != 1 {
+			t.Fatalf("entertainment config = %+v, %v", config, err)
+		}
+		want := map[string]Location{"1": {X: -0.5, Y: 0.5}}
+		if area := config.Areas[0]; !reflect.DeepEqual(area.Locations, want) {
+			t.Errorf("%s: locations = %+v, want %+v", cmd.CommandPath(), area.Locations, want)
+		}
+	}
+}
+
+func TestActivateStreaming(t *testing.T) {
+	tests := []struct {
+		name       string
+		openAccess bool
+		groupID    string
+		code       int
+	}{
+		{name: "entertainment area", openAccess: true, groupID: "3"},
+		{name: "not an entertainment area", openAccess: true, groupID: "1", code: exitError},
+		{name: "missing area", openAccess: true, groupID: "42", code: exitNotFound},
+		{name: "unauthorized user", groupID: "3", code: exitNotAuthorized},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := useFakeBridge(t)
+			fake.openAccess = test.openAccess
+			checkExitCode(t, activateStreaming(test.groupID), test.code)
+
+			fake.openAccess = true
+			group := fakeGroupByName(t, fake, "TV Area")
+			if active := group.Stream.Active; active != (test.code == exitOK && test.groupID == "3") {
+				t.Errorf("stream active = %t", active)
+			}
+		})
+	}
+}
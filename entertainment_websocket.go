@@ -31,12 +31,35 @@ type WebSocketServer struct {
 
 // LightColorMessage represents a WebSocket message with light colors
 type LightColorMessage struct {
-	// Map of light ID to RGB color
-	Lights map[string]struct {
-		R uint8 `json:"r"`
-		G uint8 `json:"g"`
-		B uint8 `json:"b"`
-	} `json:"lights"`
+	// Map of light ID to color
+	Lights map[string]LightColorValue `json:"lights"`
+}
+
+// LightColorValue is the color of one light: an RGB color, or a white color
+// temperature ("2700K", "370mired" or a preset) when Temp is set
+type LightColorValue struct {
+	R    uint8  `json:"r"`
+	G    uint8  `json:"g"`
+	B    uint8  `json:"b"`
+	Temp string `json:"temp,omitempty"`
+}
+
+// colors converts the message to the RGB colors streamed to the lights
+func (msg LightColorMessage) colors() (map[string]RGB, error) {
+	colors := make(map[string]RGB, len(msg.Lights))
+	for lightID, color := range msg.Lights {
+		if color.Temp == "" {
+			colors[lightID] = RGB{R: color.R, G: color.G, B: color.B}
+			continue
+		}
+		mired, err := parseColorTemperature(color.Temp)
+		if err != nil {
+			return nil, fmt.Errorf("light %s: %w", lightID, err)
+		}
+		r, g, b := kelvinToRGB(miredToKelvin(mired))
+		colors[lightID] = RGB{R: r, G: g, B: b}
+	}
+	return colors, nil
 }
 
 // StartWebSocketServer starts a WebSocket server for real-time streaming
@@ -51,7 +74,7 @@ func StartWebSocketServer(area *EntertainmentArea, port int) error {
 
 	// Activate streaming on the bridge
 	if err := activateStreaming(area.ID); err != nil {
-		return fmt.Errorf("failed to activate streaming: %v", err)
+		return fmt.Errorf("failed to activate streaming: %w", err)
 	}
 
 	// Create DTLS connection
@@ -123,13 +146,10 @@ func (s *WebSocketServer) handleWebSocket(w http.ResponseWriter, r *http.Request
 		}
 
 		// Convert message to RGB map
-		colors := make(map[string]RGB)
-		for lightID, color := range msg.Lights {
-			colors[lightID] = RGB{
-				R: color.R,
-				G: color.G,
-				B: color.B,
-			}
+		colors, err := msg.colors()
+		if err != nil {
+			conn.WriteJSON(map[string]string{"error": err.Error()})
+			continue
 		}
 
 		// Send to bridge via DTLS
@@ -222,6 +242,8 @@ func (s *WebSocketServer) handleIndex(w http.ResponseWriter, r *http.Request) {
     "16": { "r": 0, "g": 0, "b": 255 }
   }
 }</pre>
+    <p>Instead of <code>r</code>, <code>g</code> and <code>b</code> a light can be given a white color
+    temperature: <code>{ "temp": "2700K" }</code>, <code>"370mired"</code> or a preset (warm, neutral, daylight).</p>
 
     <h3>Example JavaScript</h3>
     <pre>const ws = new WebSocket('ws://localhost:%d/ws');
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// Process exit codes, so scripts can tell failures apart
+const (
+	exitOK             = 0
+	exitError          = 1 // any other failure
+	exitNotAuthorized  = 3 // no credentials, or the bridge rejected them
+	exitUnreachable    = 4 // the bridge could not be contacted
+	exitNotFound       = 5 // a light, group, scene or area does not exist
+	exitPartialFailure = 6 // some of the requested changes failed
+	exitUntrusted      = 7 // the bridge certificate did not match, the connection may be intercepted
+)
+
+// cliError is an error carrying the exit code the process should end with
+type cliError struct {
+	code int
+	err  error
+}
+
+func (e *cliError) Error() string {
+	return e.err.Error()
+}
+
+func (e *cliError) Unwrap() error {
+	return e.err
+}
+
+func notFoundError(format string, args ...interface{}) error {
+	return &cliError{code: exitNotFound, err: fmt.Errorf(format, args...)}
+}
+
+func notAuthorizedError(format string, args ...interface{}) error {
+	return &cliError{code: exitNotAuthorized, err: fmt.Errorf(format, args...)}
+}
+
+func unreachableError(format string, args ...interface{}) error {
+	return &cliError{code: exitUnreachable, err: fmt.Errorf(format, args...)}
+}
+
+func untrustedCertificateError(format string, args ...interface{}) error {
+	return &cliError{code: exitUntrusted, err: fmt.Errorf(format, args...)}
+}
+
+func partialFailureError(format string, args ...interface{}) error {
+	return &cliError{code: exitPartialFailure, err: fmt.Errorf(format, args...)}
+}
+
+// exitCode maps an error returned by a command to a process exit code
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+
+	var cliErr *cliError
+	if errors.As(err, &cliErr) {
+		return cliErr.code
+	}
+
+	var bridgeErr *BridgeError
+	if errors.As(err, &bridgeErr) {
+		switch bridgeErr.Type {
+		case bridgeErrUnauthorized, bridgeErrLinkButtonPressed:
+			return exitNotAuthorized
+		case bridgeErrResourceNotFound:
+			return exitNotFound
+		}
+		return exitError
+	}
+
+	var urlErr *url.Error
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	if errors.As(err, &urlErr) || errors.As(err, &opErr) || errors.As(err, &dnsErr) {
+		return exitUnreachable
+	}
+
+	return exitError
+}
+
+// lightFailure records a light a command could not update
+type lightFailure struct {
+	Name string
+	Err  error
+}
+
+// lightsResult summarizes the failures of a command that touched total
+// lights. If every light failed, the first underlying error is kept so its
+// exit code survives; otherwise a partial failure is reported.
+func lightsResult(total int, failures []lightFailure) error {
+	if len(failures) == 0 {
+		return nil
+	}
+	if len(failures) == total {
+		if total == 1 {
+			return fmt.Errorf("light '%s': %w", failures[0].Name, failures[0].Err)
+		}
+		return fmt.Errorf("all %d lights failed: %w", total, failures[0].Err)
+	}
+
+	messages := make([]string, 0, len(failures))
+	for _, failure := range failures {
+		messages = append(messages, fmt.Sprintf("'%s': %v", failure.Name, failure.Err))
+	}
+	return partialFailureError("%d/%d lights failed: %s", len(failures), total, strings.Join(messages, "; "))
+}
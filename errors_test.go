@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "no error", err: nil, want: exitOK},
+		{name: "plain error", err: errors.New("boom"), want: exitError},
+		{name: "not found", err: notFoundError("light '%s' not found", "Garage"), want: exitNotFound},
+		{name: "wrapped not found", err: fmt.Errorf("scene: %w", notFoundError("missing")), want: exitNotFound},
+		{name: "partial failure", err: partialFailureError("1/2 lights failed"), want: exitPartialFailure},
+		{name: "unauthorized user", err: &BridgeError{Type: bridgeErrUnauthorized}, want: exitNotAuthorized},
+		{name: "link button", err: &BridgeError{Type: bridgeErrLinkButtonPressed}, want: exitNotAuthorized},
+		{name: "missing resource", err: fmt.Errorf("light 9: %w", &BridgeError{Type: bridgeErrResourceNotFound}), want: exitNotFound},
+		{name: "invalid value", err: &BridgeError{Type: bridgeErrInvalidValue}, want: exitError},
+		{name: "connection refused", err: &url.Error{Op: "Get", URL: "https://bridge", Err: &net.OpError{Op: "dial", Err: errors.New("refused")}}, want: exitUnreachable},
+		{name: "unknown host", err: &net.DNSError{Name: "bridge", Err: "no such host"}, want: exitUnreachable},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := exitCode(test.err); got != test.want {
+				t.Errorf("exitCode(%v) = %d, want %d", test.err, got, test.want)
+			}
+		})
+	}
+}
+
+func TestLightsResult(t *testing.T) {
+	unreachable := unreachableError("bridge unreachable")
+	tests := []struct {
+		name     string
+		total    int
+		failures []lightFailure
+		want     int
+	}{
+		{name: "all succeeded", total: 2, want: exitOK},
+		{name: "one of one failed", total: 1, failures: []lightFailure{{Name: "Desk Lamp", Err: unreachable}}, want: exitUnreachable},
+		{name: "all failed", total: 2, failures: []lightFailure{{Name: "Desk Lamp", Err: unreachable}, {Name: "Hallway", Err: unreachable}}, want: exitUnreachable},
+		{name: "some failed", total: 3, failures: []lightFailure{{Name: "Hallway", Err: unreachable}}, want: exitPartialFailure},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := exitCode(lightsResult(test.total, test.failures)); got != test.want {
+				t.Errorf("exit code = %d, want %d", got, test.want)
+			}
+		})
+	}
+}
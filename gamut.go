@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// xyPoint is a point in the CIE 1931 xy chromaticity diagram
+type xyPoint struct {
+	X, Y float64
+}
+
+// colorGamut is the triangle of xy colors a light can reproduce
+type colorGamut struct {
+	Red, Green, Blue xyPoint
+}
+
+// Gamuts of the Hue light generations
+var (
+	gamutA = colorGamut{Red: xyPoint{0.704, 0.296}, Green: xyPoint{0.2151, 0.7106}, Blue: xyPoint{0.138, 0.08}}
+	gamutB = colorGamut{Red: xyPoint{0.675, 0.322}, Green: xyPoint{0.409, 0.518}, Blue: xyPoint{0.167, 0.04}}
+	gamutC = colorGamut{Red: xyPoint{0.6915, 0.3083}, Green: xyPoint{0.17, 0.7}, Blue: xyPoint{0.1532, 0.0475}}
+)
+
+// modelGamutTypes maps light model IDs to their gamut, for bridges that do
+// not report capabilities
+var modelGamutTypes = map[string]string{
+	// Living Colors, LightStrips and other early color lights
+	"LLC001": "A", "LLC005": "A", "LLC006": "A", "LLC007": "A", "LLC010": "A",
+	"LLC011": "A", "LLC012": "A", "LLC013": "A", "LLC014": "A", "LST001": "A",
+	// First generation Hue bulbs
+	"LCT001": "B", "LCT002": "B", "LCT003": "B", "LCT007": "B", "LLM001": "B",
+	// Current generation
+	"LCT010": "C", "LCT011": "C", "LCT012": "C", "LCT014": "C", "LCT015": "C",
+	"LCT016": "C", "LLC020": "C", "LST002": "C", "LCA001": "C", "LCA002": "C",
+	"LCA003": "C", "LCG002": "C",
+}
+
+func gamutByType(gamutType string) (colorGamut, bool) {
+	switch strings.ToUpper(gamutType) {
+	case "A":
+		return gamutA, true
+	case "B":
+		return gamutB, true
+	case "C":
+		return gamutC, true
+	}
+	return colorGamut{}, false
+}
+
+// lightGamut returns the gamut of light, preferring the corners reported by
+// the bridge, then the reported gamut type, then the model table. Unknown
+// models are assumed to be gamut C.
+func lightGamut(light Light) colorGamut {
+	if caps := light.Capabilities; caps != nil {
+		if len(caps.Gamut) == 3 {
+			return colorGamut{
+				Red:   xyPoint{caps.Gamut[0][0], caps.Gamut[0][1]},
+				Green: xyPoint{caps.Gamut[1][0], caps.Gamut[1][1]},
+				Blue:  xyPoint{caps.Gamut[2][0], caps.Gamut[2][1]},
+			}
+		}
+		if gamut, ok := gamutByType(caps.GamutType); ok {
+			return gamut
+		}
+	}
+	if gamut, ok := gamutByType(modelGamutTypes[light.ModelID]); ok {
+		return gamut
+	}
+	return gamutC
+}
+
+// contains reports whether p lies inside the gamut triangle
+func (g colorGamut) contains(p xyPoint) bool {
+	d1 := cross(p, g.Red, g.Green)
+	d2 := cross(p, g.Green, g.Blue)
+	d3 := cross(p, g.Blue, g.Red)
+	hasNegative := d1 < 0 || d2 < 0 || d3 < 0
+	hasPositive := d1 > 0 || d2 > 0 || d3 > 0
+	return !(hasNegative && hasPositive)
+}
+
+// clamp returns p if the gamut contains it, otherwise the closest point on
+// the edges of the gamut triangle
+func (g colorGamut) clamp(p xyPoint) xyPoint {
+	if g.contains(p) {
+		return p
+	}
+
+	best := closestPointOnSegment(p, g.Red, g.Green)
+	bestDistance := distance(p, best)
+	for _, edge := range [][2]xyPoint{{g.Green, g.Blue}, {g.Blue, g.Red}} {
+		candidate := closestPointOnSegment(p, edge[0], edge[1])
+		if d := distance(p, candidate); d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best
+}
+
+func cross(p, a, b xyPoint) float64 {
+	return (p.X-b.X)*(a.Y-b.Y) - (a.X-b.X)*(p.Y-b.Y)
+}
+
+func closestPointOnSegment(p, a, b xyPoint) xyPoint {
+	abX, abY := b.X-a.X, b.Y-a.Y
+	t := ((p.X-a.X)*abX + (p.Y-a.Y)*abY) / (abX*abX + abY*abY)
+	t = math.Max(0, math.Min(1, t))
+	return xyPoint{a.X + t*abX, a.Y + t*abY}
+}
+
+func distance(a, b xyPoint) float64 {
+	return math.Hypot(a.X-b.X, a.Y-b.Y)
+}
+
+// rgbToLightXY converts an RGB color to the closest xy color light can show
+func rgbToLightXY(r, g, b uint8, light Light) []float32 {
+	x, y := rgbToXY(r, g, b)
+	p := lightGamut(light).clamp(xyPoint{float64(x), float64(y)})
+	return []float32{float32(p.X), float32(p.Y)}
+}
+
+// xyToRGB converts an xy color at full brightness back to RGB, the inverse
+// of rgbToXY
+func xyToRGB(x, y float64) (uint8, uint8, uint8) {
+	if y <= 0 {
+		return 0, 0, 0
+	}
+
+	// xy at full brightness to XYZ
+	Y := 1.0
+	X := (Y / y) * x
+	Z := (Y / y) * (1 - x - y)
+
+	// XYZ to linear RGB, the inverse of the matrix used by rgbToXY
+	red := X*1.656492 - Y*0.354851 - Z*0.255038
+	green := -X*0.707196 + Y*1.655397 + Z*0.036152
+	blue := X*0.051713 - Y*0.121364 + Z*1.011530
+
+	red, green, blue = normalizeRGB(red, green, blue)
+	red, green, blue = normalizeRGB(reverseGamma(red), reverseGamma(green), reverseGamma(blue))
+	return uint8(math.Round(red * 255)), uint8(math.Round(green * 255)), uint8(math.Round(blue * 255))
+}
+
+// normalizeRGB clamps negative components and scales the color down so no
+// component exceeds 1
+func normalizeRGB(r, g, b float64) (float64, float64, float64) {
+	r, g, b = math.Max(r, 0), math.Max(g, 0), math.Max(b, 0)
+	if m := math.Max(r, math.Max(g, b)); m > 1 {
+		r, g, b = r/m, g/m, b/m
+	}
+	return r, g, b
+}
+
+func reverseGamma(value float64) float64 {
+	if value <= 0.0031308 {
+		return 12.92 * value
+	}
+	return 1.055*math.Pow(value, 1/2.4) - 0.055
+}
+
+// lightColorHex describes the color a light currently shows as #RRGGBB,
+// based on its color mode. Lights without color or temperature support
+// have no color.
+func lightColorHex(light Light) string {
+	var r, g, b uint8
+	state := light.State
+	switch {
+	case state.ColorMode == "ct" && state.Ct != 0:
+		r, g, b = kelvinToRGB(miredToKelvin(state.Ct))
+	case state.ColorMode == "hs":
+		r, g, b = hsvToRGB(float64(state.Hue)/65535*360, float64(state.Sat)/254, 1)
+	case len(state.Xy) == 2:
+		p := lightGamut(light).clamp(xyPoint{float64(state.Xy[0]), float64(state.Xy[1])})
+		r, g, b = xyToRGB(p.X, p.Y)
+	case state.Ct != 0:
+		r, g, b = kelvinToRGB(miredToKelvin(state.Ct))
+	default:
+		return ""
+	}
+	return fmt.Sprintf("#%02X%02X%02X", r, g, b)
+}
+
+// colorLights splits lights into those that accept xy colors and those
+// that do not
+func colorLights(lights []Light) (capable, skipped []Light) {
+	for _, light := range lights {
+		if lightSupportsColor(light.Type) {
+			capable = append(capable, light)
+		} else {
+			skipped = append(skipped, light)
+		}
+	}
+	return capable, skipped
+}
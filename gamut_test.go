@@ -0,0 +1,102 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLightGamut(t *testing.T) {
+	tests := []struct {
+		name  string
+		light Light
+		want  colorGamut
+	}{
+		{
+			name: "corners reported by the bridge",
+			light: Light{ModelID: "LCT001", Capabilities: &LightCapabilities{
+				GamutType: "B",
+				Gamut:     [][2]float64{{0.7, 0.3}, {0.2, 0.7}, {0.15, 0.05}},
+			}},
+			want: colorGamut{Red: xyPoint{0.7, 0.3}, Green: xyPoint{0.2, 0.7}, Blue: xyPoint{0.15, 0.05}},
+		},
+		{name: "reported gamut type", light: Light{ModelID: "LCT015", Capabilities: &LightCapabilities{GamutType: "A"}}, want: gamutA},
+		{name: "model table", light: Light{ModelID: "LCT001"}, want: gamutB},
+		{name: "unknown model", light: Light{ModelID: "XYZ999"}, want: gamutC},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := lightGamut(test.light); got != test.want {
+				t.Errorf("lightGamut = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestGamutClamp(t *testing.T) {
+	tests := []struct {
+		name  string
+		gamut colorGamut
+		point xyPoint
+		want  xyPoint
+	}{
+		{name: "inside is kept", gamut: gamutC, point: xyPoint{0.3127, 0.329}, want: xyPoint{0.3127, 0.329}},
+		{name: "corner is kept", gamut: gamutB, point: gamutB.Red, want: gamutB.Red},
+		{name: "beyond the red corner", gamut: gamutB, point: xyPoint{0.72, 0.3}, want: gamutB.Red},
+		{name: "beyond the green corner of gamut B", gamut: gamutB, point: xyPoint{0.17, 0.7}, want: gamutB.Green},
+		{name: "below the blue-red edge", gamut: gamutA, point: xyPoint{0.4, 0.1}, want: xyPoint{0.3734, 0.1698}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.gamut.clamp(test.point)
+			if math.Abs(got.X-test.want.X) > 0.0005 || math.Abs(got.Y-test.want.Y) > 0.0005 {
+				t.Errorf("clamp(%v) = %+v, want %+v", test.point, got, test.want)
+			}
+		})
+	}
+}
+
+func TestXYToRGB(t *testing.T) {
+	tests := []struct {
+		name    string
+		r, g, b uint8
+	}{
+		{name: "white", r: 255, g: 255, b: 255},
+		{name: "red", r: 255, g: 0, b: 0},
+		{name: "green", r: 0, g: 255, b: 0},
+		{name: "blue", r: 0, g: 0, b: 255},
+		{name: "coral", r: 255, g: 127, b: 80},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			x, y := rgbToXY(test.r, test.g, test.b)
+			r, g, b := xyToRGB(float64(x), float64(y))
+			for _, channel := range [][2]uint8{{r, test.r}, {g, test.g}, {b, test.b}} {
+				if math.Abs(float64(channel[0])-float64(channel[1])) > 2 {
+					t.Errorf("xyToRGB(rgbToXY(%d, %d, %d)) = %d, %d, %d", test.r, test.g, test.b, r, g, b)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestLightColorHex(t *testing.T) {
+	tests := []struct {
+		name  string
+		light Light
+		want  string
+	}{
+		{name: "white point", light: Light{ModelID: "LCT015", State: LightState{ColorMode: "xy", Xy: []float32{0.3127, 0.329}}}, want: "#F5FEFF"},
+		{name: "red beyond gamut C", light: Light{ModelID: "LCT015", State: LightState{ColorMode: "xy", Xy: []float32{0.7006, 0.2993}}}, want: "#FF2700"},
+		{name: "cool white", light: Light{State: LightState{ColorMode: "ct", Ct: 153}}, want: "#FFFFFB"},
+		{name: "warm white", light: Light{State: LightState{ColorMode: "ct", Ct: 500}}, want: "#FF890E"},
+		{name: "no color", light: Light{Type: "Dimmable light"}, want: ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := lightColorHex(test.light); got != test.want {
+				t.Errorf("lightColorHex = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -1,90 +1,129 @@
 package main
 
 import (
-	"bytes"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math"
-	"net/http"
+	"net/url"
 	"os"
-	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/amimof/huego"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
 type BridgeConfig struct {
-	Host      string `json:"host"`
-	Username  string `json:"username"`
-	ID        string `json:"id"`
-	ClientKey string `json:"clientkey,omitempty"` // For Entertainment API streaming
+	Host      string  `json:"host"`
+	Username  string  `json:"username,omitempty"`
+	ID        string  `json:"id"`
+	ClientKey string  `json:"clientkey,omitempty"` // For Entertainment API streaming
+	Secret    string  `json:"secret,omitempty"`    // username and clientkey, when encrypted
+	Device    string  `json:"device,omitempty"`    // device name of the bridge user, "" for the host name
+	Rate      float64 `json:"rate,omitempty"`      // light commands per second, 0 for the default
+	API       string  `json:"api,omitempty"`       // "v1" or "v2" (CLIP v2), "" for v1
+
+	CertFingerprint string `json:"cert_fingerprint,omitempty"` // SHA-256 of the bridge's HTTPS certificate, pinned on first use
 }
 
 type SceneCommand struct {
-	Type   string   `json:"type"`   // "on", "off", "brightness", "color"
-	Light  string   `json:"light"`  // light name or ID
-	Values []string `json:"values"` // command arguments
+	Type       string   `json:"type" yaml:"type"`                                 // "on", "off", "brightness", "color", "temp"
+	Light      string   `json:"light" yaml:"light"`                               // light name or ID
+	Values     []string `json:"values" yaml:"values"`                             // command arguments
+	Transition string   `json:"transition,omitempty" yaml:"transition,omitempty"` // fade duration, e.g. "2s"
 }
 
 type Scene struct {
-	Name     string         `json:"name"`
-	Commands []SceneCommand `json:"commands"`
+	Name     string         `json:"name" yaml:"name"`
+	Commands []SceneCommand `json:"commands" yaml:"commands"`                 // run together when the scene starts
+	Steps    []SceneStep    `json:"steps,omitempty" yaml:"steps,omitempty"`   // run in order after the commands
+	Repeat   int            `json:"repeat,omitempty" yaml:"repeat,omitempty"` // times to run the scene, 0 means once
 }
 
 type SceneConfig struct {
-	Scenes []Scene `json:"scenes"`
+	Version int     `json:"version"` // sceneFileVersion; 0 for files written before versioning
+	Scenes  []Scene `json:"scenes"`
 }
 
 type Group struct {
-	Name   string   `json:"name"`
-	Lights []string `json:"lights"` // light names or IDs
+	Name     string   `json:"name" yaml:"name"`
+	Lights   []string `json:"lights" yaml:"lights"`                           // light names or IDs
+	BridgeID string   `json:"bridge_id,omitempty" yaml:"bridge_id,omitempty"` // bridge LightGroup created by 'hue group sync'
 }
 
 type GroupConfig struct {
-	Groups []Group `json:"groups"`
+	Version int     `json:"version"` // groupFileVersion; 0 for files written before versioning
+	Groups  []Group `json:"groups"`
 }
 
-var bridge *huego.Bridge
 var configFile string
 var sceneFile string
 var groupFile string
 
 func main() {
-	// Set config file path
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		fmt.Printf("Error getting home directory: %v\n", err)
+	// Set config file paths
+	if err := setupConfigDir(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
-	configFile = filepath.Join(homeDir, ".hue-config.json")
-	sceneFile = filepath.Join(homeDir, ".hue-scenes.json")
-	groupFile = filepath.Join(homeDir, ".hue-groups.json")
+	setProfilePaths(defaultProfile)
 
 	var rootCmd = &cobra.Command{
 		Use:   "hue",
 		Short: "A CLI tool for controlling Philips Hue lights",
 		Long:  `A command line interface for discovering and controlling Philips Hue lights in your network.`,
-		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := validateOutputFormat(); err != nil {
+				return err
+			}
+			if !validRequestRate(requestRate) {
+				return fmt.Errorf("--rate must be a positive number of requests per second")
+			}
+			if err := selectProfile(); err != nil {
+				return err
+			}
+
 			// Skip bridge initialization for auth, status, find, discover, scene management, and group management commands
 			cmdName := cmd.Name()
 			parentCmdName := cmd.Parent().Name()
 			skipInit := cmdName == "auth" || cmdName == "discover" || cmdName == "status" ||
-				cmdName == "find" || cmdName == "scenes" || cmdName == "groups" ||
-				(cmdName == "list" && (parentCmdName == "scene" || parentCmdName == "group" || parentCmdName == "entertain")) ||
-				(cmdName == "remove" && (parentCmdName == "scene" || parentCmdName == "group")) ||
-				(cmdName == "area" && parentCmdName == "entertain")
+				cmdName == "find" || cmdName == "mock-bridge" || cmdName == "scenes" || cmdName == "groups" || cmdName == "export" ||
+				(cmdName == "list" && (parentCmdName == "scene" || parentCmdName == "snapshot")) ||
+				(cmdName == "remove" && (parentCmdName == "scene" || parentCmdName == "group" || parentCmdName == "snapshot")) ||
+				((cmdName == "wait" || cmdName == "repeat" || cmdName == "validate") && parentCmdName == "scene") ||
+				(cmdName == "area" && parentCmdName == "entertain") ||
+				parentCmdName == "profile" || parentCmdName == "colors" || parentCmdName == "config"
+
+			if allBridges {
+				if profileName != "" {
+					return fmt.Errorf("--profile and --all-bridges cannot be used together")
+				}
+				if cmdName == "auth" || parentCmdName == "auth" || cmdName == "mock-bridge" || parentCmdName == "profile" || parentCmdName == "colors" || parentCmdName == "config" || cmd.RunE == nil {
+					return fmt.Errorf("'%s' cannot be run with --all-bridges", cmd.CommandPath())
+				}
+				cmd.RunE = runOnAllBridges(cmd.RunE, !skipInit)
+				return nil
+			}
 
 			if !skipInit {
-				initBridge()
+				return initBridge()
 			}
+			return nil
 		},
+		SilenceUsage:  true,
+		SilenceErrors: true,
 	}
 
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", "table", "Output format for listing commands: table, json or yaml")
+	rootCmd.PersistentFlags().StringVarP(&profileName, "profile", "P", "", "Bridge profile to use (defaults to the current profile)")
+	rootCmd.PersistentFlags().BoolVar(&allBridges, "all-bridges", false, "Run the command against every saved profile")
+	rootCmd.PersistentFlags().Float64Var(&requestRate, "rate", 0, "Maximum light commands per second sent to the bridge (default: the profile's rate, or 10)")
+
 	rootCmd.AddCommand(authCmd)
 	rootCmd.AddCommand(statusCmd)
 	rootCmd.AddCommand(findCmd)
@@ -93,199 +132,354 @@ func main() {
 	rootCmd.AddCommand(offCmd)
 	rootCmd.AddCommand(brightnessCmd)
 	rootCmd.AddCommand(colorCmd)
+	rootCmd.AddCommand(tempCmd)
+	rootCmd.AddCommand(colorsCmd)
 	rootCmd.AddCommand(discoverCmd)
 	rootCmd.AddCommand(sceneCmd)
 	rootCmd.AddCommand(groupCmd)
 	rootCmd.AddCommand(entertainCmd)
-
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+	rootCmd.AddCommand(snapshotCmd)
+	rootCmd.AddCommand(configCmd)
+	rootCmd.AddCommand(applyCmd)
+	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(mockBridgeCmd)
+	rootCmd.AddCommand(profileCmd)
+	rootCmd.AddCommand(usersCmd)
+
+	err := rootCmd.Execute()
+	reportSchedulers(os.Stderr)
+	if pinErr := savePendingPins(); pinErr != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to pin the bridge certificate: %v\n", pinErr)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitCode(err))
 	}
 }
 
-func initBridge() {
+func initBridge() error {
 	// Try to load saved configuration first
 	config, err := loadBridgeConfig()
 	if err == nil && config.Host != "" && config.Username != "" {
-		// Use saved configuration
-		bridge = huego.New(config.Host, config.Username)
-		return
+		// Use saved configuration; state changes are rate limited per bridge
+		rate := config.Rate
+		if requestRate > 0 {
+			rate = requestRate
+		}
+		name := ""
+		if allBridges {
+			name = profileName
+		}
+		bridge = newScheduledClient(newConfiguredClient(config), name, rate)
+		return nil
 	}
 
-	// If no saved config, try to discover and prompt for authorization
-	fmt.Println("No saved bridge configuration found.")
-	fmt.Println("Please run 'hue auth' to authorize with your Hue bridge first.")
-	os.Exit(1)
+	// Credentials that could not be decrypted are reported as they are
+	var cliErr *cliError
+	if errors.As(err, &cliErr) {
+		return err
+	}
+
+	// If no saved config, prompt for authorization
+	if profileName != "" {
+		return notAuthorizedError("no saved bridge configuration found for profile '%s'; run 'hue auth --profile %s' first", profileName, profileName)
+	}
+	return notAuthorizedError("no saved bridge configuration found; run 'hue auth' to authorize with your Hue bridge first")
 }
 
 var authCmd = &cobra.Command{
 	Use:   "auth",
 	Short: "Authorize with Hue bridge",
-	Long:  `Discover and authorize with your Hue bridge. This will save the credentials for future use.`,
-	Run: func(cmd *cobra.Command, args []string) {
+	Long: `Discover and authorize with your Hue bridge. This will save the credentials for future use.
+
+Use --wait to give more time to press the link button, for example when the bridge is in
+another room. To set up many machines without pressing the button for each, pair once and
+import the credentials elsewhere with --username and --clientkey.
+
+Examples:
+  hue auth --wait 2m
+  hue auth --ip 192.168.1.100 --username <username> --clientkey <clientkey>`,
+	RunE: func(cmd *cobra.Command, args []string) error {
 		bridgeIP, _ := cmd.Flags().GetString("ip")
+		device, _ := cmd.Flags().GetString("device")
+		importUsername, _ := cmd.Flags().GetString("username")
+		importClientKey, _ := cmd.Flags().GetString("clientkey")
+		wait, err := linkWaitFlag(cmd)
+		if err != nil {
+			return err
+		}
+		if importClientKey != "" {
+			if importUsername == "" {
+				return fmt.Errorf("--clientkey needs --username")
+			}
+			if key, err := hex.DecodeString(importClientKey); err != nil || len(key) != 16 {
+				return fmt.Errorf("the client key must be 32 hexadecimal characters")
+			}
+		}
 
-		var discoveredBridge *huego.Bridge
-		var err error
+		// Ask for the passphrase of encrypted credentials before pairing, so a
+		// wrong one does not leave an unsaved user on the bridge
+		if profiles, err := loadProfileConfig(); err == nil && profiles.Encryption != "" {
+			if _, err := credentialKey(profiles); err != nil {
+				return err
+			}
+		}
 
+		var discoveredBridge discoveredBridge
+		var fingerprint string
 		if bridgeIP != "" {
 			fmt.Printf("Connecting to bridge at %s...\n", bridgeIP)
-			discoveredBridge = huego.New(bridgeIP, "")
+
+			// Nothing is known about the bridge yet, so pin the certificate it
+			// presents now (trust on first use) before asking for its config
+			fingerprint, discoveredBridge.ID, err = trustBridge(bridgeIP, "")
+			if err != nil {
+				fmt.Println("Please check the IP address and try again.")
+				return err
+			}
 
 			// Test if the bridge is reachable
-			_, err = discoveredBridge.GetConfig()
+			info, err := newBridgeClient(bridgeIP, "").GetConfig()
 			if err != nil {
-				fmt.Printf("Error connecting to bridge at %s: %v\n", bridgeIP, err)
 				fmt.Println("Please check the IP address and try again.")
-				return
+				return unreachableError("failed to connect to bridge at %s: %w", bridgeIP, err)
+			}
+			discoveredBridge.Host = bridgeIP
+			reported := strings.ToUpper(info.BridgeID)
+			switch {
+			case isBridgeID(discoveredBridge.ID) && reported != "" && reported != discoveredBridge.ID:
+				return untrustedCertificateError("the bridge at %s reports ID %s, but its certificate was issued to %s", bridgeIP, reported, discoveredBridge.ID)
+			case !isBridgeID(discoveredBridge.ID) && reported != "":
+				discoveredBridge.ID = reported
+			case discoveredBridge.ID == "":
+				// Fall back to the IP if the bridge did not report an ID
+				discoveredBridge.ID = bridgeIP
 			}
-			// Set the ID to the IP for manual connections
-			discoveredBridge.ID = bridgeIP
 		} else {
 			fmt.Println("Discovering Hue bridge...")
 			fmt.Println("This may take a few seconds...")
 
-			discoveredBridge, err = huego.Discover()
-			if err != nil {
-				fmt.Printf("Error discovering bridge: %v\n", err)
-				fmt.Println("\nTroubleshooting steps:")
-				fmt.Println("1. Make sure your computer and Hue bridge are on the same network")
-				fmt.Println("2. Check that your Hue bridge is powered on and connected")
-				fmt.Println("3. Try using the Philips Hue app to ensure the bridge is working")
-				fmt.Println("4. You can also try specifying the bridge IP manually with:")
-				fmt.Println("   hue auth --ip <bridge-ip-address>")
-				return
-			}
-
-			if discoveredBridge == nil {
-				fmt.Println("No Hue bridge found on the network.")
+			bridges := discoverBridges(discoveryOptions{timeout: 3 * time.Second, cloud: true})
+			if len(bridges) == 0 {
 				fmt.Println("Please check that:")
 				fmt.Println("1. Your Hue bridge is powered on")
 				fmt.Println("2. Your computer and bridge are on the same network")
-				fmt.Println("3. No firewall is blocking the discovery")
-				fmt.Println("4. Try specifying the bridge IP manually: hue auth --ip <bridge-ip>")
-				return
+				fmt.Println("3. No firewall is blocking multicast (mDNS/SSDP) traffic")
+				fmt.Println("4. Try 'hue find --scan' to probe the local network")
+				fmt.Println("5. Try specifying the bridge IP manually: hue auth --ip <bridge-ip>")
+				return unreachableError("no Hue bridge found on the network")
+			}
+
+			discoveredBridge = bridges[0]
+			if len(bridges) > 1 {
+				fmt.Printf("Found %d bridges, using the first one. Use --ip to pick another:\n", len(bridges))
+				for _, b := range bridges {
+					fmt.Printf("  %s (%s)\n", b.Host, b.ID)
+				}
+			}
+
+			// Pin the certificate the bridge presents now (trust on first use)
+			// and hold the rest of the run to it, before any username is sent
+			fingerprint, discoveredBridge.ID, err = trustBridge(discoveredBridge.Host, discoveredBridge.ID)
+			if err != nil {
+				return err
 			}
 		}
 
 		fmt.Printf("Found bridge at: %s\n", discoveredBridge.Host)
 		fmt.Printf("Bridge ID: %s\n", discoveredBridge.ID)
-		fmt.Println("\nPress the link button on your Hue bridge now...")
-		fmt.Println("You have 30 seconds to press the button.")
-		fmt.Println("Waiting for authorization...")
 
-		// Try to create a new user with clientkey every 2 seconds for 30 seconds
-		var username, clientKey string
-		for i := 0; i < 15; i++ {
-			time.Sleep(2 * time.Second)
-			username, clientKey, err = createUserWithClientKey(discoveredBridge.Host)
-			if err == nil {
-				break
+		username, clientKey := importUsername, importClientKey
+		if username != "" {
+			if _, err := newBridgeClient(discoveredBridge.Host, username).GetLights(); err != nil {
+				return notAuthorizedError("the bridge did not accept username %s: %w", redact(username), err)
+			}
+			fmt.Printf("Imported username %s\n", redact(username))
+			if clientKey == "" {
+				fmt.Println("No client key given, entertainment streaming will not be available")
+			}
+		} else {
+			username, clientKey, err = waitForLinkButton(discoveredBridge.Host, device, wait)
+			if err != nil {
+				return err
 			}
-			fmt.Print(".")
-		}
-
-		if err != nil {
-			fmt.Printf("\nFailed to authorize: %v\n", err)
-			fmt.Println("Make sure you pressed the link button on your bridge.")
-			fmt.Println("If the problem persists, try:")
-			fmt.Println("1. Wait a minute and try again")
-			fmt.Println("2. Check that no other apps are trying to connect")
-			return
-		}
 
-		fmt.Printf("\nSuccess! Authorized with username: %s\n", username)
-		if clientKey != "" {
-			fmt.Println("✅ Entertainment streaming enabled (clientkey generated)")
+			fmt.Printf("Success! Authorized with username: %s\n", redact(username))
+			if clientKey != "" {
+				fmt.Println("✅ Entertainment streaming enabled (clientkey generated)")
+			}
 		}
 
 		// Save configuration
 		config := BridgeConfig{
-			Host:      discoveredBridge.Host,
-			Username:  username,
-			ID:        discoveredBridge.ID,
-			ClientKey: clientKey,
+			Host:            discoveredBridge.Host,
+			Username:        username,
+			ID:              discoveredBridge.ID,
+			ClientKey:       clientKey,
+			Device:          device,
+			CertFingerprint: fingerprint,
 		}
 
-		err = saveBridgeConfig(config)
-		if err != nil {
-			fmt.Printf("Warning: Failed to save configuration: %v\n", err)
+		if err := saveBridgeConfig(config); err != nil {
 			fmt.Println("You may need to authorize again next time.")
-		} else {
-			fmt.Printf("Configuration saved to: %s\n", configFile)
-			fmt.Println("You can now use other hue commands!")
+			return fmt.Errorf("failed to save configuration: %w", err)
 		}
+
+		fmt.Printf("Configuration saved to: %s (profile '%s')\n", configFile, currentProfile())
+		fmt.Println("You can now use other hue commands!")
+		return nil
 	},
 }
 
 func init() {
 	authCmd.Flags().StringP("ip", "i", "", "Manually specify bridge IP address instead of auto-discovery")
+	authCmd.Flags().String("device", "", "Device name the bridge and Hue app show for this user (default: host name)")
+	authCmd.Flags().String("username", "", "Import an existing bridge username instead of pairing")
+	authCmd.Flags().String("clientkey", "", "Client key for entertainment streaming that belongs to --username")
+	authCmd.PersistentFlags().String("wait", defaultLinkWait.String(), "How long to wait for the link button to be pressed (e.g. 60s or 2m)")
+	statusCmd.Flags().Bool("show-secrets", false, "Show the username in full")
+}
+
+// linkWaitFlag returns the --wait duration of auth and its subcommands
+func linkWaitFlag(cmd *cobra.Command) (time.Duration, error) {
+	value, _ := cmd.Flags().GetString("wait")
+	wait, ok := parseDuration(value)
+	if !ok || wait <= 0 {
+		return 0, fmt.Errorf("invalid wait '%s' (use e.g. 60s or 2m)", value)
+	}
+	return wait, nil
 }
 
 var statusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Show authorization status",
 	Long:  `Display the current authorization status and bridge information.`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
+		profile := currentProfile()
+
 		config, err := loadBridgeConfig()
 		if err != nil {
-			fmt.Println("Status: Not authorized")
-			fmt.Printf("Config file: %s (not found)\n", configFile)
-			fmt.Println("Run 'hue auth' to authorize with your Hue bridge.")
-			return
+			printOutput(statusDocument{Profile: profile, ConfigFile: configFile}, func(w io.Writer) {
+				fmt.Fprintln(w, "Status: Not authorized")
+				fmt.Fprintf(w, "Profile: %s\n", profile)
+				fmt.Fprintf(w, "Config file: %s (%v)\n", configFile, err)
+				fmt.Fprintln(w, "Run 'hue auth' to authorize with your Hue bridge.")
+			})
+			return notAuthorizedError("not authorized")
 		}
 
-		fmt.Println("Status: Authorized")
-		fmt.Printf("Config file: %s\n", configFile)
-		fmt.Printf("Bridge Host: %s\n", config.Host)
-		fmt.Printf("Bridge ID: %s\n", config.ID)
-		fmt.Printf("Username: %s\n", config.Username)
+		status := statusDocument{
+			Profile:    profile,
+			Authorized: true,
+			ConfigFile: configFile,
+			Host:       config.Host,
+			BridgeID:   config.ID,
+			Username:   redact(config.Username),
+			API:        apiV1,
+			Overrides:  applyEnvOverrides(&BridgeConfig{}),
+		}
+		if config.API != "" {
+			status.API = config.API
+		}
+		if showSecrets, _ := cmd.Flags().GetBool("show-secrets"); showSecrets {
+			status.Username = config.Username
+		}
+		if profiles, err := loadProfileConfig(); err == nil {
+			status.Encrypted = profiles.Encryption
+		}
 
 		// Test connection
-		testBridge := huego.New(config.Host, config.Username)
-		if lights, err := testBridge.GetLights(); err == nil {
-			fmt.Printf("Connection: OK (%d lights found)\n", len(lights))
+		testBridge := newConfiguredClient(config)
+		lights, connErr := testBridge.GetLights()
+		if connErr == nil {
+			status.Connected = true
+			status.Lights = len(lights)
 		} else {
-			fmt.Printf("Connection: FAILED (%v)\n", err)
-			fmt.Println("You may need to re-authorize with 'hue auth'")
+			status.Error = connErr.Error()
+		}
+
+		printOutput(status, func(w io.Writer) {
+			fmt.Fprintln(w, "Status: Authorized")
+			fmt.Fprintf(w, "Profile: %s\n", status.Profile)
+			fmt.Fprintf(w, "Config file: %s\n", status.ConfigFile)
+			fmt.Fprintf(w, "Bridge Host: %s\n", status.Host)
+			fmt.Fprintf(w, "Bridge ID: %s\n", status.BridgeID)
+			fmt.Fprintf(w, "Username: %s\n", status.Username)
+			fmt.Fprintf(w, "API: %s\n", status.API)
+			if status.Encrypted != "" {
+				fmt.Fprintf(w, "Credentials: encrypted with a %s\n", status.Encrypted)
+			}
+			if len(status.Overrides) > 0 {
+				fmt.Fprintf(w, "Overridden by: %s\n", strings.Join(status.Overrides, ", "))
+			}
+			if status.Connected {
+				fmt.Fprintf(w, "Connection: OK (%d lights found)\n", status.Lights)
+			} else {
+				fmt.Fprintf(w, "Connection: FAILED (%s)\n", status.Error)
+				fmt.Fprintln(w, "You may need to re-authorize with 'hue auth'")
+			}
+		})
+		if connErr != nil {
+			return fmt.Errorf("connection failed: %w", connErr)
 		}
+		return nil
 	},
 }
 
 var findCmd = &cobra.Command{
 	Use:   "find",
 	Short: "Find Hue bridge on network",
-	Long:  `Attempt to find your Hue bridge using multiple methods and show diagnostic information.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("Searching for Hue bridge...")
-		fmt.Println("Method 1: Using Philips discovery service...")
+	Long: `Attempt to find your Hue bridge using multiple methods and show diagnostic information.
 
-		bridge, err := huego.Discover()
+mDNS and SSDP work without internet access. Use --scan to also probe every
+address of the local networks for a bridge.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts, err := discoveryFlags(cmd)
 		if err != nil {
-			fmt.Printf("Discovery service failed: %v\n", err)
-		} else if bridge != nil {
-			fmt.Printf("✓ Found bridge at: %s (ID: %s)\n", bridge.Host, bridge.ID)
+			return err
+		}
 
-			// Test connection
-			if config, err := bridge.GetConfig(); err == nil {
-				fmt.Printf("  Bridge name: %s\n", config.Name)
-				fmt.Printf("  API version: %s\n", config.APIVersion)
-				fmt.Printf("  Software version: %s\n", config.SwVersion)
+		fmt.Println("Searching for Hue bridge...")
+		results := runDiscovery(discoveryMethods(opts), opts.timeout)
+		for i, result := range results {
+			fmt.Printf("Method %d: %s...\n", i+1, result.method.label)
+			switch {
+			case result.err != nil:
+				fmt.Printf("  Failed: %v\n", result.err)
+			case len(result.hits) == 0:
+				fmt.Println("  No bridge found")
+			default:
+				for _, hit := range result.hits {
+					fmt.Printf("  ✓ Found bridge at: %s\n", hit.host)
+				}
 			}
-		} else {
-			fmt.Println("No bridge found via discovery service")
+		}
+		if !opts.scan && opts.subnet == "" {
+			fmt.Println("Subnet probe skipped (use --scan to probe the local network)")
 		}
 
-		fmt.Println("\nMethod 2: Checking common IP ranges...")
-		fmt.Println("Scanning local network for Hue bridges...")
+		bridges := collectBridges(results, opts.timeout)
+		if len(bridges) == 0 {
+			fmt.Println("\nNo bridge found. To manually find your bridge IP:")
+			fmt.Println("1. Open your router's admin panel (usually 192.168.1.1 or 192.168.0.1)")
+			fmt.Println("2. Look for connected devices named 'Philips-hue' or similar")
+			fmt.Println("3. Use that IP address with: hue auth --ip <ip-address>")
+			fmt.Println("\nAlternatively, try the Philips Hue app to ensure your bridge is working properly.")
+			return unreachableError("no bridge found")
+		}
 
-		// Get local IP to determine network range
-		fmt.Println("To manually find your bridge IP:")
-		fmt.Println("1. Open your router's admin panel (usually 192.168.1.1 or 192.168.0.1)")
-		fmt.Println("2. Look for connected devices named 'Philips-hue' or similar")
-		fmt.Println("3. Use that IP address with: hue auth --ip <ip-address>")
-		fmt.Println("\nAlternatively, try the Philips Hue app to ensure your bridge is working properly.")
+		fmt.Println()
+		for _, b := range bridges {
+			fmt.Printf("✓ Bridge at %s (ID: %s)\n", b.Host, b.ID)
+			if b.Name != "" {
+				fmt.Printf("  Bridge name: %s\n", b.Name)
+				fmt.Printf("  Model: %s\n", b.ModelID)
+				fmt.Printf("  API version: %s\n", b.APIVersion)
+				fmt.Printf("  Software version: %s\n", b.SwVersion)
+			}
+			fmt.Printf("  Found via: %s\n", strings.Join(b.Methods, ", "))
+		}
+		return nil
 	},
 }
 
@@ -293,93 +487,94 @@ var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all Hue lights",
 	Long:  `Display a list of all Philips Hue lights with their current status.`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		lights, err := bridge.GetLights()
 		if err != nil {
-			fmt.Printf("Error getting lights: %v\n", err)
-			return
+			return fmt.Errorf("failed to get lights: %w", err)
 		}
 
-		fmt.Println("Hue Lights:")
-		fmt.Println("ID\tName\t\t\tOn\tBrightness\tHue\tSaturation")
-		fmt.Println("--\t----\t\t\t--\t----------\t---\t----------")
+		doc := lightsDocument{Lights: make([]lightDocument, 0, len(lights))}
 		for _, light := range lights {
-			fmt.Printf("%d\t%-20s\t%t\t%d\t\t%d\t%d\n",
-				light.ID, light.Name, light.State.On, light.State.Bri, light.State.Hue, light.State.Sat)
+			doc.Lights = append(doc.Lights, lightDocument{Light: light, Color: lightColorHex(light)})
 		}
+
+		return printOutput(doc, func(w io.Writer) {
+			fmt.Fprintln(w, "ID\tName\tOn\tBrightness\tHue\tSaturation\tColor\tReachable")
+			fmt.Fprintln(w, "--\t----\t--\t----------\t---\t----------\t-----\t---------")
+			for _, light := range doc.Lights {
+				fmt.Fprintf(w, "%d\t%s\t%t\t%d\t%d\t%d\t%s\t%t\n",
+					light.ID, light.Name, light.State.On, light.State.Bri, light.State.Hue, light.State.Sat, light.Color, light.State.Reachable)
+			}
+		})
 	},
 }
 
 var onCmd = &cobra.Command{
 	Use:   "on [light-id/light-name/group]",
 	Short: "Turn on lights",
-	Long:  `Turn on one or more lights by ID, name, or group. Use 'all' to turn on all lights. Use 'g:groupname' to turn on a group.`,
+	Long:  `Turn on one or more lights by ID, name, or group. Use 'all' to turn on all lights. Use 'g:groupname' to turn on a group, or 'r:room' and 'z:zone' for rooms and zones stored on the bridge.`,
 	Args:  cobra.MinimumNArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		if args[0] == "all" {
-			lights, err := bridge.GetLights()
-			if err != nil {
-				fmt.Printf("Error getting lights: %v\n", err)
-				return
-			}
-			for _, light := range lights {
-				light.On()
-			}
-			fmt.Println("All lights turned on")
-			return
-		}
-
-		lights := resolveLightIdentifiers(args)
-		if len(lights) == 0 {
-			fmt.Printf("No lights found for identifiers: %v\n", args)
-			return
-		}
-
-		for _, light := range lights {
-			light.On()
-			fmt.Printf("Light '%s' turned on\n", light.Name)
-		}
-
-		if len(lights) > 1 {
-			fmt.Printf("Total: %d lights turned on\n", len(lights))
+	RunE: func(cmd *cobra.Command, args []string) error {
+		transition, err := transitionFlag(cmd)
+		if err != nil {
+			return err
 		}
+		return setPower(args, true, transition)
 	},
 }
 
 var offCmd = &cobra.Command{
 	Use:   "off [light-id/light-name/group]",
 	Short: "Turn off lights",
-	Long:  `Turn off one or more lights by ID, name, or group. Use 'all' to turn off all lights. Use 'g:groupname' to turn off a group.`,
+	Long:  `Turn off one or more lights by ID, name, or group. Use 'all' to turn off all lights. Use 'g:groupname' to turn off a group, or 'r:room' and 'z:zone' for rooms and zones stored on the bridge.`,
 	Args:  cobra.MinimumNArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		if args[0] == "all" {
-			lights, err := bridge.GetLights()
-			if err != nil {
-				fmt.Printf("Error getting lights: %v\n", err)
-				return
-			}
-			for _, light := range lights {
-				light.Off()
-			}
-			fmt.Println("All lights turned off")
-			return
+	RunE: func(cmd *cobra.Command, args []string) error {
+		transition, err := transitionFlag(cmd)
+		if err != nil {
+			return err
 		}
+		return setPower(args, false, transition)
+	},
+}
 
-		lights := resolveLightIdentifiers(args)
-		if len(lights) == 0 {
-			fmt.Printf("No lights found for identifiers: %v\n", args)
-			return
-		}
+// setPower turns the lights identified by args on or off, fading over
+// transition when it is set
+func setPower(args []string, on bool, transition *uint16) error {
+	word := "off"
+	if on {
+		word = "on"
+	}
 
-		for _, light := range lights {
-			light.Off()
-			fmt.Printf("Light '%s' turned off\n", light.Name)
+	state := newState().On(on).Transition(transition)
+	if groupID, ok := bridgeGroupTarget(args); ok {
+		if err := state.sendToGroup(groupID); err != nil {
+			return err
 		}
+		fmt.Printf("%s turned %s\n", groupTargetName(args[0]), word)
+		return nil
+	}
+
+	lights, err := resolveTargets(args)
+	if err != nil {
+		return err
+	}
 
-		if len(lights) > 1 {
-			fmt.Printf("Total: %d lights turned off\n", len(lights))
+	all := args[0] == "all"
+	updated := 0
+	err = setLightsState(lights, state, func(light Light) {
+		updated++
+		if !all {
+			fmt.Printf("Light '%s' turned %s\n", light.Name, word)
 		}
-	},
+	})
+
+	if all && err == nil {
+		fmt.Printf("All lights turned %s\n", word)
+	}
+	if !all && updated > 1 {
+		fmt.Printf("Total: %d lights turned %s\n", updated, word)
+	}
+	return err
 }
 
 var brightnessCmd = &cobra.Command{
@@ -387,140 +582,144 @@ var brightnessCmd = &cobra.Command{
 	Short: "Set brightness of lights",
 	Long:  `Set the brightness of one or more lights. Brightness range is 0-254. Use 'g:groupname' to set brightness for a group.`,
 	Args:  cobra.ExactArgs(2),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		brightness, err := strconv.Atoi(args[1])
 		if err != nil || brightness < 0 || brightness > 254 {
-			fmt.Println("Brightness must be a number between 0 and 254")
-			return
+			return fmt.Errorf("brightness must be a number between 0 and 254")
+		}
+		transition, err := transitionFlag(cmd)
+		if err != nil {
+			return err
 		}
 
-		if args[0] == "all" {
-			lights, err := bridge.GetLights()
-			if err != nil {
-				fmt.Printf("Error getting lights: %v\n", err)
-				return
-			}
-			for _, light := range lights {
-				light.Bri(uint8(brightness))
+		state := newState().On(true).Bri(uint8(brightness)).Transition(transition)
+		if groupID, ok := bridgeGroupTarget(args[:1]); ok {
+			if err := state.sendToGroup(groupID); err != nil {
+				return err
 			}
-			fmt.Printf("All lights brightness set to %d\n", brightness)
-			return
+			fmt.Printf("%s brightness set to %d\n", groupTargetName(args[0]), brightness)
+			return nil
 		}
 
-		lights := resolveLightIdentifiers([]string{args[0]})
-		if len(lights) == 0 {
-			fmt.Printf("No lights found for identifier: %s\n", args[0])
-			return
+		lights, err := resolveTargets(args[:1])
+		if err != nil {
+			return err
 		}
 
-		for _, light := range lights {
-			light.Bri(uint8(brightness))
-			fmt.Printf("Light '%s' brightness set to %d\n", light.Name, brightness)
-		}
+		all := args[0] == "all"
+		updated := 0
+		err = setLightsState(lights, state, func(light Light) {
+			updated++
+			if !all {
+				fmt.Printf("Light '%s' brightness set to %d\n", light.Name, brightness)
+			}
+		})
 
-		if len(lights) > 1 {
-			fmt.Printf("Total: %d lights brightness set to %d\n", len(lights), brightness)
+		if all && err == nil {
+			fmt.Printf("All lights brightness set to %d\n", brightness)
+		}
+		if !all && updated > 1 {
+			fmt.Printf("Total: %d lights brightness set to %d\n", updated, brightness)
 		}
+		return err
 	},
 }
 
 var colorCmd = &cobra.Command{
-	Use:   "color [light-id/light-name/group] [red] [green] [blue] [brightness] OR [light-id/light-name/group] [RRGGBB|RRGGBBAA|RGB|RGBA]",
-	Short: "Set RGB color of lights",
-	Long:  `Set the RGB color of one or more lights. RGB values should be between 0-255. Brightness is optional (0-254). You can also use Hex color codes (RRGGBB, RRGGBBAA, RGB, RGBA). Use 'g:groupname' to set color for a group.`,
-	Args:  cobra.RangeArgs(1, 5),
-	Run: func(cmd *cobra.Command, args []string) {
-		if len(args) == 2 {
-			// Check if args[1] is a hex color code using regex
-			hex := args[1]
-			if (len(hex) > 2) && isHexColor(hex) {
-				r, g, b, a := parseHexColor(hex)
-				args[1] = fmt.Sprintf("%d", r)
-				args = append(args, fmt.Sprintf("%d", g))
-				args = append(args, fmt.Sprintf("%d", b))
-				if a >= 0 {
-					args = append(args, fmt.Sprintf("%d", a))
-				}
-			} else {
-				// If not a hex code, we expect RGB values
-				if len(args) < 4 {
-					fmt.Println("RGB values must be provided")
-					return
-				}
-			}
+	Use:   "color [light-id/light-name/group] [color] [brightness] OR [light-id/light-name/group] [red] [green] [blue] [brightness]",
+	Short: "Set color of lights",
+	Long: `Set the color of one or more lights. Brightness is optional (0-254). Use 'g:groupname' to set color for a group.
+
+Colors can be given as RGB values (0-255), a hex code (RRGGBB, RRGGBBAA, RGB, RGBA, optionally with #),
+a CSS/X11 color name, rgb(r, g, b), hsl(h, s%, l%), hsv(h, s%, v%), xy:x,y, ct:2700K or a color alias
+defined with 'hue colors set'. Quote colors that contain spaces.
+
+Examples:
+  hue color "Desk Lamp" 255 0 0
+  hue color "Desk Lamp" FF0000
+  hue color g:living-room coral 200
+  hue color all "hsl(200, 80%, 50%)"
+  hue color 3 xy:0.31,0.32
+  hue color all sunset --transition 2s`,
+	Args: cobra.RangeArgs(2, 5),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		color, brightness, err := parseColorArgs(args[1:])
+		if err != nil {
+			return err
+		}
+		transition, err := transitionFlag(cmd)
+		if err != nil {
+			return err
+		}
+
+		lights, err := resolveTargets(args[:1])
+		if err != nil {
+			return err
 		}
 
-		r, err1 := strconv.Atoi(args[1])
-		g, err2 := strconv.Atoi(args[2])
-		b, err3 := strconv.Atoi(args[3])
+		lights, skipped := color.capableLights(lights)
+		for _, light := range skipped {
+			fmt.Fprintf(os.Stderr, "Warning: Light '%s' does not support %s, skipping\n", light.Name, color.capability())
+		}
+		if len(lights) == 0 {
+			return fmt.Errorf("none of the lights support %s", color.capability())
+		}
 
-		if err1 != nil || err2 != nil || err3 != nil ||
-			r < 0 || r > 255 || g < 0 || g > 255 || b < 0 || b > 255 {
-			fmt.Println("RGB values must be numbers between 0 and 255")
-			return
+		// Color and brightness go in one request so they fade together
+		state := newState().On(true).Transition(transition)
+		if brightness >= 0 {
+			state = state.Bri(uint8(brightness))
 		}
 
-		// Check for optional brightness parameter
-		var brightness int = -1 // -1 means no brightness set
-		if len(args) == 5 {
-			var err4 error
-			brightness, err4 = strconv.Atoi(args[4])
-			if err4 != nil || brightness < 0 || brightness > 255 {
-				if brightness > 254 {
-					brightness = 254 // Just to not break the user script if they provide 255 or FF as that feels more natural
-				}
-				fmt.Println("Brightness value must be a number between 0 and 254")
-				return
+		// A bridge group gets the color in a single request and the bridge fits
+		// it to each light's gamut. Groups with lights that cannot show it are
+		// set light by light, as the group request would turn those lights on.
+		if groupID, ok := bridgeGroupTarget(args[:1]); ok && len(skipped) == 0 {
+			if err := color.applyTo(state, Light{}).sendToGroup(groupID); err != nil {
+				return err
 			}
+			if brightness >= 0 {
+				fmt.Printf("%s color set to %s with brightness %d\n", groupTargetName(args[0]), color, brightness)
+			} else {
+				fmt.Printf("%s color set to %s\n", groupTargetName(args[0]), color)
+			}
+			return nil
 		}
 
-		if args[0] == "all" {
-			lights, err := bridge.GetLights()
-			if err != nil {
-				fmt.Printf("Error getting lights: %v\n", err)
-				return
+		all := args[0] == "all"
+		var failures []lightFailure
+		for _, light := range lights {
+			if err := color.applyTo(state, light).send(light); err != nil {
+				failures = append(failures, lightFailure{Name: light.Name, Err: err})
+				continue
 			}
-			for _, light := range lights {
-				// Convert RGB to XY color space for Hue lights
-				x, y := rgbToXY(uint8(r), uint8(g), uint8(b))
-				light.Xy([]float32{x, y})
-				if brightness >= 0 {
-					light.Bri(uint8(brightness))
-				}
+
+			if all {
+				continue
 			}
 			if brightness >= 0 {
-				fmt.Printf("All lights color set to RGB(%d, %d, %d) with brightness %d\n", r, g, b, brightness)
+				fmt.Printf("Light '%s' color set to %s with brightness %d\n", light.Name, color, brightness)
 			} else {
-				fmt.Printf("All lights color set to RGB(%d, %d, %d)\n", r, g, b)
+				fmt.Printf("Light '%s' color set to %s\n", light.Name, color)
 			}
-			return
-		}
-
-		lights := resolveLightIdentifiers([]string{args[0]})
-		if len(lights) == 0 {
-			fmt.Printf("No lights found for identifier: %s\n", args[0])
-			return
 		}
 
-		for _, light := range lights {
-			// Convert RGB to XY color space for Hue lights
-			x, y := rgbToXY(uint8(r), uint8(g), uint8(b))
-			light.Xy([]float32{x, y})
+		updated := len(lights) - len(failures)
+		if all && len(failures) == 0 {
 			if brightness >= 0 {
-				light.Bri(uint8(brightness))
-				fmt.Printf("Light '%s' color set to RGB(%d, %d, %d) with brightness %d\n", light.Name, r, g, b, brightness)
+				fmt.Printf("All lights color set to %s with brightness %d\n", color, brightness)
 			} else {
-				fmt.Printf("Light '%s' color set to RGB(%d, %d, %d)\n", light.Name, r, g, b)
+				fmt.Printf("All lights color set to %s\n", color)
 			}
 		}
-
-		if len(lights) > 1 {
+		if !all && updated > 1 {
 			if brightness >= 0 {
-				fmt.Printf("Total: %d lights color set to RGB(%d, %d, %d) with brightness %d\n", len(lights), r, g, b, brightness)
+				fmt.Printf("Total: %d lights color set to %s with brightness %d\n", updated, color, brightness)
 			} else {
-				fmt.Printf("Total: %d lights color set to RGB(%d, %d, %d)\n", len(lights), r, g, b)
+				fmt.Printf("Total: %d lights color set to %s\n", updated, color)
 			}
 		}
+		return lightsResult(len(lights), failures)
 	},
 }
 
@@ -572,52 +771,136 @@ func parseHexColor(s string) (r, g, b, a int) {
 }
 
 func isHexColor(s string) bool {
-	matched, _ := regexp.MatchString(`^[0-9a-fA-F]{6}|^[0-9a-fA-F]{3}$`, s)
+	matched, _ := regexp.MatchString(`^([0-9a-fA-F]{3,4}|[0-9a-fA-F]{6}|[0-9a-fA-F]{8})$`, s)
 	return matched
 }
 
 var discoverCmd = &cobra.Command{
 	Use:   "discover",
 	Short: "Discover Hue bridge in network",
-	Long:  `Discover and display information about the Hue bridge in your network.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		bridge, err := huego.Discover()
+	Long:  `Discover and display information about the Hue bridges in your network using mDNS, SSDP and the Philips discovery service. Use --scan to also probe the local networks.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts, err := discoveryFlags(cmd)
 		if err != nil {
-			fmt.Printf("Error discovering bridge: %v\n", err)
-			return
+			return err
+		}
+		bridges := discoverBridges(opts)
+		if len(bridges) == 0 {
+			return unreachableError("no Hue bridge found on the network")
 		}
 
-		fmt.Printf("Bridge found at: %s\n", bridge.Host)
-		fmt.Printf("Bridge ID: %s\n", bridge.ID)
+		return printOutput(bridgesDocument{Bridges: bridges}, func(w io.Writer) {
+			fmt.Fprintln(w, "Host\tBridge ID\tName\tModel\tAPI Version\tFound Via")
+			for _, b := range bridges {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", b.Host, b.ID, b.Name, b.ModelID, b.APIVersion, strings.Join(b.Methods, ", "))
+			}
+		})
+	},
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{onCmd, offCmd, brightnessCmd, colorCmd, tempCmd} {
+		cmd.Flags().String("transition", "", "Fade to the new state over this duration (e.g. 400ms, 2s)")
+	}
+}
 
-		// Try to get bridge info
-		if info, err := bridge.GetConfig(); err == nil {
-			fmt.Printf("Bridge Name: %s\n", info.Name)
-			fmt.Printf("API Version: %s\n", info.APIVersion)
-			fmt.Printf("Software Version: %s\n", info.SwVersion)
+func init() {
+	for _, cmd := range []*cobra.Command{findCmd, discoverCmd} {
+		cmd.Flags().Duration("timeout", 3*time.Second, "How long to wait for discovery responses")
+		cmd.Flags().Bool("scan", false, "Probe every address of the local networks for a bridge")
+		cmd.Flags().String("subnet", "", "Probe this network (CIDR of at most a /24, e.g. 192.168.1.0/24) instead of the local ones")
+		cmd.Flags().Bool("no-cloud", false, "Skip the Philips discovery service (for offline use)")
+	}
+}
+
+// discoveryFlags reads the discovery options of find and discover
+func discoveryFlags(cmd *cobra.Command) (discoveryOptions, error) {
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	scan, _ := cmd.Flags().GetBool("scan")
+	subnet, _ := cmd.Flags().GetString("subnet")
+	noCloud, _ := cmd.Flags().GetBool("no-cloud")
+	if subnet != "" {
+		if _, err := parseProbeSubnet(subnet); err != nil {
+			return discoveryOptions{}, err
 		}
-	},
+	}
+	return discoveryOptions{timeout: timeout, cloud: !noCloud, scan: scan, subnet: subnet}, nil
 }
 
-func findLight(identifier string) *huego.Light {
-	lights := resolveLightIdentifiers([]string{identifier})
+func findLight(identifier string) *Light {
+	lights, _ := resolveLightIdentifiers([]string{identifier})
 	if len(lights) > 0 {
 		return &lights[0]
 	}
 	return nil
 }
 
+// resolveTargets resolves command targets to lights. "all" selects every
+// light; otherwise at least one light must match.
+func resolveTargets(identifiers []string) ([]Light, error) {
+	if len(identifiers) > 0 && identifiers[0] == "all" {
+		lights, err := bridge.GetLights()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get lights: %w", err)
+		}
+		return lights, nil
+	}
+
+	lights, err := resolveLightIdentifiers(identifiers)
+	if err != nil {
+		return nil, err
+	}
+	if len(lights) == 0 {
+		return nil, notFoundError("no lights found for identifiers: %s", strings.Join(identifiers, ", "))
+	}
+	return lights, nil
+}
+
+// setLightsState sends update to every light, calling done for each light
+// that was updated successfully
+func setLightsState(lights []Light, state stateBuilder, done func(Light)) error {
+	var failures []lightFailure
+	for _, light := range lights {
+		if err := state.send(light); err != nil {
+			failures = append(failures, lightFailure{Name: light.Name, Err: err})
+			continue
+		}
+		if done != nil {
+			done(light)
+		}
+	}
+	return lightsResult(len(lights), failures)
+}
+
 // resolveLightIdentifiers resolves a list of identifiers (IDs, names, or groups) to actual lights
-func resolveLightIdentifiers(identifiers []string) []huego.Light {
+func resolveLightIdentifiers(identifiers []string) ([]Light, error) {
 	allLights, err := bridge.GetLights()
 	if err != nil {
-		return nil
+		return nil, fmt.Errorf("failed to get lights: %w", err)
 	}
 
-	var resolvedLights []huego.Light
+	var resolvedLights []Light
 	seenLights := make(map[int]bool) // prevent duplicates
 
 	for _, identifier := range identifiers {
+		// Rooms and zones stored on the bridge (r:name, z:name)
+		if _, _, ok := bridgeGroupIdentifier(identifier); ok {
+			group, err := resolveBridgeGroup(identifier)
+			if err != nil {
+				if exitCode(err) == exitNotFound {
+					continue
+				}
+				return nil, err
+			}
+			for _, light := range bridgeGroupLights(group, allLights) {
+				if !seenLights[light.ID] {
+					resolvedLights = append(resolvedLights, light)
+					seenLights[light.ID] = true
+				}
+			}
+			continue
+		}
+
 		// Check if it's a group reference (starts with "g:")
 		if strings.HasPrefix(identifier, "g:") {
 			groupName := identifier[2:] // remove "g:" prefix
@@ -638,10 +921,10 @@ func resolveLightIdentifiers(identifiers []string) []huego.Light {
 		}
 	}
 
-	return resolvedLights
+	return resolvedLights, nil
 }
 
-func resolveSingleLight(identifier string, allLights []huego.Light) *huego.Light {
+func resolveSingleLight(identifier string, allLights []Light) *Light {
 	// Try to find by ID first
 	if id, err := strconv.Atoi(identifier); err == nil {
 		for _, light := range allLights {
@@ -662,7 +945,7 @@ func resolveSingleLight(identifier string, allLights []huego.Light) *huego.Light
 	return nil
 }
 
-func resolveGroup(groupName string, allLights []huego.Light) []huego.Light {
+func resolveGroup(groupName string, allLights []Light) []Light {
 	config, err := loadGroupConfig()
 	if err != nil {
 		return nil
@@ -681,7 +964,7 @@ func resolveGroup(groupName string, allLights []huego.Light) []huego.Light {
 		return nil
 	}
 
-	var groupLights []huego.Light
+	var groupLights []Light
 	for _, lightIdentifier := range group.Lights {
 		light := resolveSingleLight(lightIdentifier, allLights)
 		if light != nil {
@@ -728,24 +1011,54 @@ func gammaCorrect(value float64) float64 {
 	return value / 12.92
 }
 
-// saveBridgeConfig saves the bridge configuration to disk
+// saveBridgeConfig saves the bridge configuration of the active profile to disk
 func saveBridgeConfig(config BridgeConfig) error {
-	data, err := json.MarshalIndent(config, "", "  ")
+	unlock, err := lockConfig()
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(configFile, data, 0600)
+	defer unlock()
+
+	profiles, err := loadProfileConfig()
+	if os.IsNotExist(err) {
+		profiles = &ProfileConfig{Profiles: map[string]BridgeConfig{}}
+	} else if err != nil {
+		return fmt.Errorf("failed to read %s: %w", configFile, err)
+	}
+
+	name := activeProfile(profiles)
+	profiles.Profiles[name] = config
+	if profiles.Current == "" {
+		profiles.Current = name
+	}
+	return saveProfileConfig(*profiles)
 }
 
-// loadBridgeConfig loads the bridge configuration from disk
+// loadBridgeConfig loads the bridge configuration of the active profile,
+// with HUE_BRIDGE_HOST, HUE_USERNAME and HUE_CLIENTKEY taking precedence. The
+// host and username variables alone are enough to run without a config file.
 func loadBridgeConfig() (BridgeConfig, error) {
-	var config BridgeConfig
-	data, err := os.ReadFile(configFile)
+	config, err := loadSavedBridgeConfig()
+	if err != nil && (os.Getenv(envBridgeHost) == "" || os.Getenv(envUsername) == "") {
+		return BridgeConfig{}, err
+	}
+	applyEnvOverrides(&config)
+	return config, nil
+}
+
+// loadSavedBridgeConfig loads the bridge configuration of the active profile from disk
+func loadSavedBridgeConfig() (BridgeConfig, error) {
+	profiles, err := loadProfileConfig()
 	if err != nil {
-		return config, err
+		return BridgeConfig{}, err
 	}
-	err = json.Unmarshal(data, &config)
-	return config, err
+
+	name := activeProfile(profiles)
+	config, exists := profiles.Profiles[name]
+	if !exists {
+		return BridgeConfig{}, fmt.Errorf("profile '%s' not found", name)
+	}
+	return openProfile(profiles, config)
 }
 
 // Scene commands
@@ -754,13 +1067,16 @@ var sceneCmd = &cobra.Command{
 	Short: "Manage and execute light scenes",
 	Long:  `Create, manage, and execute custom light scenes. Scenes allow you to save and replay complex lighting configurations.`,
 	Args:  cobra.MinimumNArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		if len(args) == 1 {
 			// Execute scene
-			executeScene(args[0])
-		} else {
-			cmd.Help()
+			transition, err := transitionFlag(cmd)
+			if err != nil {
+				return err
+			}
+			return executeScene(args[0], transition)
 		}
+		return cmd.Help()
 	},
 }
 
@@ -770,37 +1086,49 @@ func init() {
 	sceneCmd.AddCommand(sceneListCmd)
 	sceneCmd.AddCommand(sceneRemoveCmd)
 	sceneCmd.AddCommand(scenesListAllCmd)
+	sceneCmd.AddCommand(scenePushCmd)
+	sceneCmd.AddCommand(scenePullCmd)
+	sceneCmd.AddCommand(sceneRecallCmd)
+	sceneCmd.AddCommand(sceneCaptureCmd)
+	sceneCmd.AddCommand(sceneWaitCmd)
+	sceneCmd.AddCommand(sceneRepeatCmd)
+	sceneCmd.AddCommand(sceneValidateCmd)
+
+	sceneCmd.Flags().String("transition", "", "Fade duration for commands that do not set their own (e.g. 2s)")
+	sceneAddCmd.Flags().String("transition", "", "Fade duration stored with the command (e.g. 2s)")
+	sceneAddCmd.Flags().Bool("step", false, "Run the command as a new step after the scene's previous steps")
+	sceneAddCmd.Flags().Bool("parallel", false, "Run the command together with the scene's last step")
 }
 
 var sceneAddCmd = &cobra.Command{
 	Use:   "add [scene-name] [command-type] [light/group] [args...]",
 	Short: "Add a command to a scene",
 	Long: `Add a light command to a scene. Commands will be executed concurrently when the scene is run.
+With --step the command runs as a new step once the previous steps are done, and with
+--parallel it runs together with the last step. Use 'hue scene wait' to pause between steps.
   
 Examples:
   hue scene add "movie-night" color "Living Room" 255 100 50
-  hue scene add "movie-night" brightness "Bedroom" 50
+  hue scene add "movie-night" color "Desk Lamp" coral 180
+  hue scene add "movie-night" brightness "Bedroom" 50 --transition 3s
+  hue scene add "movie-night" temp "Reading Lamp" 2700K
+  hue scene add "movie-night" temp "g:hallway" warm 120
   hue scene add "movie-night" on "Kitchen"
   hue scene add "movie-night" off "g:hallway"
-  hue scene add "movie-night" color "g:living-room" 255 100 50`,
-	Args: cobra.MinimumNArgs(4),
-	Run: func(cmd *cobra.Command, args []string) {
+  hue scene add "movie-night" off "r:Kitchen"
+  hue scene add "movie-night" color "g:living-room" "hsl(20, 90%, 55%)"
+  hue scene add "wake-up" brightness "Bedroom" 150 --step --transition 5m
+  hue scene add "wake-up" on "Hallway" --parallel`,
+	Args: cobra.MinimumNArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
 		sceneName := args[0]
 		commandType := args[1]
 		lightOrGroup := args[2]
 		values := args[3:]
 
-		// Validate command type
-		validTypes := map[string]bool{"on": true, "off": true, "brightness": true, "color": true}
-		if !validTypes[commandType] {
-			fmt.Printf("Invalid command type '%s'. Valid types: on, off, brightness, color\n", commandType)
-			return
-		}
-
-		// Validate arguments based on command type
+		// Validate the command type and its arguments
 		if err := validateSceneCommand(commandType, values); err != nil {
-			fmt.Printf("Error: %v\n", err)
-			return
+			return err
 		}
 
 		// Verify light or group exists
@@ -818,13 +1146,32 @@ Examples:
 			}
 		}
 
+		transition, _ := cmd.Flags().GetString("transition")
+		if transition != "" {
+			if _, err := parseTransition(transition); err != nil {
+				return err
+			}
+		}
+
 		// Add command to scene
-		if err := addCommandToScene(sceneName, commandType, lightOrGroup, values); err != nil {
-			fmt.Printf("Error adding command to scene: %v\n", err)
-			return
+		command := SceneCommand{Type: commandType, Light: lightOrGroup, Values: values, Transition: transition}
+		step, _ := cmd.Flags().GetBool("step")
+		parallel, _ := cmd.Flags().GetBool("parallel")
+		switch {
+		case step && parallel:
+			return fmt.Errorf("--step and --parallel cannot be used together")
+		case step || parallel:
+			if err := addStepToScene(sceneName, SceneStep{Commands: []SceneCommand{command}}, parallel); err != nil {
+				return fmt.Errorf("failed to add command to scene: %w", err)
+			}
+		default:
+			if err := addCommandToScene(sceneName, command); err != nil {
+				return fmt.Errorf("failed to add command to scene: %w", err)
+			}
 		}
 
 		fmt.Printf("Added %s command for '%s' to scene '%s'\n", commandType, lightOrGroup, sceneName)
+		return nil
 	},
 }
 
@@ -833,29 +1180,36 @@ var sceneListCmd = &cobra.Command{
 	Short: "List commands in a scene",
 	Long:  `Display all commands that will be executed when a scene is run.`,
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		sceneName := args[0]
 
 		config, err := loadSceneConfig()
 		if err != nil {
-			fmt.Printf("Error loading scenes: %v\n", err)
-			return
+			return fmt.Errorf("failed to load scenes: %w", err)
 		}
 
 		scene := findScene(config, sceneName)
 		if scene == nil {
-			fmt.Printf("Scene '%s' not found\n", sceneName)
-			return
+			return notFoundError("scene '%s' not found", sceneName)
 		}
 
-		fmt.Printf("Scene '%s' commands:\n", sceneName)
-		for i, command := range scene.Commands {
-			fmt.Printf("%d. %s \"%s\"", i+1, command.Type, command.Light)
-			for _, value := range command.Values {
-				fmt.Printf(" %s", value)
+		return printOutput(scene, func(w io.Writer) {
+			if len(scene.Commands) > 0 || len(scene.Steps) == 0 {
+				fmt.Fprintf(w, "Scene '%s' commands:\n", sceneName)
+				fmt.Fprintln(w, "#\tType\tLight\tValues\tTransition")
+				for i, command := range scene.Commands {
+					fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", i+1, command.Type, command.Light, strings.Join(command.Values, " "), command.Transition)
+				}
 			}
-			fmt.Println()
-		}
+			if len(scene.Steps) > 0 {
+				fmt.Fprintf(w, "Scene '%s' steps:\n", sceneName)
+				fmt.Fprintln(w, "Step\tType\tLight\tValues\tTransition")
+				printSceneSteps(w, scene.Steps, "")
+			}
+			if scene.Repeat > 1 {
+				fmt.Fprintf(w, "Runs %d times\n", scene.Repeat)
+			}
+		})
 	},
 }
 
@@ -866,32 +1220,44 @@ var sceneRemoveCmd = &cobra.Command{
   
 Examples:
   hue scene remove "movie-night" 1    # Remove command at index 1
+  hue scene remove "wake-up" step 2   # Remove step 2
   hue scene remove "movie-night"      # Remove entire scene`,
-	Args: cobra.RangeArgs(1, 2),
-	Run: func(cmd *cobra.Command, args []string) {
+	Args: cobra.RangeArgs(1, 3),
+	RunE: func(cmd *cobra.Command, args []string) error {
 		sceneName := args[0]
 
 		if len(args) == 1 {
 			// Remove entire scene
 			if err := removeScene(sceneName); err != nil {
-				fmt.Printf("Error removing scene: %v\n", err)
-				return
+				return fmt.Errorf("failed to remove scene: %w", err)
 			}
 			fmt.Printf("Scene '%s' removed\n", sceneName)
-		} else {
-			// Remove specific command
-			index, err := strconv.Atoi(args[1])
+			return nil
+		}
+
+		if args[1] == "step" && len(args) == 3 {
+			index, err := strconv.Atoi(args[2])
 			if err != nil {
-				fmt.Printf("Invalid index: %s\n", args[1])
-				return
+				return fmt.Errorf("invalid step index: %s", args[2])
 			}
-
-			if err := removeCommandFromScene(sceneName, index-1); err != nil {
-				fmt.Printf("Error removing command: %v\n", err)
-				return
+			if err := removeStepFromScene(sceneName, index-1); err != nil {
+				return fmt.Errorf("failed to remove step: %w", err)
 			}
-			fmt.Printf("Command %d removed from scene '%s'\n", index, sceneName)
+			fmt.Printf("Step %d removed from scene '%s'\n", index, sceneName)
+			return nil
+		}
+
+		// Remove specific command
+		index, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid index: %s", args[1])
+		}
+
+		if err := removeCommandFromScene(sceneName, index-1); err != nil {
+			return fmt.Errorf("failed to remove command: %w", err)
 		}
+		fmt.Printf("Command %d removed from scene '%s'\n", index, sceneName)
+		return nil
 	},
 }
 
@@ -899,22 +1265,22 @@ var scenesListAllCmd = &cobra.Command{
 	Use:   "scenes",
 	Short: "List all available scenes",
 	Long:  `Display all saved scenes and their command counts.`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		config, err := loadSceneConfig()
 		if err != nil {
-			fmt.Printf("Error loading scenes: %v\n", err)
-			return
-		}
-
-		if len(config.Scenes) == 0 {
-			fmt.Println("No scenes found")
-			return
+			return fmt.Errorf("failed to load scenes: %w", err)
 		}
 
-		fmt.Println("Available scenes:")
-		for _, scene := range config.Scenes {
-			fmt.Printf("  %s (%d commands)\n", scene.Name, len(scene.Commands))
-		}
+		return printOutput(scenesDocument{Scenes: config.Scenes}, func(w io.Writer) {
+			if len(config.Scenes) == 0 {
+				fmt.Fprintln(w, "No scenes found")
+				return
+			}
+			fmt.Fprintln(w, "Name\tCommands\tSteps")
+			for i, scene := range config.Scenes {
+				fmt.Fprintf(w, "%s\t%d\t%d\n", scene.Name, len(sceneCommands(&config.Scenes[i])), len(scene.Steps))
+			}
+		})
 	},
 }
 
@@ -934,34 +1300,41 @@ func validateSceneCommand(commandType string, values []string) error {
 			return fmt.Errorf("brightness must be a number between 0 and 254")
 		}
 	case "color":
-		if len(values) != 3 && len(values) != 4 {
-			return fmt.Errorf("color command requires 3 arguments (red green blue) or 4 arguments (red green blue brightness)")
-		}
-		for i, value := range values {
-			if i < 3 {
-				// RGB values
-				colorValue, err := strconv.Atoi(value)
-				if err != nil || colorValue < 0 || colorValue > 255 {
-					colors := []string{"red", "green", "blue"}
-					return fmt.Errorf("%s value must be a number between 0 and 255", colors[i])
-				}
-			} else {
-				// Brightness value (4th parameter)
-				brightness, err := strconv.Atoi(value)
-				if err != nil || brightness < 0 || brightness > 254 {
-					return fmt.Errorf("brightness value must be a number between 0 and 254")
-				}
+		if _, _, err := parseColorArgs(values); err != nil {
+			return err
+		}
+	case "temp":
+		if len(values) != 1 && len(values) != 2 {
+			return fmt.Errorf("temp command requires 1 argument (2700K, 370mired or a preset) or 2 arguments (temperature brightness)")
+		}
+		if _, err := parseColorTemperature(values[0]); err != nil {
+			return err
+		}
+		if len(values) == 2 {
+			brightness, err := strconv.Atoi(values[1])
+			if err != nil || brightness < 0 || brightness > 254 {
+				return fmt.Errorf("brightness value must be a number between 0 and 254")
 			}
 		}
+	default:
+		return fmt.Errorf("invalid command type '%s' (valid types: on, off, brightness, color, temp)", commandType)
 	}
 	return nil
 }
 
-func addCommandToScene(sceneName, commandType, light string, values []string) error {
-	config, err := loadSceneConfig()
+func addCommandToScene(sceneName string, command SceneCommand) error {
+	unlock, err := lockConfig()
 	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	config, err := loadSceneConfig()
+	if os.IsNotExist(err) {
 		// Create new config if file doesn't exist
 		config = &SceneConfig{Scenes: []Scene{}}
+	} else if err != nil {
+		return err
 	}
 
 	// Find or create scene
@@ -975,17 +1348,18 @@ func addCommandToScene(sceneName, commandType, light string, values []string) er
 	}
 
 	// Add command to scene
-	command := SceneCommand{
-		Type:   commandType,
-		Light:  light,
-		Values: values,
-	}
 	scene.Commands = append(scene.Commands, command)
 
 	return saveSceneConfig(*config)
 }
 
 func removeCommandFromScene(sceneName string, index int) error {
+	unlock, err := lockConfig()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	config, err := loadSceneConfig()
 	if err != nil {
 		return err
@@ -993,7 +1367,7 @@ func removeCommandFromScene(sceneName string, index int) error {
 
 	scene := findScene(config, sceneName)
 	if scene == nil {
-		return fmt.Errorf("scene '%s' not found", sceneName)
+		return notFoundError("scene '%s' not found", sceneName)
 	}
 
 	if index < 0 || index >= len(scene.Commands) {
@@ -1007,6 +1381,12 @@ func removeCommandFromScene(sceneName string, index int) error {
 }
 
 func removeScene(sceneName string) error {
+	unlock, err := lockConfig()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	config, err := loadSceneConfig()
 	if err != nil {
 		return err
@@ -1022,7 +1402,7 @@ func removeScene(sceneName string) error {
 	}
 
 	if sceneIndex == -1 {
-		return fmt.Errorf("scene '%s' not found", sceneName)
+		return notFoundError("scene '%s' not found", sceneName)
 	}
 
 	// Remove scene
@@ -1031,102 +1411,180 @@ func removeScene(sceneName string) error {
 	return saveSceneConfig(*config)
 }
 
-func executeScene(sceneName string) error {
+// executeScene runs every command of a scene. transition, when set, is used
+// for commands without a transition of their own.
+func executeScene(sceneName string, transition *uint16) error {
 	config, err := loadSceneConfig()
+	if os.IsNotExist(err) {
+		return notFoundError("scene '%s' not found", sceneName)
+	}
 	if err != nil {
-		fmt.Printf("Error loading scenes: %v\n", err)
-		return err
+		return fmt.Errorf("failed to load scenes: %w", err)
 	}
 
 	scene := findScene(config, sceneName)
 	if scene == nil {
-		fmt.Printf("Scene '%s' not found\n", sceneName)
-		return fmt.Errorf("scene not found")
+		return notFoundError("scene '%s' not found", sceneName)
+	}
+	if err := checkStoredScene(config, scene); err != nil {
+		return err
 	}
 
-	if len(scene.Commands) == 0 {
+	if len(scene.Commands) == 0 && len(scene.Steps) == 0 {
 		fmt.Printf("Scene '%s' has no commands\n", sceneName)
 		return nil
 	}
 
-	fmt.Printf("Executing scene '%s' with %d commands...\n", sceneName, len(scene.Commands))
+	if len(scene.Steps) == 0 {
+		fmt.Printf("Executing scene '%s' with %d commands...\n", sceneName, len(scene.Commands))
+	} else {
+		fmt.Printf("Executing scene '%s' with %d steps...\n", sceneName, len(scene.Steps))
+	}
+
+	// Commands run concurrently, then the steps one after another
+	runner := sceneRunner{transition: transition}
+	for i := 0; i < max(scene.Repeat, 1); i++ {
+		runner.runCommands(scene.Commands)
+		runner.runSteps(scene.Steps)
+	}
 
-	// Execute all commands concurrently
-	results := make(chan string, len(scene.Commands))
+	fmt.Printf("Scene '%s' executed: %d/%d commands successful\n", sceneName, runner.succeeded, runner.total)
 
-	for _, command := range scene.Commands {
-		go func(cmd SceneCommand) {
-			result := executeSceneCommand(cmd)
-			results <- result
-		}(command)
+	switch {
+	case runner.firstErr == nil:
+		return nil
+	case runner.succeeded == 0:
+		return fmt.Errorf("scene '%s' failed: %w", sceneName, runner.firstErr)
+	default:
+		return partialFailureError("scene '%s': %d/%d commands failed", sceneName, runner.total-runner.succeeded, runner.total)
 	}
+}
 
-	// Collect results
-	successCount := 0
-	for i := 0; i < len(scene.Commands); i++ {
-		result := <-results
-		if result == "success" {
-			successCount++
-		} else {
-			fmt.Printf("  %s\n", result)
+// executeSceneCommand applies a single scene command to its lights.
+// defaultTransition is used when the command has no transition.
+func executeSceneCommand(command SceneCommand, defaultTransition *uint16) error {
+	state, color, err := sceneCommandState(command, defaultTransition)
+	if err != nil {
+		return fmt.Errorf("'%s': %w", command.Light, err)
+	}
+
+	// On, off and brightness are the same for every light, so a bridge
+	// group gets them in a single request
+	if command.Type == "on" || command.Type == "off" || command.Type == "brightness" {
+		if groupID, ok := bridgeGroupTarget([]string{command.Light}); ok {
+			if err := state.sendToGroup(groupID); err != nil {
+				return fmt.Errorf("'%s': %w", command.Light, err)
+			}
+			return nil
+		}
+	}
+
+	lights, skipped, err := sceneCommandLights(command, color)
+	if err != nil {
+		return err
+	}
+
+	// Colors and temperatures go to a bridge group in one request too, unless
+	// some of its lights cannot show them and would only be turned on
+	if groupID, ok := bridgeGroupTarget([]string{command.Light}); ok && len(skipped) == 0 {
+		if err := sceneLightState(command, state, color, Light{}).sendToGroup(groupID); err != nil {
+			return fmt.Errorf("'%s': %w", command.Light, err)
 		}
+		return nil
 	}
 
-	fmt.Printf("Scene '%s' executed: %d/%d commands successful\n", sceneName, successCount, len(scene.Commands))
+	var failures []lightFailure
+	for _, light := range lights {
+		if err := sceneLightState(command, state, color, light).send(light); err != nil {
+			failures = append(failures, lightFailure{Name: light.Name, Err: err})
+		}
+	}
+
+	if err := lightsResult(len(lights), failures); err != nil {
+		return fmt.Errorf("'%s': %w", command.Light, err)
+	}
 	return nil
 }
 
-func executeSceneCommand(command SceneCommand) string {
-	// Resolve lights (supports both single lights and groups)
-	lights := resolveLightIdentifiers([]string{command.Light})
-	if len(lights) == 0 {
-		return fmt.Sprintf("Error: Light/Group '%s' not found", command.Light)
+// sceneCommandState builds the state change of a scene command. For color
+// commands the returned color still has to be applied per light.
+func sceneCommandState(command SceneCommand, defaultTransition *uint16) (stateBuilder, lightColor, error) {
+	if err := validateSceneCommand(command.Type, command.Values); err != nil {
+		return stateBuilder{}, lightColor{}, err
 	}
 
-	var errors []string
-	successCount := 0
+	transition := defaultTransition
+	if command.Transition != "" {
+		steps, err := parseTransition(command.Transition)
+		if err != nil {
+			return stateBuilder{}, lightColor{}, err
+		}
+		transition = uint16Ptr(steps)
+	}
 
-	for _, light := range lights {
+	var color lightColor
+	colorBrightness := -1
+	if command.Type == "color" {
 		var err error
-		switch command.Type {
-		case "on":
-			err = light.On()
-		case "off":
-			err = light.Off()
-		case "brightness":
-			brightness, _ := strconv.Atoi(command.Values[0])
-			err = light.Bri(uint8(brightness))
-		case "color":
-			r, _ := strconv.Atoi(command.Values[0])
-			g, _ := strconv.Atoi(command.Values[1])
-			b, _ := strconv.Atoi(command.Values[2])
-			x, y := rgbToXY(uint8(r), uint8(g), uint8(b))
-			err = light.Xy([]float32{x, y})
-			// Handle optional brightness parameter
-			if len(command.Values) == 4 {
-				brightness, _ := strconv.Atoi(command.Values[3])
-				if err == nil {
-					err = light.Bri(uint8(brightness))
-				}
-			}
+		if color, colorBrightness, err = parseColorArgs(command.Values); err != nil {
+			return stateBuilder{}, lightColor{}, err
 		}
+	}
 
-		if err != nil {
-			errors = append(errors, fmt.Sprintf("Error with '%s': %v", light.Name, err))
-		} else {
-			successCount++
+	// Each command is sent as a single state change so attributes fade together
+	state := newState().On(command.Type != "off").Transition(transition)
+	switch command.Type {
+	case "brightness":
+		brightness, _ := strconv.Atoi(command.Values[0])
+		state = state.Bri(uint8(brightness))
+	case "color":
+		// Handle optional brightness parameter
+		if colorBrightness >= 0 {
+			state = state.Bri(uint8(colorBrightness))
+		}
+	case "temp":
+		mired, _ := parseColorTemperature(command.Values[0])
+		state = state.Ct(mired)
+		if len(command.Values) == 2 {
+			brightness, _ := strconv.Atoi(command.Values[1])
+			state = state.Bri(uint8(brightness))
 		}
 	}
+	return state, color, nil
+}
 
-	if len(errors) > 0 {
-		if successCount > 0 {
-			return fmt.Sprintf("Partial success for '%s' (%d/%d lights): %s", command.Light, successCount, len(lights), strings.Join(errors, "; "))
-		} else {
-			return fmt.Sprintf("Failed for '%s': %s", command.Light, strings.Join(errors, "; "))
+// sceneCommandLights resolves the lights of a scene command, leaving out
+// lights without color or color temperature support, which are returned
+// as skipped
+func sceneCommandLights(command SceneCommand, color lightColor) (lights, skipped []Light, err error) {
+	// Resolve lights (supports both single lights and groups)
+	lights, err = resolveTargets([]string{command.Light})
+	if err != nil {
+		return nil, nil, fmt.Errorf("'%s': %w", command.Light, err)
+	}
+
+	switch command.Type {
+	case "color":
+		if lights, skipped = color.capableLights(lights); len(lights) == 0 {
+			return nil, nil, fmt.Errorf("'%s': none of the lights support %s", command.Light, color.capability())
+		}
+	case "temp":
+		if lights, skipped = temperatureLights(lights); len(lights) == 0 {
+			return nil, nil, fmt.Errorf("'%s': none of the lights support color temperature", command.Light)
 		}
 	}
+	return lights, skipped, nil
+}
 
-	return "success"
+// sceneLightState returns the state a scene command gives one light
+func sceneLightState(command SceneCommand, state stateBuilder, color lightColor, light Light) stateBuilder {
+	switch {
+	case command.Type == "color":
+		return color.applyTo(state, light)
+	case command.Type == "temp" && state.update.Ct != nil:
+		return state.Ct(lightMired(light, *state.update.Ct))
+	}
+	return state
 }
 
 func findScene(config *SceneConfig, sceneName string) *Scene {
@@ -1138,22 +1596,29 @@ func findScene(config *SceneConfig, sceneName string) *Scene {
 	return nil
 }
 
+// loadSceneConfig reads the scene file, migrating older versions. Only a
+// file that cannot be decoded is rejected; the values of a scene are
+// checked by checkStoredScene when it is used, so listing and editing the
+// other scenes keeps working.
 func loadSceneConfig() (*SceneConfig, error) {
-	var config SceneConfig
 	data, err := os.ReadFile(sceneFile)
 	if err != nil {
 		return nil, err
 	}
-	err = json.Unmarshal(data, &config)
-	return &config, err
+	config, problems := parseSceneConfig(data)
+	if len(problems) > 0 {
+		return nil, &configFileError{File: sceneFile, Problems: problems}
+	}
+	return config, nil
 }
 
 func saveSceneConfig(config SceneConfig) error {
+	config.Version = sceneFileVersion
 	data, err := json.MarshalIndent(config, "", "  ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(sceneFile, data, 0600)
+	return writeConfigFile(sceneFile, data)
 }
 
 // Group commands
@@ -1169,6 +1634,9 @@ func init() {
 	groupCmd.AddCommand(groupListCmd)
 	groupCmd.AddCommand(groupRemoveCmd)
 	groupCmd.AddCommand(groupsListAllCmd)
+	groupCmd.AddCommand(groupRoomsCmd)
+	groupCmd.AddCommand(groupSyncCmd)
+	groupCmd.AddCommand(groupUnsyncCmd)
 }
 
 var groupAddCmd = &cobra.Command{
@@ -1181,15 +1649,14 @@ Examples:
   hue group add "bedroom" "Bedside Left" "Bedside Right"
   hue group add "kitchen" 5 "Kitchen Counter" 7`,
 	Args: cobra.MinimumNArgs(2),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		groupName := args[0]
 		lightIdentifiers := args[1:]
 
 		// Verify all lights exist
 		allLights, err := bridge.GetLights()
 		if err != nil {
-			fmt.Printf("Error getting lights: %v\n", err)
-			return
+			return fmt.Errorf("failed to get lights: %w", err)
 		}
 
 		var validLights []string
@@ -1203,17 +1670,17 @@ Examples:
 		}
 
 		if len(validLights) == 0 {
-			fmt.Println("No valid lights found")
-			return
+			return notFoundError("no valid lights found")
 		}
 
 		// Add lights to group
 		if err := addLightsToGroup(groupName, validLights); err != nil {
-			fmt.Printf("Error adding lights to group: %v\n", err)
-			return
+			return fmt.Errorf("failed to add lights to group: %w", err)
 		}
 
 		fmt.Printf("Added %d lights to group '%s'\n", len(validLights), groupName)
+		warnGroupOutOfSync(groupName)
+		return nil
 	},
 }
 
@@ -1222,36 +1689,46 @@ var groupListCmd = &cobra.Command{
 	Short: "List lights in a group",
 	Long:  `Display all lights that belong to a specific group.`,
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		groupName := args[0]
 
 		config, err := loadGroupConfig()
 		if err != nil {
-			fmt.Printf("Error loading groups: %v\n", err)
-			return
+			return fmt.Errorf("failed to load groups: %w", err)
 		}
 
 		group := findGroup(config, groupName)
 		if group == nil {
-			fmt.Printf("Group '%s' not found\n", groupName)
-			return
+			return notFoundError("group '%s' not found", groupName)
 		}
 
-		fmt.Printf("Group '%s' lights:\n", groupName)
 		allLights, err := bridge.GetLights()
 		if err != nil {
-			fmt.Printf("Error getting lights: %v\n", err)
-			return
+			return fmt.Errorf("failed to get lights: %w", err)
 		}
 
-		for i, lightIdentifier := range group.Lights {
-			light := resolveSingleLight(lightIdentifier, allLights)
-			if light != nil {
-				fmt.Printf("%d. %s (ID: %d)\n", i+1, light.Name, light.ID)
-			} else {
-				fmt.Printf("%d. %s (NOT FOUND)\n", i+1, lightIdentifier)
+		doc := groupLightsDocument{Name: group.Name, Lights: []groupLightDocument{}}
+		for _, lightIdentifier := range group.Lights {
+			entry := groupLightDocument{Identifier: lightIdentifier}
+			if light := resolveSingleLight(lightIdentifier, allLights); light != nil {
+				entry.Found = true
+				entry.ID = light.ID
+				entry.Name = light.Name
 			}
+			doc.Lights = append(doc.Lights, entry)
 		}
+
+		return printOutput(doc, func(w io.Writer) {
+			fmt.Fprintf(w, "Group '%s' lights:\n", groupName)
+			fmt.Fprintln(w, "#\tName\tID")
+			for i, entry := range doc.Lights {
+				if entry.Found {
+					fmt.Fprintf(w, "%d\t%s\t%d\n", i+1, entry.Name, entry.ID)
+				} else {
+					fmt.Fprintf(w, "%d\t%s\tNOT FOUND\n", i+1, entry.Identifier)
+				}
+			}
+		})
 	},
 }
 
@@ -1264,30 +1741,43 @@ Examples:
   hue group remove "living-room" 1    # Remove light at index 1
   hue group remove "living-room"      # Remove entire group`,
 	Args: cobra.RangeArgs(1, 2),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		groupName := args[0]
 
 		if len(args) == 1 {
+			// A synced group takes its bridge light group with it
+			if config, err := loadGroupConfig(); err == nil {
+				if group := findGroup(config, groupName); group != nil && group.BridgeID != "" {
+					if err := initBridge(); err != nil {
+						return err
+					}
+					if err := deleteSyncedGroup(group.BridgeID); err != nil {
+						return err
+					}
+					fmt.Printf("Bridge group %s deleted\n", group.BridgeID)
+				}
+			}
+
 			// Remove entire group
 			if err := removeGroup(groupName); err != nil {
-				fmt.Printf("Error removing group: %v\n", err)
-				return
+				return fmt.Errorf("failed to remove group: %w", err)
 			}
 			fmt.Printf("Group '%s' removed\n", groupName)
-		} else {
-			// Remove specific light
-			index, err := strconv.Atoi(args[1])
-			if err != nil {
-				fmt.Printf("Invalid index: %s\n", args[1])
-				return
-			}
+			return nil
+		}
 
-			if err := removeLightFromGroup(groupName, index-1); err != nil {
-				fmt.Printf("Error removing light: %v\n", err)
-				return
-			}
-			fmt.Printf("Light %d removed from group '%s'\n", index, groupName)
+		// Remove specific light
+		index, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid index: %s", args[1])
+		}
+
+		if err := removeLightFromGroup(groupName, index-1); err != nil {
+			return fmt.Errorf("failed to remove light: %w", err)
 		}
+		fmt.Printf("Light %d removed from group '%s'\n", index, groupName)
+		warnGroupOutOfSync(groupName)
+		return nil
 	},
 }
 
@@ -1295,31 +1785,39 @@ var groupsListAllCmd = &cobra.Command{
 	Use:   "groups",
 	Short: "List all available groups",
 	Long:  `Display all saved groups and their light counts.`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		config, err := loadGroupConfig()
 		if err != nil {
-			fmt.Printf("Error loading groups: %v\n", err)
-			return
-		}
-
-		if len(config.Groups) == 0 {
-			fmt.Println("No groups found")
-			return
+			return fmt.Errorf("failed to load groups: %w", err)
 		}
 
-		fmt.Println("Available groups:")
-		for _, group := range config.Groups {
-			fmt.Printf("  %s (%d lights)\n", group.Name, len(group.Lights))
-		}
+		return printOutput(groupsDocument{Groups: config.Groups}, func(w io.Writer) {
+			if len(config.Groups) == 0 {
+				fmt.Fprintln(w, "No groups found")
+				return
+			}
+			fmt.Fprintln(w, "Name\tLights\tBridge Group")
+			for _, group := range config.Groups {
+				fmt.Fprintf(w, "%s\t%d\t%s\n", group.Name, len(group.Lights), group.BridgeID)
+			}
+		})
 	},
 }
 
 // Group helper functions
 func addLightsToGroup(groupName string, lightIdentifiers []string) error {
-	config, err := loadGroupConfig()
+	unlock, err := lockConfig()
 	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	config, err := loadGroupConfig()
+	if os.IsNotExist(err) {
 		// Create new config if file doesn't exist
 		config = &GroupConfig{Groups: []Group{}}
+	} else if err != nil {
+		return err
 	}
 
 	// Find or create group
@@ -1349,6 +1847,12 @@ func addLightsToGroup(groupName string, lightIdentifiers []string) error {
 }
 
 func removeLightFromGroup(groupName string, index int) error {
+	unlock, err := lockConfig()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	config, err := loadGroupConfig()
 	if err != nil {
 		return err
@@ -1356,7 +1860,7 @@ func removeLightFromGroup(groupName string, index int) error {
 
 	group := findGroup(config, groupName)
 	if group == nil {
-		return fmt.Errorf("group '%s' not found", groupName)
+		return notFoundError("group '%s' not found", groupName)
 	}
 
 	if index < 0 || index >= len(group.Lights) {
@@ -1370,6 +1874,12 @@ func removeLightFromGroup(groupName string, index int) error {
 }
 
 func removeGroup(groupName string) error {
+	unlock, err := lockConfig()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	config, err := loadGroupConfig()
 	if err != nil {
 		return err
@@ -1385,7 +1895,7 @@ func removeGroup(groupName string) error {
 	}
 
 	if groupIndex == -1 {
-		return fmt.Errorf("group '%s' not found", groupName)
+		return notFoundError("group '%s' not found", groupName)
 	}
 
 	// Remove group
@@ -1403,63 +1913,92 @@ func findGroup(config *GroupConfig, groupName string) *Group {
 	return nil
 }
 
+// loadGroupConfig reads the group file, migrating older versions
 func loadGroupConfig() (*GroupConfig, error) {
-	var config GroupConfig
 	data, err := os.ReadFile(groupFile)
 	if err != nil {
 		return nil, err
 	}
-	err = json.Unmarshal(data, &config)
-	return &config, err
+	config, problems := parseGroupConfig(data)
+	if len(problems) > 0 {
+		return nil, &configFileError{File: groupFile, Problems: problems}
+	}
+	return config, nil
 }
 
 func saveGroupConfig(config GroupConfig) error {
+	config.Version = groupFileVersion
 	data, err := json.MarshalIndent(config, "", "  ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(groupFile, data, 0600)
+	return writeConfigFile(groupFile, data)
 }
 
-// createUserWithClientKey creates a new bridge user with entertainment streaming support
-func createUserWithClientKey(host string) (string, string, error) {
-	payload := map[string]interface{}{
-		"devicetype":        "hue_cli#device",
-		"generateclientkey": true,
+// defaultLinkWait is how long hue waits for the link button to be pressed
+const defaultLinkWait = 30 * time.Second
+
+// waitForLinkButton asks for the bridge's link button to be pressed and
+// creates a user named after device once it is, trying every second until
+// wait has passed. Attempts the bridge does not answer are tried again too,
+// as it may be briefly out of reach. On a terminal the time left is counted
+// down.
+func waitForLinkButton(host, device string, wait time.Duration) (string, string, error) {
+	fmt.Println("\nPress the link button on your Hue bridge now...")
+	countdown := term.IsTerminal(int(os.Stdout.Fd()))
+	if !countdown {
+		fmt.Printf("Waiting up to %s for authorization...\n", wait)
 	}
 
-	data, _ := json.Marshal(payload)
+	deadline := time.Now().Add(wait)
+	var err error
+	for {
+		if countdown {
+			fmt.Printf("\rWaiting for the link button... %s left ", time.Until(deadline).Round(time.Second))
+		}
 
-	// Build URL - handle host that may already have http://
-	var url string
-	if strings.HasPrefix(host, "http://") || strings.HasPrefix(host, "https://") {
-		url = host + "/api"
-	} else {
-		url = "http://" + host + "/api"
-	}
+		var username, clientKey string
+		username, clientKey, err = createUserWithClientKey(host, deviceType(device))
+		if err == nil {
+			fmt.Println()
+			return username, clientKey, nil
+		}
+		var bridgeErr *BridgeError
+		if !errors.As(err, &bridgeErr) || bridgeErr.Type != bridgeErrLinkButtonPressed {
+			if !isTransportError(err) {
+				fmt.Println()
+				return "", "", fmt.Errorf("failed to create a bridge user: %w", err)
+			}
+		}
 
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(data))
-	if err != nil {
-		return "", "", err
+		if time.Until(deadline) < time.Second {
+			break
+		}
+		time.Sleep(time.Second)
+		if !countdown {
+			fmt.Print(".")
+		}
 	}
-	defer resp.Body.Close()
 
-	body, _ := io.ReadAll(resp.Body)
-	var result []map[string]interface{}
-	if err := json.Unmarshal(body, &result); err != nil {
-		return "", "", err
+	if isTransportError(err) {
+		fmt.Println()
+		return "", "", unreachableError("failed to reach the bridge at %s: %w", host, err)
 	}
+	fmt.Println("\nMake sure you pressed the link button on your bridge.")
+	fmt.Println("If the problem persists, try:")
+	fmt.Println("1. Wait a minute and try again, or give more time with --wait")
+	fmt.Println("2. Check that no other apps are trying to connect")
+	return "", "", notAuthorizedError("failed to authorize: %w", err)
+}
 
-	if len(result) > 0 {
-		if success, ok := result[0]["success"].(map[string]interface{}); ok {
-			username, _ := success["username"].(string)
-			clientkey, _ := success["clientkey"].(string)
-			return username, clientkey, nil
-		}
-		if errMsg, ok := result[0]["error"].(map[string]interface{}); ok {
-			return "", "", fmt.Errorf("%v", errMsg["description"])
-		}
-	}
+// isTransportError reports whether err means the bridge did not answer,
+// rather than that it refused the request
+func isTransportError(err error) bool {
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
 
-	return "", "", fmt.Errorf("unexpected response from bridge")
+// createUserWithClientKey creates a new bridge user with entertainment streaming support
+func createUserWithClientKey(host, deviceType string) (string, string, error) {
+	return newBridgeClient(host, "").CreateUser(deviceType)
 }
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// useFakeBridge points the commands at a new fake bridge and the config
+// files at a temporary directory for the duration of the test
+func useFakeBridge(t *testing.T) *fakeBridge {
+	t.Helper()
+
+	previousBridge, previousDir, previousFile := bridge, configDir, configFile
+	t.Cleanup(func() {
+		bridge, configDir, configFile = previousBridge, previousDir, previousFile
+		setProfilePaths(defaultProfile)
+	})
+
+	configDir = t.TempDir()
+	configFile = filepath.Join(configDir, "config.json")
+	setProfilePaths(defaultProfile)
+
+	fake := newFakeBridge()
+	fake.openAccess = true
+	bridge = newFakeClient(fake, "test")
+	return fake
+}
+
+// fakeLight returns the current state of a light of the fake bridge
+func fakeLight(t *testing.T, fake *fakeBridge, id int) Light {
+	t.Helper()
+	lights, err := fake.getLights("test")
+	if err != nil {
+		t.Fatalf("getLights: %v", err)
+	}
+	for _, light := range lights {
+		if light.ID == id {
+			return light
+		}
+	}
+	t.Fatalf("light %d not found", id)
+	return Light{}
+}
+
+// checkExitCode fails the test unless err maps to the expected exit code
+func checkExitCode(t *testing.T, err error, want int) {
+	t.Helper()
+	if got := exitCode(err); got != want {
+		t.Fatalf("exit code = %d, want %d (err: %v)", got, want, err)
+	}
+}
+
+func TestOnCmd(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		wantOn []int
+		code   int
+	}{
+		{name: "by name", args: []string{"Desk Lamp"}, wantOn: []int{3}},
+		{name: "by id", args: []string{"2"}, wantOn: []int{2}},
+		{name: "several", args: []string{"1", "Hallway"}, wantOn: []int{1, 4}},
+		{name: "all", args: []string{"all"}, wantOn: []int{1, 2, 3, 4, 5}},
+		{name: "bridge room", args: []string{"r:Living Room"}, wantOn: []int{1, 3}},
+		{name: "unknown light", args: []string{"Garage"}, code: exitNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := useFakeBridge(t)
+			checkExitCode(t, onCmd.RunE(onCmd, test.args), test.code)
+
+			on := map[int]bool{}
+			for _, id := range test.wantOn {
+				on[id] = true
+			}
+			for id := 1; id <= 5; id++ {
+				if got := fakeLight(t, fake, id).State.On; got != on[id] {
+					t.Errorf("light %d on = %t, want %t", id, got, on[id])
+				}
+			}
+		})
+	}
+}
+
+func TestColorCmd(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		light     int
+		colorMode string
+		bri       uint8
+		code      int
+	}{
+		{name: "named color", args: []string{"Desk Lamp", "red"}, light: 3, colorMode: "xy", bri: 254},
+		{name: "hex with brightness", args: []string{"1", "#00ff00", "100"}, light: 1, colorMode: "xy", bri: 100},
+		{name: "rgb values", args: []string{"2", "0", "0", "255"}, light: 2, colorMode: "xy", bri: 254},
+		{name: "temperature light", args: []string{"Bedroom Ambiance", "ct:2700K"}, light: 5, colorMode: "ct", bri: 254},
+		{name: "color on a white light", args: []string{"Hallway", "red"}, light: 4, code: exitError},
+		{name: "unknown color", args: []string{"Desk Lamp", "notacolor"}, light: 3, code: exitError},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := useFakeBridge(t)
+			checkExitCode(t, colorCmd.RunE(colorCmd, test.args), test.code)
+
+			light := fakeLight(t, fake, test.light)
+			if test.code != exitOK {
+				if light.State.On {
+					t.Errorf("light %d was turned on", test.light)
+				}
+				return
+			}
+			if !light.State.On || light.State.ColorMode != test.colorMode || light.State.Bri != test.bri {
+				t.Errorf("light %d = on %t, colormode %q, bri %d; want on, %q, %d",
+					test.light, light.State.On, light.State.ColorMode, light.State.Bri, test.colorMode, test.bri)
+			}
+		})
+	}
+}
+
+func TestExecuteScene(t *testing.T) {
+	tests := []struct {
+		name     string
+		commands []SceneCommand
+		scene    string
+		wantOn   []int
+		wantBri  map[int]uint8
+		code     int
+	}{
+		{
+			name: "commands",
+			commands: []SceneCommand{
+				{Type: "on", Light: "Desk Lamp"},
+				{Type: "brightness", Light: "Kitchen Ceiling", Values: []string{"100"}},
+				{Type: "color", Light: "1", Values: []string{"coral"}},
+			},
+			wantOn:  []int{1, 2, 3},
+			wantBri: map[int]uint8{2: 100},
+		},
+		{
+			name:     "bridge group",
+			commands: []SceneCommand{{Type: "brightness", Light: "r:Living Room", Values: []string{"50"}}},
+			wantOn:   []int{1, 3},
+			wantBri:  map[int]uint8{1: 50, 3: 50},
+		},
+		{
+			name: "some commands fail",
+			commands: []SceneCommand{
+				{Type: "on", Light: "Hallway"},
+				{Type: "on", Light: "Garage"},
+			},
+			wantOn: []int{4},
+			code:   exitPartialFailure,
+		},
+		{name: "unknown scene", scene: "missing", code: exitNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := useFakeBridge(t)
+			if err := saveSceneConfig(SceneConfig{Scenes: []Scene{{Name: "test", Commands: test.commands}}}); err != nil {
+				t.Fatal(err)
+			}
+
+			scene := test.scene
+			if scene == "" {
+				scene = "test"
+			}
+			checkExitCode(t, executeScene(scene, nil), test.code)
+
+			on := map[int]bool{}
+			for _, id := range test.wantOn {
+				on[id] = true
+			}
+			for id := 1; id <= 5; id++ {
+				light := fakeLight(t, fake, id)
+				if light.State.On != on[id] {
+					t.Errorf("light %d on = %t, want %t", id, light.State.On, on[id])
+				}
+				if bri, ok := test.wantBri[id]; ok && light.State.Bri != bri {
+					t.Errorf("light %d bri = %d, want %d", id, light.State.Bri, bri)
+				}
+			}
+		})
+	}
+}
+
+func TestExecuteSceneChecksOnlyItsScene(t *testing.T) {
+	useFakeBridge(t)
+	// The alias 'brand' was removed after the scene was saved
+	if err := saveSceneConfig(SceneConfig{Scenes: []Scene{
+		{Name: "good", Commands: []SceneCommand{{Type: "on", Light: "Desk Lamp", Values: []string{}}}},
+		{Name: "accent", Commands: []SceneCommand{{Type: "color", Light: "1", Values: []string{"brand"}}}},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := loadSceneConfig()
+	if err != nil || len(config.Scenes) != 2 {
+		t.Fatalf("loadSceneConfig = %+v, %v; want both scenes", config, err)
+	}
+	if err := executeScene("good", nil); err != nil {
+		t.Errorf("running a valid scene: %v", err)
+	}
+
+	err = executeScene("accent", nil)
+	fileErr, ok := err.(*configFileError)
+	if !ok || len(fileErr.Problems) != 1 || fileErr.Problems[0].Path != "scenes[1].commands[0]" || fileErr.Problems[0].Line == 0 {
+		t.Errorf("err = %#v, want the problem of the accent scene with its line", err)
+	}
+}
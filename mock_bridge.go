@@ -0,0 +1,701 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/dtls/v2"
+	"github.com/pion/transport/v2/udp"
+	"github.com/spf13/cobra"
+)
+
+var mockBridgeCmd = &cobra.Command{
+	Use:   "mock-bridge",
+	Short: "Run a local Hue bridge emulator",
+	Long: `Run an emulated Hue bridge serving the v1 REST API and the Entertainment DTLS endpoint.
+
+The emulator serves /api, /api/<user>/config, /api/<user>/lights and
+/api/<user>/groups (including Entertainment groups with stream.active), the
+CLIP v2 lights, grouped lights, scenes and entertainment configurations under
+/clip/v2/resource, and listens for PSK DTLS streaming connections. Decoded stream frames are
+printed as they arrive.
+
+Like a real bridge the API is served over HTTPS with a self-signed certificate
+issued to the bridge ID, which hue pins on first use. Use --http to serve plain
+HTTP instead (and pair with --ip http://127.0.0.1:<port>).
+
+User creation requires the simulated link button: press Enter in the
+emulator terminal or POST to /linkbutton to press it for 30 seconds, or
+start with --auto-link to keep it pressed.
+
+Examples:
+  hue mock-bridge --port 8000 --auto-link
+  hue auth --ip 127.0.0.1:8000
+  hue entertain stream effect "TV Area" rainbow`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		port, _ := cmd.Flags().GetInt("port")
+		autoLink, _ := cmd.Flags().GetBool("auto-link")
+		plainHTTP, _ := cmd.Flags().GetBool("http")
+
+		fake := newFakeBridge()
+		fake.linkButton = autoLink
+		server := &mockBridgeServer{bridge: fake}
+
+		if err := server.listenStream(); err != nil {
+			return fmt.Errorf("failed to start DTLS endpoint: %w", err)
+		}
+
+		scheme, authHost := "https", fmt.Sprintf("127.0.0.1:%d", port)
+		if plainHTTP {
+			scheme, authHost = "http", "http://"+authHost
+		}
+		fmt.Printf("🌉 Mock bridge REST API on %s://127.0.0.1:%d/api\n", scheme, port)
+		fmt.Printf("📡 Entertainment DTLS endpoint on udp://127.0.0.1:%d\n", entertainmentStreamPort)
+		if autoLink {
+			fmt.Println("🔗 Link button: always pressed")
+		} else {
+			fmt.Println("🔗 Link button: press Enter (or POST /linkbutton) to press it for 30 seconds")
+			go server.readLinkButton(os.Stdin)
+		}
+		fmt.Printf("\nAuthorize with: hue auth --ip %s\n\n", authHost)
+
+		address := fmt.Sprintf(":%d", port)
+		var err error
+		if plainHTTP {
+			err = http.ListenAndServe(address, server)
+		} else {
+			var cert tls.Certificate
+			if cert, err = bridgeCertificate(fake.info.BridgeID); err != nil {
+				return fmt.Errorf("failed to create certificate: %w", err)
+			}
+			httpServer := &http.Server{Addr: address, Handler: server, TLSConfig: &tls.Config{Certificates: []tls.Certificate{cert}}}
+			err = httpServer.ListenAndServeTLS("", "")
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return fmt.Errorf("failed to start server: %w", err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	mockBridgeCmd.Flags().IntP("port", "p", 8000, "Port for the REST API")
+	mockBridgeCmd.Flags().Bool("auto-link", false, "Keep the link button pressed so user creation always succeeds")
+	mockBridgeCmd.Flags().Bool("http", false, "Serve the REST API over plain HTTP instead of HTTPS")
+}
+
+// bridgeCertificate creates a self-signed certificate issued to the bridge
+// ID, like the one a real bridge presents
+func bridgeCertificate(bridgeID string) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: strings.ToLower(bridgeID), Organization: []string{"Philips Hue"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(10, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// mockBridgeServer serves a fakeBridge over the v1 REST API and DTLS
+type mockBridgeServer struct {
+	bridge      *fakeBridge
+	linkMutex   sync.Mutex
+	linkRelease *time.Timer
+}
+
+// pressLinkButton presses the simulated link button for 30 seconds
+func (s *mockBridgeServer) pressLinkButton() {
+	s.linkMutex.Lock()
+	defer s.linkMutex.Unlock()
+
+	s.bridge.setLinkButton(true)
+	if s.linkRelease != nil {
+		s.linkRelease.Stop()
+	}
+	s.linkRelease = time.AfterFunc(30*time.Second, func() {
+		s.bridge.setLinkButton(false)
+		fmt.Println("🔗 Link button released")
+	})
+	fmt.Println("🔗 Link button pressed (30 seconds)")
+}
+
+func (s *mockBridgeServer) readLinkButton(in io.Reader) {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		s.pressLinkButton()
+	}
+}
+
+func (s *mockBridgeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fmt.Printf("%s %s\n", r.Method, r.URL.Path)
+
+	if r.URL.Path == "/linkbutton" && r.Method == "POST" {
+		s.pressLinkButton()
+		writeJSON(w, []map[string]interface{}{{"success": map[string]bool{"/config/linkbutton": true}}})
+		return
+	}
+
+	if strings.HasPrefix(r.URL.Path, "/clip/v2/resource") {
+		s.handleClip(w, r)
+		return
+	}
+
+	// Path segments after /api: [username, resource, id, sub]
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if len(parts) == 0 || parts[0] != "api" {
+		http.NotFound(w, r)
+		return
+	}
+	parts = parts[1:]
+	for len(parts) > 0 && parts[len(parts)-1] == "" && len(parts) > 1 {
+		parts = parts[:len(parts)-1]
+	}
+
+	// POST /api creates a user
+	if len(parts) == 0 || (len(parts) == 1 && parts[0] == "") {
+		if r.Method != "POST" {
+			writeBridgeError(w, &BridgeError{Type: 4, Address: "/", Description: "method, " + r.Method + ", not available for resource, /"})
+			return
+		}
+		s.handleCreateUser(w, r)
+		return
+	}
+
+	username := parts[0]
+	resource := ""
+	if len(parts) > 1 {
+		resource = parts[1]
+	}
+
+	if resource == "config" && len(parts) == 4 && parts[2] == "whitelist" && r.Method == "DELETE" {
+		if err := newFakeClient(s.bridge, username).DeleteUser(parts[3]); err != nil {
+			writeBridgeError(w, err)
+			return
+		}
+		fmt.Printf("🗑️  Deleted user %s\n", parts[3])
+		writeJSON(w, []map[string]string{{"success": "/config/whitelist/" + parts[3] + " deleted"}})
+		return
+	}
+
+	// The configuration is partially readable without a whitelisted user
+	if resource == "config" || username == "config" {
+		writeJSON(w, s.bridge.config(username))
+		return
+	}
+
+	client := newFakeClient(s.bridge, username)
+	switch resource {
+	case "lights":
+		s.handleLights(w, r, client, parts[2:])
+	case "groups":
+		s.handleGroups(w, r, client, parts[2:])
+	case "scenes":
+		s.handleScenes(w, r, client, parts[2:])
+	case "":
+		if err := s.bridge.authorize(username); err != nil {
+			writeBridgeError(w, err)
+			return
+		}
+		lights, _ := client.GetLights()
+		groups, _ := client.GetGroups()
+		writeJSON(w, map[string]interface{}{
+			"lights": lightsByID(lights),
+			"groups": groupsByID(groups),
+			"config": s.bridge.info,
+		})
+	default:
+		writeBridgeError(w, &BridgeError{
+			Type:        bridgeErrResourceNotFound,
+			Address:     "/" + resource,
+			Description: fmt.Sprintf("resource, /%s, not available", resource),
+		})
+	}
+}
+
+func (s *mockBridgeServer) handleCreateUser(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		DeviceType        string `json:"devicetype"`
+		GenerateClientKey bool   `json:"generateclientkey"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeBridgeError(w, &BridgeError{Type: 2, Address: "/", Description: "body contains invalid json"})
+		return
+	}
+
+	username, clientKey, err := s.bridge.createUser(payload.DeviceType)
+	if err != nil {
+		writeBridgeError(w, err)
+		return
+	}
+
+	success := map[string]string{"username": username}
+	if payload.GenerateClientKey {
+		success["clientkey"] = clientKey
+	}
+	fmt.Printf("✅ Created user %s (%s)\n", username, payload.DeviceType)
+	writeJSON(w, []map[string]interface{}{{"success": success}})
+}
+
+func (s *mockBridgeServer) handleLights(w http.ResponseWriter, r *http.Request, client *fakeClient, rest []string) {
+	lights, err := client.GetLights()
+	if err != nil {
+		writeBridgeError(w, err)
+		return
+	}
+
+	if len(rest) == 0 {
+		writeJSON(w, lightsByID(lights))
+		return
+	}
+
+	id, _ := strconv.Atoi(rest[0])
+	var light *Light
+	for i := range lights {
+		if lights[i].ID == id {
+			light = &lights[i]
+			break
+		}
+	}
+	if light == nil {
+		writeBridgeError(w, &BridgeError{
+			Type:        bridgeErrResourceNotFound,
+			Address:     "/lights/" + rest[0],
+			Description: fmt.Sprintf("resource, /lights/%s, not available", rest[0]),
+		})
+		return
+	}
+
+	if len(rest) == 1 && r.Method == "GET" {
+		writeJSON(w, newV1Light(*light))
+		return
+	}
+
+	if len(rest) == 2 && rest[1] == "state" && r.Method == "PUT" {
+		body, _ := readBody(r)
+		var update StateUpdate
+		if err := json.Unmarshal(body, &update); err != nil {
+			writeBridgeError(w, &BridgeError{Type: 2, Address: "/lights/" + rest[0] + "/state", Description: "body contains invalid json"})
+			return
+		}
+		if err := client.SetLightState(id, update); err != nil {
+			writeBridgeError(w, err)
+			return
+		}
+		writeSuccessList(w, fmt.Sprintf("/lights/%d/state", id), body)
+		return
+	}
+
+	writeBridgeError(w, &BridgeError{Type: 4, Address: r.URL.Path, Description: "method, " + r.Method + ", not available for resource"})
+}
+
+func (s *mockBridgeServer) handleGroups(w http.ResponseWriter, r *http.Request, client *fakeClient, rest []string) {
+	if len(rest) == 0 {
+		switch r.Method {
+		case "GET":
+			groups, err := client.GetGroups()
+			if err != nil {
+				writeBridgeError(w, err)
+				return
+			}
+			writeJSON(w, groupsByID(groups))
+		case "POST":
+			var group BridgeGroup
+			if err := json.NewDecoder(r.Body).Decode(&group); err != nil {
+				writeBridgeError(w, &BridgeError{Type: 2, Address: "/groups", Description: "body contains invalid json"})
+				return
+			}
+			id, err := client.CreateGroup(group)
+			if err != nil {
+				writeBridgeError(w, err)
+				return
+			}
+			writeJSON(w, []map[string]interface{}{{"success": map[string]string{"id": id}}})
+		default:
+			writeBridgeError(w, &BridgeError{Type: 4, Address: "/groups", Description: "method, " + r.Method + ", not available for resource, /groups"})
+		}
+		return
+	}
+
+	groupID := rest[0]
+	if len(rest) == 2 && rest[1] == "action" && r.Method == "PUT" {
+		body, _ := readBody(r)
+		var action struct {
+			StateUpdate
+			Scene string `json:"scene"`
+		}
+		if err := json.Unmarshal(body, &action); err != nil {
+			writeBridgeError(w, &BridgeError{Type: 2, Address: "/groups/" + groupID + "/action", Description: "body contains invalid json"})
+			return
+		}
+		var err error
+		if action.Scene != "" {
+			err = client.RecallScene(groupID, action.Scene)
+		} else {
+			err = client.SetGroupAction(groupID, action.StateUpdate)
+		}
+		if err != nil {
+			writeBridgeError(w, err)
+			return
+		}
+		writeSuccessList(w, "/groups/"+groupID+"/action", body)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		groups, err := client.GetGroups()
+		if err != nil {
+			writeBridgeError(w, err)
+			return
+		}
+		for _, group := range groups {
+			if group.ID == groupID {
+				writeJSON(w, group)
+				return
+			}
+		}
+		writeBridgeError(w, &BridgeError{
+			Type:        bridgeErrResourceNotFound,
+			Address:     "/groups/" + groupID,
+			Description: fmt.Sprintf("resource, /groups/%s, not available", groupID),
+		})
+	case "PUT":
+		body, _ := readBody(r)
+		var payload struct {
+			Name   string   `json:"name"`
+			Lights []string `json:"lights"`
+			Stream *struct {
+				Active *bool `json:"active"`
+			} `json:"stream"`
+		}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			writeBridgeError(w, &BridgeError{Type: 2, Address: "/groups/" + groupID, Description: "body contains invalid json"})
+			return
+		}
+		if payload.Name != "" || payload.Lights != nil {
+			if err := client.UpdateGroup(groupID, BridgeGroup{Name: payload.Name, Lights: payload.Lights}); err != nil {
+				writeBridgeError(w, err)
+				return
+			}
+		}
+		if payload.Stream != nil && payload.Stream.Active != nil {
+			if err := client.SetStreamActive(groupID, *payload.Stream.Active); err != nil {
+				writeBridgeError(w, err)
+				return
+			}
+			if *payload.Stream.Active {
+				fmt.Printf("▶️  Streaming activated for group %s\n", groupID)
+			} else {
+				fmt.Printf("⏹️  Streaming deactivated for group %s\n", groupID)
+			}
+		}
+		writeSuccessList(w, "/groups/"+groupID, body)
+	case "DELETE":
+		if err := client.DeleteGroup(groupID); err != nil {
+			writeBridgeError(w, err)
+			return
+		}
+		writeJSON(w, []map[string]interface{}{{"success": "/groups/" + groupID + " deleted"}})
+	default:
+		writeBridgeError(w, &BridgeError{Type: 4, Address: "/groups/" + groupID, Description: "method, " + r.Method + ", not available for resource"})
+	}
+}
+
+func (s *mockBridgeServer) handleScenes(w http.ResponseWriter, r *http.Request, client *fakeClient, rest []string) {
+	if len(rest) == 0 {
+		switch r.Method {
+		case "GET":
+			scenes, err := client.GetScenes()
+			if err != nil {
+				writeBridgeError(w, err)
+				return
+			}
+			result := make(map[string]BridgeScene, len(scenes))
+			for _, scene := range scenes {
+				result[scene.ID] = scene
+			}
+			writeJSON(w, result)
+		case "POST":
+			var scene BridgeScene
+			if err := json.NewDecoder(r.Body).Decode(&scene); err != nil {
+				writeBridgeError(w, &BridgeError{Type: 2, Address: "/scenes", Description: "body contains invalid json"})
+				return
+			}
+			id, err := client.CreateScene(scene)
+			if err != nil {
+				writeBridgeError(w, err)
+				return
+			}
+			writeJSON(w, []map[string]interface{}{{"success": map[string]string{"id": id}}})
+		default:
+			writeBridgeError(w, &BridgeError{Type: 4, Address: "/scenes", Description: "method, " + r.Method + ", not available for resource, /scenes"})
+		}
+		return
+	}
+
+	sceneID := rest[0]
+	switch r.Method {
+	case "GET":
+		scene, err := client.GetScene(sceneID)
+		if err != nil {
+			writeBridgeError(w, err)
+			return
+		}
+		writeJSON(w, scene)
+	case "PUT":
+		body, _ := readBody(r)
+		var scene BridgeScene
+		if err := json.Unmarshal(body, &scene); err != nil {
+			writeBridgeError(w, &BridgeError{Type: 2, Address: "/scenes/" + sceneID, Description: "body contains invalid json"})
+			return
+		}
+		if err := client.UpdateScene(sceneID, scene); err != nil {
+			writeBridgeError(w, err)
+			return
+		}
+		writeSuccessList(w, "/scenes/"+sceneID, body)
+	default:
+		writeBridgeError(w, &BridgeError{Type: 4, Address: "/scenes/" + sceneID, Description: "method, " + r.Method + ", not available for resource"})
+	}
+}
+
+func lightsByID(lights []Light) map[string]v1Light {
+	result := make(map[string]v1Light, len(lights))
+	for _, light := range lights {
+		result[strconv.Itoa(light.ID)] = newV1Light(light)
+	}
+	return result
+}
+
+func groupsByID(groups []BridgeGroup) map[string]BridgeGroup {
+	result := make(map[string]BridgeGroup, len(groups))
+	for _, group := range groups {
+		result[group.ID] = group
+	}
+	return result
+}
+
+func readBody(r *http.Request) ([]byte, error) {
+	var buf bytes.Buffer
+	_, err := buf.ReadFrom(r.Body)
+	return buf.Bytes(), err
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeBridgeError writes an error the way the bridge does: HTTP 200 with
+// a list holding a single error object
+func writeBridgeError(w http.ResponseWriter, err error) {
+	bridgeErr, ok := err.(*BridgeError)
+	if !ok {
+		bridgeErr = &BridgeError{Type: 901, Address: "/", Description: err.Error()}
+	}
+	writeJSON(w, []map[string]interface{}{{"error": bridgeErr}})
+}
+
+// writeSuccessList acknowledges every attribute of a state update
+func writeSuccessList(w http.ResponseWriter, address string, body []byte) {
+	var attrs map[string]interface{}
+	json.Unmarshal(body, &attrs)
+
+	result := []map[string]interface{}{}
+	for key, value := range attrs {
+		result = append(result, map[string]interface{}{
+			"success": map[string]interface{}{address + "/" + key: value},
+		})
+	}
+	writeJSON(w, result)
+}
+
+// listenStream starts the Entertainment DTLS endpoint on the port of a real
+// bridge, where hue connects to stream. Clients authenticate with their
+// username as PSK identity and their clientkey as the key.
+func (s *mockBridgeServer) listenStream() error {
+	config := &dtls.Config{
+		PSK: func(identity []byte) ([]byte, error) {
+			key, ok := s.bridge.clientKey(string(identity))
+			if !ok {
+				return nil, fmt.Errorf("unknown PSK identity %q", string(identity))
+			}
+			return hex.DecodeString(key)
+		},
+		CipherSuites:         []dtls.CipherSuiteID{dtls.TLS_PSK_WITH_AES_128_GCM_SHA256},
+		ExtendedMasterSecret: dtls.RequireExtendedMasterSecret,
+	}
+
+	listener, err := dtls.Listen("udp", &net.UDPAddr{IP: net.IPv4zero, Port: entertainmentStreamPort}, config)
+	if err != nil {
+		return err
+	}
+
+	go s.acceptStreams(listener)
+	return nil
+}
+
+// acceptStreams serves DTLS clients until the listener is closed. Accept
+// also runs the handshake, so a failed handshake only skips that client;
+// repeated errors are slowed down the way net/http does.
+func (s *mockBridgeServer) acceptStreams(listener net.Listener) {
+	var delay time.Duration
+	for {
+		conn, err := listener.Accept()
+		if errors.Is(err, net.ErrClosed) || errors.Is(err, udp.ErrClosedListener) {
+			return
+		}
+		if err != nil {
+			fmt.Printf("DTLS accept error: %v\n", err)
+			if delay == 0 {
+				delay = 5 * time.Millisecond
+			} else if delay *= 2; delay > time.Second {
+				delay = time.Second
+			}
+			time.Sleep(delay)
+			continue
+		}
+		delay = 0
+		go s.handleStream(conn)
+	}
+}
+
+func (s *mockBridgeServer) handleStream(conn net.Conn) {
+	defer conn.Close()
+
+	identity := ""
+	if dtlsConn, ok := conn.(*dtls.Conn); ok {
+		identity = string(dtlsConn.ConnectionState().IdentityHint)
+	}
+	if !s.streamOwner(identity) {
+		fmt.Printf("❌ DTLS client %s has no active streaming session, closing\n", identity)
+		return
+	}
+	fmt.Printf("✅ DTLS stream connected (%s)\n", identity)
+
+	buf := make([]byte, 2048)
+	lastPrint := time.Time{}
+	frames := 0
+	for {
+		// The bridge ends the session after 10 seconds without data
+		conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+		n, err := conn.Read(buf)
+		if err != nil {
+			fmt.Printf("⏹️  DTLS stream closed after %d frames (%v)\n", frames, err)
+			return
+		}
+
+		frame, err := decodeStreamMessage(buf[:n])
+		if err != nil {
+			fmt.Printf("⚠️  Invalid stream message: %v\n", err)
+			continue
+		}
+		frames++
+
+		// Throttle output; streams run at up to 60 frames per second
+		if time.Since(lastPrint) >= 250*time.Millisecond {
+			fmt.Println(frame)
+			lastPrint = time.Now()
+		}
+	}
+}
+
+// streamOwner reports whether username owns an active streaming session
+func (s *mockBridgeServer) streamOwner(username string) bool {
+	groups, err := newFakeClient(s.bridge, username).GetGroups()
+	if err != nil {
+		return false
+	}
+	for _, group := range groups {
+		if group.Stream != nil && group.Stream.Active && group.Stream.Owner == username {
+			return true
+		}
+	}
+	return false
+}
+
+// streamFrame is a decoded HueStream v1 message
+type streamFrame struct {
+	Version    string
+	Sequence   uint8
+	ColorSpace uint8
+	Lights     []streamLight
+}
+
+type streamLight struct {
+	ID      uint16
+	R, G, B uint16
+}
+
+func (f *streamFrame) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "🎨 seq=%3d", f.Sequence)
+	for _, light := range f.Lights {
+		if f.ColorSpace == 0x00 {
+			fmt.Fprintf(&sb, "  %d:#%02X%02X%02X", light.ID, light.R>>8, light.G>>8, light.B>>8)
+		} else {
+			fmt.Fprintf(&sb, "  %d:xyb(%d,%d,%d)", light.ID, light.R, light.G, light.B)
+		}
+	}
+	return sb.String()
+}
+
+// decodeStreamMessage parses a message produced by buildStreamMessage
+func decodeStreamMessage(data []byte) (*streamFrame, error) {
+	const headerSize = 16
+	const lightSize = 9
+
+	if len(data) < headerSize || string(data[:9]) != "HueStream" {
+		return nil, fmt.Errorf("missing HueStream header")
+	}
+	if (len(data)-headerSize)%lightSize != 0 {
+		return nil, fmt.Errorf("unexpected message length %d", len(data))
+	}
+
+	frame := &streamFrame{
+		Version:    fmt.Sprintf("%d.%d", data[9], data[10]),
+		Sequence:   data[11],
+		ColorSpace: data[14],
+	}
+
+	for offset := headerSize; offset < len(data); offset += lightSize {
+		record := data[offset : offset+lightSize]
+		frame.Lights = append(frame.Lights, streamLight{
+			ID: binary.BigEndian.Uint16(record[1:3]),
+			R:  binary.BigEndian.Uint16(record[3:5]),
+			G:  binary.BigEndian.Uint16(record[5:7]),
+			B:  binary.BigEndian.Uint16(record[7:9]),
+		})
+	}
+	return frame, nil
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// clipResourceID returns a stable UUID for a resource of the fake bridge, so
+// the same light keeps its ID between emulator runs
+func clipResourceID(rtype, v1ID string) string {
+	sum := sha1.Sum([]byte(rtype + "/" + v1ID))
+	sum[6] = sum[6]&0x0f | 0x50 // version 5
+	sum[8] = sum[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+// newClipLight converts a light to its CLIP v2 light, device and
+// zigbee_connectivity resources
+func newClipLight(light Light) []clipResource {
+	id := strconv.Itoa(light.ID)
+	device := clipReference{RID: clipResourceID("device", id), RType: "device"}
+	connectivity := clipReference{RID: clipResourceID("zigbee_connectivity", id), RType: "zigbee_connectivity"}
+
+	resource := clipResource{
+		ID:       clipResourceID("light", id),
+		IDV1:     "/lights/" + id,
+		Type:     "light",
+		Owner:    &device,
+		Metadata: &clipMetadata{Name: light.Name, Archetype: "sultan_bulb"},
+		On:       &clipOn{On: light.State.On},
+		Dimming:  &clipDimming{Brightness: briToPercent(light.State.Bri)},
+	}
+	if lightSupportsColor(light.Type) && len(light.State.Xy) == 2 {
+		resource.Color = &clipColor{XY: clipXY{X: float64(light.State.Xy[0]), Y: float64(light.State.Xy[1])}}
+		if caps := light.Capabilities; caps != nil && len(caps.Gamut) == 3 {
+			resource.Color.GamutType = caps.GamutType
+			resource.Color.Gamut = &clipGamut{
+				Red:   clipXY{X: caps.Gamut[0][0], Y: caps.Gamut[0][1]},
+				Green: clipXY{X: caps.Gamut[1][0], Y: caps.Gamut[1][1]},
+				Blue:  clipXY{X: caps.Gamut[2][0], Y: caps.Gamut[2][1]},
+			}
+		}
+	}
+	if lightSupportsCt(light.Type) {
+		ct := light.State.Ct
+		resource.ColorTemperature = &clipColorTemperature{Mirek: &ct, MirekValid: light.State.ColorMode == "ct"}
+		if caps := light.Capabilities; caps != nil && caps.CtMax != 0 {
+			resource.ColorTemperature.MirekSchema = &clipMirekSchema{Minimum: caps.CtMin, Maximum: caps.CtMax}
+		}
+	}
+
+	status := "connected"
+	if !light.State.Reachable {
+		status = "connectivity_issue"
+	}
+	return []clipResource{
+		resource,
+		{
+			ID:          device.RID,
+			IDV1:        "/lights/" + id,
+			Type:        "device",
+			Metadata:    &clipMetadata{Name: light.Name, Archetype: "sultan_bulb"},
+			ProductData: &clipProductData{ModelID: light.ModelID},
+			Services:    []clipReference{{RID: resource.ID, RType: "light"}, connectivity},
+		},
+		{ID: connectivity.RID, Type: "zigbee_connectivity", Owner: &device, Status: status},
+	}
+}
+
+// newClipGroup converts a room, zone or entertainment area to CLIP v2.
+// Other v1 group types have no CLIP v2 counterpart.
+func newClipGroup(group BridgeGroup) []clipResource {
+	v1ID := "/groups/" + group.ID
+	switch group.Type {
+	case "Room", "Zone":
+		rtype := strings.ToLower(group.Type)
+		owner := clipReference{RID: clipResourceID(rtype, group.ID), RType: rtype}
+		grouped := clipReference{RID: clipResourceID("grouped_light", group.ID), RType: "grouped_light"}
+
+		var children []clipReference
+		for _, lightID := range group.Lights {
+			if rtype == "room" {
+				children = append(children, clipReference{RID: clipResourceID("device", lightID), RType: "device"})
+			} else {
+				children = append(children, clipReference{RID: clipResourceID("light", lightID), RType: "light"})
+			}
+		}
+		return []clipResource{
+			{ID: owner.RID, IDV1: v1ID, Type: rtype, Metadata: &clipMetadata{Name: group.Name}, Children: children, Services: []clipReference{grouped}},
+			{ID: grouped.RID, IDV1: v1ID, Type: "grouped_light", Owner: &owner},
+		}
+	case "Entertainment":
+		status := "inactive"
+		if group.Stream != nil && group.Stream.Active {
+			status = "active"
+		}
+		return []clipResource{{
+			ID:       clipResourceID("entertainment_configuration", group.ID),
+			IDV1:     v1ID,
+			Type:     "entertainment_configuration",
+			Metadata: &clipMetadata{Name: group.Name},
+			Status:   status,
+		}}
+	}
+	return nil
+}
+
+// clipResources lists every resource of the fake bridge in CLIP v2 form
+func (s *mockBridgeServer) clipResources(client *fakeClient) ([]clipResource, error) {
+	lights, err := client.GetLights()
+	if err != nil {
+		return nil, err
+	}
+	groups, _ := client.GetGroups()
+	scenes, _ := client.GetScenes()
+	info, _ := client.GetConfig()
+
+	home := clipReference{RID: clipResourceID("bridge_home", allLightsGroup), RType: "bridge_home"}
+	allLights := clipReference{RID: clipResourceID("grouped_light", allLightsGroup), RType: "grouped_light"}
+	resources := []clipResource{
+		{ID: clipResourceID("bridge", info.BridgeID), Type: "bridge", BridgeID: strings.ToLower(info.BridgeID)},
+		{ID: home.RID, IDV1: "/groups/" + allLightsGroup, Type: "bridge_home", Services: []clipReference{allLights}},
+		{ID: allLights.RID, IDV1: "/groups/" + allLightsGroup, Type: "grouped_light", Owner: &home},
+	}
+	for _, light := range lights {
+		resources = append(resources, newClipLight(light)...)
+	}
+
+	groupTypes := map[string]string{}
+	for _, group := range groups {
+		groupTypes[group.ID] = strings.ToLower(group.Type)
+		resources = append(resources, newClipGroup(group)...)
+	}
+	// Only scenes of a room or zone exist in CLIP v2
+	for _, scene := range scenes {
+		rtype := groupTypes[scene.Group]
+		if scene.Type != "GroupScene" || (rtype != "room" && rtype != "zone") {
+			continue
+		}
+		resources = append(resources, clipResource{
+			ID:       clipResourceID("scene", scene.ID),
+			IDV1:     "/scenes/" + scene.ID,
+			Type:     "scene",
+			Metadata: &clipMetadata{Name: scene.Name},
+			Group:    &clipReference{RID: clipResourceID(rtype, scene.Group), RType: rtype},
+		})
+	}
+	return resources, nil
+}
+
+// handleClip serves /clip/v2/resource[/<type>[/<id>]]
+func (s *mockBridgeServer) handleClip(w http.ResponseWriter, r *http.Request) {
+	client := newFakeClient(s.bridge, r.Header.Get("hue-application-key"))
+	resources, err := s.clipResources(client)
+	if err != nil {
+		writeClipError(w, err)
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/clip/v2/resource"), "/")
+	var rtype, id string
+	if path != "" {
+		rtype, id, _ = strings.Cut(path, "/")
+	}
+
+	var matches []clipResource
+	for _, resource := range resources {
+		if (rtype == "" || resource.Type == rtype) && (id == "" || resource.ID == id) {
+			matches = append(matches, resource)
+		}
+	}
+	if id != "" && len(matches) == 0 {
+		writeClipError(w, &BridgeError{Type: bridgeErrResourceNotFound, Description: "Not Found"})
+		return
+	}
+
+	switch {
+	case r.Method == "GET":
+		if matches == nil {
+			matches = []clipResource{}
+		}
+		writeClipData(w, matches)
+	case r.Method == "PUT" && id != "":
+		if err := s.updateClipResource(r, client, matches[0]); err != nil {
+			writeClipError(w, err)
+			return
+		}
+		writeClipData(w, []clipReference{{RID: id, RType: rtype}})
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		writeJSON(w, map[string]interface{}{"data": []interface{}{}, "errors": []map[string]string{{"description": "method not allowed"}}})
+	}
+}
+
+// updateClipResource applies a PUT to a light, grouped_light, scene or
+// entertainment_configuration through the v1 operations of the fake
+func (s *mockBridgeServer) updateClipResource(r *http.Request, client *fakeClient, resource clipResource) error {
+	body, _ := readBody(r)
+	invalid := &BridgeError{Type: 2, Description: "body contains invalid json"}
+	v1ID := resource.IDV1[strings.LastIndex(resource.IDV1, "/")+1:]
+
+	switch resource.Type {
+	case "light", "grouped_light":
+		var update clipLightUpdate
+		if err := json.Unmarshal(body, &update); err != nil {
+			return invalid
+		}
+		if resource.Type == "grouped_light" {
+			return client.SetGroupAction(v1ID, update.toStateUpdate())
+		}
+		id, _ := strconv.Atoi(v1ID)
+		return client.SetLightState(id, update.toStateUpdate())
+	case "scene":
+		var payload struct {
+			Recall *clipAction `json:"recall"`
+		}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return invalid
+		}
+		if payload.Recall == nil {
+			return nil
+		}
+		scene, err := client.GetScene(v1ID)
+		if err != nil {
+			return err
+		}
+		return client.RecallScene(scene.Group, v1ID)
+	case "entertainment_configuration":
+		var payload clipAction
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return invalid
+		}
+		active := payload.Action == "start"
+		if err := client.SetStreamActive(v1ID, active); err != nil {
+			return err
+		}
+		if active {
+			fmt.Printf("▶️  Streaming activated for group %s\n", v1ID)
+		} else {
+			fmt.Printf("⏹️  Streaming deactivated for group %s\n", v1ID)
+		}
+		return nil
+	}
+	return &BridgeError{Type: 4, Description: "method, PUT, not available for resource, " + resource.Type}
+}
+
+func writeClipData(w http.ResponseWriter, data interface{}) {
+	writeJSON(w, map[string]interface{}{"data": data, "errors": []interface{}{}})
+}
+
+// writeClipError writes an error the way CLIP v2 does: an HTTP error status
+// and the description in the errors list
+func writeClipError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	if bridgeErr, ok := err.(*BridgeError); ok {
+		switch bridgeErr.Type {
+		case bridgeErrUnauthorized:
+			status = http.StatusForbidden
+		case bridgeErrResourceNotFound:
+			status = http.StatusNotFound
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": []interface{}{}, "errors": []map[string]string{{"description": err.Error()}}})
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// startPlainMockBridge serves a new fake bridge over plain HTTP and returns
+// the host to reach it at
+func startPlainMockBridge(t *testing.T) (string, *fakeBridge) {
+	t.Helper()
+	fake := newFakeBridge()
+	server := httptest.NewServer(&mockBridgeServer{bridge: fake})
+	t.Cleanup(server.Close)
+	return server.URL, fake
+}
+
+func TestMockBridgePairing(t *testing.T) {
+	host, _ := startPlainMockBridge(t)
+
+	_, _, err := newBridgeClient(host, "").CreateUser("hue_cli#test")
+	checkExitCode(t, err, exitNotAuthorized)
+
+	resp, err := http.Post(host+"/linkbutton", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	username, clientKey, err := newBridgeClient(host, "").CreateUser("hue_cli#test")
+	if err != nil || username == "" || len(clientKey) != 32 {
+		t.Fatalf("CreateUser = %q, %q, %v; want a user with a clientkey", username, clientKey, err)
+	}
+	if _, err := newBridgeClient(host, username).GetLights(); err != nil {
+		t.Errorf("GetLights as the new user: %v", err)
+	}
+	_, err = newBridgeClient(host, "someone-else").GetLights()
+	checkExitCode(t, err, exitNotAuthorized)
+}
+
+func TestMockBridgeRESTAPI(t *testing.T) {
+	host, fake := startPlainMockBridge(t)
+	fake.openAccess = true
+	client := newBridgeClient(host, "test")
+
+	lights, err := client.GetLights()
+	if err != nil || len(lights) != 5 || lights[2].Name != "Desk Lamp" {
+		t.Fatalf("GetLights = %+v, %v", lights, err)
+	}
+
+	if err := client.SetLightState(3, StateUpdate{On: boolPtr(true), Bri: uint8Ptr(100)}); err != nil {
+		t.Fatal(err)
+	}
+	if state := fakeLight(t, fake, 3).State; !state.On || state.Bri != 100 {
+		t.Errorf("light 3 = %+v, want on at 100", state)
+	}
+	checkExitCode(t, client.SetLightState(42, StateUpdate{On: boolPtr(true)}), exitNotFound)
+
+	if err := client.SetGroupAction("1", StateUpdate{On: boolPtr(true)}); err != nil {
+		t.Fatal(err)
+	}
+	if !fakeLight(t, fake, 1).State.On {
+		t.Error("light 1 is off after switching on its room")
+	}
+
+	if err := client.SetStreamActive("3", true); err != nil {
+		t.Fatalf("SetStreamActive: %v", err)
+	}
+	area := fakeGroupByName(t, fake, "TV Area")
+	if area.Stream == nil || !area.Stream.Active || area.Stream.Owner != "test" {
+		t.Errorf("TV Area = %+v, want streaming owned by the test user", area)
+	}
+}
+
+func TestDecodeStreamMessage(t *testing.T) {
+	tests := []struct {
+		name     string
+		lights   []string
+		colors   map[string]RGB
+		sequence uint8
+		want     []streamLight
+	}{
+		{
+			name:     "every light colored",
+			lights:   []string{"1", "3"},
+			colors:   map[string]RGB{"1": {R: 255, G: 0, B: 10}, "3": {R: 1, G: 128, B: 255}},
+			sequence: 7,
+			want:     []streamLight{{ID: 1, R: 0xFF00, B: 0x0A00}, {ID: 3, R: 0x0100, G: 0x8000, B: 0xFF00}},
+		},
+		{
+			name:     "missing lights are black",
+			lights:   []string{"17", "300"},
+			colors:   map[string]RGB{"300": {G: 255}},
+			sequence: 255,
+			want:     []streamLight{{ID: 17}, {ID: 300, G: 0xFF00}},
+		},
+		{name: "no lights", colors: map[string]RGB{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stream := &DTLSStream{area: &EntertainmentArea{Lights: test.lights}, sequenceNum: test.sequence}
+			frame, err := decodeStreamMessage(stream.buildStreamMessage(test.colors).Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := &streamFrame{Version: "1.0", Sequence: test.sequence, ColorSpace: 0x00, Lights: test.want}
+			if !reflect.DeepEqual(frame, want) {
+				t.Errorf("decoded %+v, want %+v", frame, want)
+			}
+		})
+	}
+}
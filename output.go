@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// outputFormat is set by the global --output flag
+var outputFormat string
+
+func validateOutputFormat() error {
+	switch outputFormat {
+	case "table", "json", "yaml":
+		return nil
+	}
+	return fmt.Errorf("unknown output format '%s' (valid formats: table, json, yaml)", outputFormat)
+}
+
+// collectOutput, when set, receives the JSON and YAML documents instead of
+// stdout. --all-bridges uses it to print one document keyed by profile.
+var collectOutput func(doc interface{})
+
+// printOutput renders doc as JSON or YAML. For the default table format it
+// calls table with a writer that aligns tab-separated columns.
+func printOutput(doc interface{}, table func(w io.Writer)) error {
+	if collectOutput != nil && outputFormat != "table" {
+		collectOutput(doc)
+		return nil
+	}
+
+	switch outputFormat {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(doc)
+	case "yaml":
+		encoder := yaml.NewEncoder(os.Stdout)
+		encoder.SetIndent(2)
+		defer encoder.Close()
+		return encoder.Encode(doc)
+	default:
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		table(tw)
+		return tw.Flush()
+	}
+}
+
+// Documents rendered by the listing commands
+
+type lightsDocument struct {
+	Lights []lightDocument `json:"lights" yaml:"lights"`
+}
+
+// lightDocument is a light with the color it currently shows as #RRGGBB
+type lightDocument struct {
+	Light `yaml:",inline"`
+	Color string `json:"color,omitempty" yaml:"color,omitempty"`
+}
+
+type groupsDocument struct {
+	Groups []Group `json:"groups" yaml:"groups"`
+}
+
+type bridgeGroupsDocument struct {
+	Groups []bridgeGroupDocument `json:"groups" yaml:"groups"`
+}
+
+type bridgeGroupDocument struct {
+	ID     string   `json:"id" yaml:"id"`
+	Name   string   `json:"name" yaml:"name"`
+	Type   string   `json:"type" yaml:"type"`
+	Class  string   `json:"class,omitempty" yaml:"class,omitempty"`
+	Lights []string `json:"lights" yaml:"lights"`
+	Target string   `json:"target,omitempty" yaml:"target,omitempty"`
+}
+
+type bridgeScenesDocument struct {
+	Scenes []bridgeSceneDocument `json:"scenes" yaml:"scenes"`
+}
+
+type bridgeSceneDocument struct {
+	ID     string   `json:"id" yaml:"id"`
+	Name   string   `json:"name" yaml:"name"`
+	Type   string   `json:"type" yaml:"type"`
+	Group  string   `json:"group,omitempty" yaml:"group,omitempty"`
+	Lights []string `json:"lights" yaml:"lights"`
+}
+
+type groupLightsDocument struct {
+	Name   string               `json:"name" yaml:"name"`
+	Lights []groupLightDocument `json:"lights" yaml:"lights"`
+}
+
+type groupLightDocument struct {
+	Identifier string `json:"identifier" yaml:"identifier"`
+	Found      bool   `json:"found" yaml:"found"`
+	ID         int    `json:"id,omitempty" yaml:"id,omitempty"`
+	Name       string `json:"name,omitempty" yaml:"name,omitempty"`
+}
+
+type scenesDocument struct {
+	Scenes []Scene `json:"scenes" yaml:"scenes"`
+}
+
+type snapshotsDocument struct {
+	Snapshots []Snapshot `json:"snapshots" yaml:"snapshots"`
+}
+
+type areasDocument struct {
+	Areas []EntertainmentArea `json:"areas" yaml:"areas"`
+}
+
+type bridgesDocument struct {
+	Bridges []discoveredBridge `json:"bridges" yaml:"bridges"`
+}
+
+type profilesDocument struct {
+	Current  string            `json:"current" yaml:"current"`
+	Profiles []profileDocument `json:"profiles" yaml:"profiles"`
+}
+
+type profileDocument struct {
+	Name     string `json:"name" yaml:"name"`
+	Host     string `json:"host" yaml:"host"`
+	BridgeID string `json:"bridge_id" yaml:"bridge_id"`
+	Current  bool   `json:"current" yaml:"current"`
+}
+
+type colorsDocument struct {
+	Aliases map[string]string `json:"aliases" yaml:"aliases"`
+	Named   map[string]string `json:"named,omitempty" yaml:"named,omitempty"`
+}
+
+type usersDocument struct {
+	Users []userDocument `json:"users" yaml:"users"`
+}
+
+type userDocument struct {
+	Name     string    `json:"name" yaml:"name"`
+	Username string    `json:"username" yaml:"username"`
+	Created  time.Time `json:"created" yaml:"created"`
+	LastUsed time.Time `json:"last_used" yaml:"last_used"`
+	Current  bool      `json:"current" yaml:"current"`
+}
+
+type statusDocument struct {
+	Profile    string   `json:"profile" yaml:"profile"`
+	Authorized bool     `json:"authorized" yaml:"authorized"`
+	ConfigFile string   `json:"config_file" yaml:"config_file"`
+	Host       string   `json:"host,omitempty" yaml:"host,omitempty"`
+	BridgeID   string   `json:"bridge_id,omitempty" yaml:"bridge_id,omitempty"`
+	Username   string   `json:"username,omitempty" yaml:"username,omitempty"`
+	API        string   `json:"api,omitempty" yaml:"api,omitempty"`
+	Overrides  []string `json:"env_overrides,omitempty" yaml:"env_overrides,omitempty"`
+	Encrypted  string   `json:"encryption,omitempty" yaml:"encryption,omitempty"`
+	Connected  bool     `json:"connected" yaml:"connected"`
+	Lights     int      `json:"lights" yaml:"lights"`
+	Error      string   `json:"error,omitempty" yaml:"error,omitempty"`
+}
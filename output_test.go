@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"testing"
+)
+
+// captureStdout returns what run writes to stdout
+func captureStdout(t *testing.T, run func()) string {
+	t.Helper()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := os.Stdout
+	os.Stdout = writer
+	defer func() { os.Stdout = previous }()
+
+	done := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(reader)
+		done <- string(data)
+	}()
+	run()
+	writer.Close()
+	return <-done
+}
+
+// useOutputFormat sets the --output format for the duration of the test
+func useOutputFormat(t *testing.T, format string) {
+	t.Helper()
+	previous := outputFormat
+	t.Cleanup(func() { outputFormat = previous })
+	outputFormat = format
+}
+
+func TestPrintOutput(t *testing.T) {
+	doc := groupsDocument{Groups: []Group{{Name: "desk", Lights: []string{"1", "3"}}}}
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{
+			format: "table",
+			want:   "Name  Lights\ndesk  1,3\n",
+		},
+		{
+			format: "json",
+			want:   "{\n  \"groups\": [\n    {\n      \"name\": \"desk\",\n      \"lights\": [\n        \"1\",\n        \"3\"\n      ]\n    }\n  ]\n}\n",
+		},
+		{
+			format: "yaml",
+			want:   "groups:\n  - name: desk\n    lights:\n      - \"1\"\n      - \"3\"\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.format, func(t *testing.T) {
+			useOutputFormat(t, test.format)
+			got := captureStdout(t, func() {
+				err := printOutput(doc, func(w io.Writer) {
+					fmt.Fprintln(w, "Name\tLights")
+					fmt.Fprintln(w, "desk\t1,3")
+				})
+				if err != nil {
+					t.Errorf("printOutput: %v", err)
+				}
+			})
+			if got != test.want {
+				t.Errorf("output = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestValidateOutputFormat(t *testing.T) {
+	for _, format := range []string{"table", "json", "yaml"} {
+		useOutputFormat(t, format)
+		if err := validateOutputFormat(); err != nil {
+			t.Errorf("format %s: %v", format, err)
+		}
+	}
+	useOutputFormat(t, "xml")
+	if err := validateOutputFormat(); err == nil {
+		t.Error("format xml was accepted")
+	}
+}
@@ -0,0 +1,420 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+const defaultProfile = "default"
+
+// ProfileConfig is the content of the bridge config file: one BridgeConfig
+// per named profile, the profile used when --profile is not given and the
+// user's color aliases
+type ProfileConfig struct {
+	Current    string                  `json:"current"`
+	Profiles   map[string]BridgeConfig `json:"profiles"`
+	Colors     map[string]string       `json:"colors,omitempty"`
+	Encryption string                  `json:"encryption,omitempty"` // "passphrase", "keyring" or empty for plain text
+	Salt       string                  `json:"salt,omitempty"`       // for the passphrase key
+	Verifier   string                  `json:"verifier,omitempty"`   // a known value sealed with the key, to check it
+}
+
+// profileFile also accepts the old single-bridge layout, whose fields are
+// read into the embedded BridgeConfig
+type profileFile struct {
+	BridgeConfig
+	Current    string                  `json:"current"`
+	Profiles   map[string]BridgeConfig `json:"profiles"`
+	Colors     map[string]string       `json:"colors,omitempty"`
+	Encryption string                  `json:"encryption,omitempty"`
+	Salt       string                  `json:"salt,omitempty"`
+	Verifier   string                  `json:"verifier,omitempty"`
+}
+
+// profileName is set by the global --profile flag
+var profileName string
+
+// allBridges is set by the global --all-bridges flag
+var allBridges bool
+
+// configDir is the directory holding the config, scene and group files,
+// set up by setupConfigDir
+var configDir string
+
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func validateProfileName(name string) error {
+	if !profileNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid profile name '%s' (use letters, digits, '-' and '_')", name)
+	}
+	return nil
+}
+
+// loadProfileConfig reads the bridge config file, converting the old
+// single-bridge layout into a "default" profile
+func loadProfileConfig() (*ProfileConfig, error) {
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var file profileFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+
+	config := &ProfileConfig{Current: file.Current, Profiles: file.Profiles, Colors: file.Colors, Encryption: file.Encryption, Salt: file.Salt, Verifier: file.Verifier}
+	if config.Profiles == nil {
+		config.Profiles = map[string]BridgeConfig{}
+	}
+	if file.Host != "" {
+		if _, exists := config.Profiles[defaultProfile]; !exists {
+			config.Profiles[defaultProfile] = file.BridgeConfig
+		}
+		if config.Current == "" {
+			config.Current = defaultProfile
+		}
+	}
+	return config, nil
+}
+
+func saveProfileConfig(config ProfileConfig) error {
+	applyPendingPins(&config)
+	if err := sealProfiles(&config); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeConfigFile(configFile, data)
+}
+
+// activeProfile returns the profile selected by --profile, falling back to
+// the current profile in config
+func activeProfile(config *ProfileConfig) string {
+	if profileName != "" {
+		return profileName
+	}
+	if config != nil && config.Current != "" {
+		return config.Current
+	}
+	return defaultProfile
+}
+
+// currentProfile returns the name of the profile this invocation uses
+func currentProfile() string {
+	config, _ := loadProfileConfig()
+	return activeProfile(config)
+}
+
+// profileNames returns the names of all saved profiles in sorted order
+func profileNames(config *ProfileConfig) []string {
+	names := make([]string, 0, len(config.Profiles))
+	for name := range config.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// setProfilePaths points the scene, group, entertainment and snapshot files at the
+// ones belonging to profile. The default profile uses scenes.json and so on,
+// other profiles scenes.<profile>.json.
+func setProfilePaths(profile string) {
+	suffix := ".json"
+	if profile != defaultProfile {
+		suffix = "." + profile + ".json"
+	}
+	sceneFile = filepath.Join(configDir, "scenes"+suffix)
+	groupFile = filepath.Join(configDir, "groups"+suffix)
+	entertainmentFile = filepath.Join(configDir, "entertainment"+suffix)
+	snapshotFile = filepath.Join(configDir, "snapshots"+suffix)
+}
+
+// selectProfile resolves the profile to use for this invocation
+func selectProfile() error {
+	if profileName != "" {
+		if err := validateProfileName(profileName); err != nil {
+			return err
+		}
+	}
+
+	setProfilePaths(currentProfile())
+	return nil
+}
+
+// runOnAllBridges wraps run so it is executed once for every saved profile.
+// Failures on some bridges are reported as a partial failure. JSON and YAML
+// output is printed once, as an object keyed by profile name.
+func runOnAllBridges(run func(cmd *cobra.Command, args []string) error, needsBridge bool) func(cmd *cobra.Command, args []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		config, err := loadProfileConfig()
+		if err != nil || len(config.Profiles) == 0 {
+			return notAuthorizedError("no saved profiles found; run 'hue auth --profile <name>' first")
+		}
+
+		names := profileNames(config)
+		var failed []string
+		var firstErr error
+		documents := map[string]interface{}{}
+		defer func() { collectOutput = nil }()
+		for _, name := range names {
+			if outputFormat == "table" {
+				fmt.Printf("[%s]\n", name)
+			} else {
+				collectOutput = func(doc interface{}) { documents[name] = doc }
+			}
+
+			profileName = name
+			setProfilePaths(name)
+			var err error
+			if needsBridge {
+				err = initBridge()
+			}
+			if err == nil {
+				// Commands may rewrite their args, so each bridge gets a copy
+				err = run(cmd, append([]string(nil), args...))
+			}
+
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error (%s): %v\n", name, err)
+				failed = append(failed, name)
+				if firstErr == nil {
+					firstErr = err
+				}
+				documents[name] = map[string]string{"error": err.Error()}
+			}
+		}
+
+		if outputFormat != "table" && len(documents) > 0 {
+			collectOutput = nil
+			if err := printOutput(documents, nil); err != nil {
+				return err
+			}
+		}
+
+		switch {
+		case len(failed) == 0:
+			return nil
+		case len(failed) == len(names):
+			return fmt.Errorf("all %d bridges failed: %w", len(names), firstErr)
+		default:
+			return partialFailureError("%d/%d bridges failed: %s", len(failed), len(names), strings.Join(failed, ", "))
+		}
+	}
+}
+
+// Profile commands
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Manage bridge profiles",
+	Long:  `Manage named bridge profiles. Each profile has its own credentials, scenes, groups and entertainment areas. Create one with 'hue auth --profile <name>'.`,
+}
+
+func init() {
+	profileCmd.AddCommand(profileListCmd)
+	profileCmd.AddCommand(profileUseCmd)
+	profileCmd.AddCommand(profileRemoveCmd)
+	profileCmd.AddCommand(profileRateCmd)
+	profileCmd.AddCommand(profileAPICmd)
+}
+
+var profileListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List saved profiles",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := loadProfileConfig()
+		if err != nil {
+			config = &ProfileConfig{Profiles: map[string]BridgeConfig{}}
+		}
+
+		doc := profilesDocument{Current: config.Current, Profiles: []profileDocument{}}
+		for _, name := range profileNames(config) {
+			profile := config.Profiles[name]
+			doc.Profiles = append(doc.Profiles, profileDocument{
+				Name:     name,
+				Host:     profile.Host,
+				BridgeID: profile.ID,
+				Current:  name == config.Current,
+			})
+		}
+
+		return printOutput(doc, func(w io.Writer) {
+			if len(doc.Profiles) == 0 {
+				fmt.Fprintln(w, "No profiles found")
+				fmt.Fprintln(w, "Create one with: hue auth --profile <name>")
+				return
+			}
+			fmt.Fprintln(w, "\tName\tHost\tBridge ID")
+			for _, profile := range doc.Profiles {
+				marker := ""
+				if profile.Current {
+					marker = "*"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", marker, profile.Name, profile.Host, profile.BridgeID)
+			}
+		})
+	},
+}
+
+var profileUseCmd = &cobra.Command{
+	Use:   "use [profile-name]",
+	Short: "Set the profile used when --profile is not given",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+
+		unlock, err := lockConfig()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		config, err := loadProfileConfig()
+		if err != nil {
+			return notFoundError("profile '%s' not found", name)
+		}
+		if _, exists := config.Profiles[name]; !exists {
+			return notFoundError("profile '%s' not found", name)
+		}
+
+		config.Current = name
+		if err := saveProfileConfig(*config); err != nil {
+			return fmt.Errorf("failed to save configuration: %w", err)
+		}
+
+		fmt.Printf("Now using profile '%s'\n", name)
+		return nil
+	},
+}
+
+var profileRemoveCmd = &cobra.Command{
+	Use:   "remove [profile-name]",
+	Short: "Remove a saved profile",
+	Long:  `Remove a profile's bridge credentials. Its scene, group and entertainment files are left on disk.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+
+		unlock, err := lockConfig()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		config, err := loadProfileConfig()
+		if err != nil {
+			return notFoundError("profile '%s' not found", name)
+		}
+		if _, exists := config.Profiles[name]; !exists {
+			return notFoundError("profile '%s' not found", name)
+		}
+
+		delete(config.Profiles, name)
+		if config.Current == name {
+			config.Current = ""
+			if names := profileNames(config); len(names) > 0 {
+				config.Current = names[0]
+			}
+		}
+
+		if err := saveProfileConfig(*config); err != nil {
+			return fmt.Errorf("failed to save configuration: %w", err)
+		}
+
+		fmt.Printf("Profile '%s' removed\n", name)
+		if config.Current != "" {
+			fmt.Printf("Now using profile '%s'\n", config.Current)
+		}
+		return nil
+	},
+}
+
+var profileRateCmd = &cobra.Command{
+	Use:   "rate [requests-per-second]",
+	Short: "Set how many light commands per second are sent to the profile's bridge",
+	Long: `Set the rate limit for light commands sent to the bridge of the current profile (or --profile).
+Hue bridges handle about 10 light commands per second; faster updates are queued, and
+queued updates to the same light are merged. Use 0 to return to the default of 10.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rate, err := strconv.ParseFloat(args[0], 64)
+		if err != nil || !validRequestRate(rate) {
+			return fmt.Errorf("rate must be a positive number of requests per second")
+		}
+
+		unlock, err := lockConfig()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		config, err := loadSavedBridgeConfig()
+		if err != nil {
+			return notAuthorizedError("no saved bridge configuration found for profile '%s'", currentProfile())
+		}
+		config.Rate = rate
+		if err := saveBridgeConfig(config); err != nil {
+			return fmt.Errorf("failed to save configuration: %w", err)
+		}
+
+		if rate == 0 {
+			fmt.Printf("Profile '%s' uses the default rate of %g requests/second\n", currentProfile(), defaultRequestRate)
+		} else {
+			fmt.Printf("Profile '%s' rate set to %g requests/second\n", currentProfile(), rate)
+		}
+		return nil
+	},
+}
+
+var profileAPICmd = &cobra.Command{
+	Use:   "api [v1|v2]",
+	Short: "Choose the bridge API used by the profile",
+	Long: `Choose whether commands for the current profile (or --profile) use the v1 API or CLIP v2.
+CLIP v2 needs bridge firmware 1948086000 or later and is checked before it is selected.
+Light IDs, group IDs and names stay the same; they are mapped to CLIP v2 resource IDs.`,
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: []string{apiV1, apiV2},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		api := args[0]
+		if api != apiV1 && api != apiV2 {
+			return fmt.Errorf("unknown API '%s' (use %s or %s)", api, apiV1, apiV2)
+		}
+
+		unlock, err := lockConfig()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		config, err := loadSavedBridgeConfig()
+		if err != nil {
+			return notAuthorizedError("no saved bridge configuration found for profile '%s'", currentProfile())
+		}
+		if api == apiV2 && config.Host != fakeBridgeHost {
+			if err := checkClipSupport(config.Host, config.Username); err != nil {
+				return fmt.Errorf("the bridge at %s does not serve CLIP v2: %w", config.Host, err)
+			}
+		}
+
+		config.API = api
+		if api == apiV1 {
+			config.API = ""
+		}
+		if err := saveBridgeConfig(config); err != nil {
+			return fmt.Errorf("failed to save configuration: %w", err)
+		}
+		fmt.Printf("Profile '%s' uses the %s API\n", currentProfile(), api)
+		return nil
+	},
+}
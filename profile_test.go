@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+// useProfile selects a profile as --profile does for the duration of the test
+func useProfile(t *testing.T, name string) {
+	t.Helper()
+	previous := profileName
+	t.Cleanup(func() {
+		profileName = previous
+		setProfilePaths(defaultProfile)
+	})
+	profileName = name
+	setProfilePaths(name)
+}
+
+func TestLoadProfileConfigLegacyLayout(t *testing.T) {
+	useFakeBridge(t)
+	legacy := `{"host": "192.168.1.2", "username": "abc", "id": "001788FFFE000000"}`
+	if err := os.WriteFile(configFile, []byte(legacy), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := loadProfileConfig()
+	if err != nil {
+		t.Fatalf("loadProfileConfig: %v", err)
+	}
+	want := BridgeConfig{Host: "192.168.1.2", Username: "abc", ID: "001788FFFE000000"}
+	if config.Current != defaultProfile || !reflect.DeepEqual(config.Profiles[defaultProfile], want) {
+		t.Errorf("config = %+v, want the old layout as the current default profile", config)
+	}
+}
+
+func TestProfilesAreSeparate(t *testing.T) {
+	useFakeBridge(t)
+	for _, name := range []string{"home", "office"} {
+		useProfile(t, name)
+		if err := saveBridgeConfig(BridgeConfig{Host: name + ".local", Username: name}); err != nil {
+			t.Fatalf("saveBridgeConfig(%s): %v", name, err)
+		}
+		if err := saveSceneConfig(SceneConfig{Scenes: []Scene{{Name: name}}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, name := range []string{"home", "office"} {
+		useProfile(t, name)
+		config, err := loadSavedBridgeConfig()
+		if err != nil || config.Host != name+".local" {
+			t.Errorf("profile %s config = %+v (%v)", name, config, err)
+		}
+		if filepath.Base(sceneFile) != "scenes."+name+".json" {
+			t.Errorf("profile %s scene file = %s", name, sceneFile)
+		}
+		scenes, err := loadSceneConfig()
+		if err != nil || len(scenes.Scenes) != 1 || scenes.Scenes[0].Name != name {
+			t.Errorf("profile %s scenes = %+v (%v)", name, scenes, err)
+		}
+	}
+
+	// The first profile saved becomes the current one
+	profileName = ""
+	if got := currentProfile(); got != "home" {
+		t.Errorf("current profile = %s, want home", got)
+	}
+}
+
+func TestProfileRemoveCmd(t *testing.T) {
+	tests := []struct {
+		name        string
+		remove      string
+		wantCurrent string
+		code        int
+	}{
+		{name: "current profile", remove: "home", wantCurrent: "office"},
+		{name: "other profile", remove: "office", wantCurrent: "home"},
+		{name: "unknown profile", remove: "garage", wantCurrent: "home", code: exitNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useFakeBridge(t)
+			config := ProfileConfig{Current: "home", Profiles: map[string]BridgeConfig{
+				"home":   {Host: "home.local", Username: "a"},
+				"office": {Host: "office.local", Username: "b"},
+			}}
+			if err := saveProfileConfig(config); err != nil {
+				t.Fatal(err)
+			}
+
+			checkExitCode(t, profileRemoveCmd.RunE(profileRemoveCmd, []string{test.remove}), test.code)
+
+			saved, err := loadProfileConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if saved.Current != test.wantCurrent {
+				t.Errorf("current = %s, want %s", saved.Current, test.wantCurrent)
+			}
+			if _, exists := saved.Profiles[test.remove]; exists && test.code == exitOK {
+				t.Errorf("profile %s was not removed", test.remove)
+			}
+		})
+	}
+}
+
+func TestRunOnAllBridgesOutput(t *testing.T) {
+	useFakeBridge(t)
+	useProfile(t, "")
+	useOutputFormat(t, "json")
+	config := ProfileConfig{Current: "home", Profiles: map[string]BridgeConfig{
+		"home":   {Host: "home.local", Username: "a"},
+		"office": {Host: "office.local", Username: "b"},
+	}}
+	if err := saveProfileConfig(config); err != nil {
+		t.Fatal(err)
+	}
+
+	run := runOnAllBridges(func(cmd *cobra.Command, args []string) error {
+		if profileName == "office" {
+			return notFoundError("nothing here")
+		}
+		return printOutput(groupsDocument{Groups: []Group{{Name: profileName}}}, nil)
+	}, false)
+
+	var err error
+	output := captureStdout(t, func() { err = run(&cobra.Command{}, nil) })
+	checkExitCode(t, err, exitPartialFailure)
+
+	var got map[string]interface{}
+	if err := json.Unmarshal([]byte(output), &got); err != nil {
+		t.Fatalf("output is not one JSON document: %v\n%s", err, output)
+	}
+	want := map[string]interface{}{
+		"home":   map[string]interface{}{"groups": []interface{}{map[string]interface{}{"name": "home", "lights": nil}}},
+		"office": map[string]interface{}{"error": "nothing here"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("output = %v, want %v", got, want)
+	}
+}
+
+func TestSaveBridgeConfigKeepsUnreadableConfig(t *testing.T) {
+	useFakeBridge(t)
+	broken := []byte(`{"current": "home", "profiles": {`)
+	if err := os.WriteFile(configFile, broken, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := saveBridgeConfig(BridgeConfig{Host: "new.local", Username: "c"}); err == nil {
+		t.Error("saveBridgeConfig replaced an unreadable config file")
+	}
+	if data, _ := os.ReadFile(configFile); string(data) != string(broken) {
+		t.Errorf("config file = %q, want it unchanged", data)
+	}
+}
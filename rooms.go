@@ -0,0 +1,295 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// bridgeGroupPrefixes maps target prefixes to the bridge group type they select
+var bridgeGroupPrefixes = map[string]string{
+	"r:": "Room",
+	"z:": "Zone",
+}
+
+// bridgeGroupIdentifier splits an r:/z: target into the bridge group type
+// and the room or zone name
+func bridgeGroupIdentifier(identifier string) (groupType, name string, ok bool) {
+	for prefix, groupType := range bridgeGroupPrefixes {
+		if strings.HasPrefix(identifier, prefix) {
+			return groupType, identifier[len(prefix):], true
+		}
+	}
+	return "", "", false
+}
+
+// findBridgeGroup returns the group of groupType whose name or ID matches
+// nameOrID. Exact names win over case-insensitive ones.
+func findBridgeGroup(groups []BridgeGroup, groupType, nameOrID string) *BridgeGroup {
+	var match *BridgeGroup
+	for i, group := range groups {
+		if group.Type != groupType {
+			continue
+		}
+		if group.Name == nameOrID || group.ID == nameOrID {
+			return &groups[i]
+		}
+		if match == nil && strings.EqualFold(group.Name, nameOrID) {
+			match = &groups[i]
+		}
+	}
+	return match
+}
+
+// resolveBridgeGroup looks up the room or zone named by an r:/z: target
+func resolveBridgeGroup(identifier string) (*BridgeGroup, error) {
+	groupType, name, _ := bridgeGroupIdentifier(identifier)
+	groups, err := bridge.GetGroups()
+	if err != nil {
+		return nil, err
+	}
+	group := findBridgeGroup(groups, groupType, name)
+	if group == nil {
+		return nil, notFoundError("%s '%s' not found on the bridge", strings.ToLower(groupType), name)
+	}
+	return group, nil
+}
+
+// bridgeGroupLights returns the lights of a bridge group
+func bridgeGroupLights(group *BridgeGroup, allLights []Light) []Light {
+	var lights []Light
+	for _, lightID := range group.Lights {
+		id, _ := strconv.Atoi(lightID)
+		for _, light := range allLights {
+			if light.ID == id {
+				lights = append(lights, light)
+				break
+			}
+		}
+	}
+	return lights
+}
+
+// syncedGroupTarget returns the bridge group a local group is synced to,
+// provided the bridge group still has the same lights as the local group
+func syncedGroupTarget(groupName string) (string, bool) {
+	config, err := loadGroupConfig()
+	if err != nil {
+		return "", false
+	}
+	group := findGroup(config, groupName)
+	if group == nil || group.BridgeID == "" {
+		return "", false
+	}
+
+	allLights, err := bridge.GetLights()
+	if err != nil {
+		return "", false
+	}
+	groups, err := bridge.GetGroups()
+	if err != nil {
+		return "", false
+	}
+	bridgeGroup := findBridgeGroup(groups, "LightGroup", group.BridgeID)
+	if bridgeGroup == nil || !sameLights(lightIDs(resolveGroup(groupName, allLights)), bridgeGroup.Lights) {
+		return "", false
+	}
+	return bridgeGroup.ID, true
+}
+
+// lightIDs returns the bridge IDs of lights
+func lightIDs(lights []Light) []string {
+	ids := make([]string, len(lights))
+	for i, light := range lights {
+		ids[i] = strconv.Itoa(light.ID)
+	}
+	return ids
+}
+
+// sameLights reports whether a and b hold the same light IDs in any order
+func sameLights(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string(nil), a...)
+	b = append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+var groupRoomsCmd = &cobra.Command{
+	Use:   "rooms",
+	Short: "List rooms and zones stored on the bridge",
+	Long: `Display the rooms, zones and synced light groups stored on the bridge. Rooms and zones can be
+used as targets with 'r:<room>' and 'z:<zone>', for example 'hue on r:Kitchen'.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		groups, err := bridge.GetGroups()
+		if err != nil {
+			return err
+		}
+
+		doc := bridgeGroupsDocument{Groups: []bridgeGroupDocument{}}
+		for _, group := range groups {
+			entry := bridgeGroupDocument{ID: group.ID, Name: group.Name, Type: group.Type, Class: group.Class, Lights: group.Lights}
+			switch group.Type {
+			case "Room":
+				entry.Target = "r:" + group.Name
+			case "Zone":
+				entry.Target = "z:" + group.Name
+			case "LightGroup":
+			default:
+				continue
+			}
+			doc.Groups = append(doc.Groups, entry)
+		}
+
+		return printOutput(doc, func(w io.Writer) {
+			if len(doc.Groups) == 0 {
+				fmt.Fprintln(w, "No rooms or zones found")
+				return
+			}
+			fmt.Fprintln(w, "ID\tName\tType\tClass\tLights\tTarget")
+			for _, group := range doc.Groups {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", group.ID, group.Name, group.Type, group.Class, strings.Join(group.Lights, ","), group.Target)
+			}
+		})
+	},
+}
+
+var groupSyncCmd = &cobra.Command{
+	Use:   "sync [group-name]",
+	Short: "Create or update a bridge light group from a local group",
+	Long: `Store a local group on the bridge as a LightGroup. Turning a synced group on or off and
+setting its brightness, color or color temperature is then sent as a single group request.
+Run sync again after changing the group's lights.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		groupName := args[0]
+
+		unlock, err := lockConfig()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		config, err := loadGroupConfig()
+		if err != nil {
+			return fmt.Errorf("failed to load groups: %w", err)
+		}
+		group := findGroup(config, groupName)
+		if group == nil {
+			return notFoundError("group '%s' not found", groupName)
+		}
+
+		if err := syncGroupToBridge(group); err != nil {
+			return err
+		}
+		return saveGroupConfig(*config)
+	},
+}
+
+// syncGroupToBridge creates or updates the bridge light group of a saved
+// local group and records its ID in group.BridgeID
+func syncGroupToBridge(group *Group) error {
+	allLights, err := bridge.GetLights()
+	if err != nil {
+		return fmt.Errorf("failed to get lights: %w", err)
+	}
+	lights := resolveGroup(group.Name, allLights)
+	if len(lights) == 0 {
+		return notFoundError("none of the lights in group '%s' were found", group.Name)
+	}
+
+	bridgeGroup := BridgeGroup{Name: group.Name, Type: "LightGroup", Lights: lightIDs(lights)}
+
+	existing := false
+	if group.BridgeID != "" {
+		groups, err := bridge.GetGroups()
+		if err != nil {
+			return err
+		}
+		existing = findBridgeGroup(groups, "LightGroup", group.BridgeID) != nil
+	}
+
+	if existing {
+		if err := bridge.UpdateGroup(group.BridgeID, bridgeGroup); err != nil {
+			return fmt.Errorf("failed to update bridge group: %w", err)
+		}
+		fmt.Printf("Bridge group %s updated with %d lights from group '%s'\n", group.BridgeID, len(lights), group.Name)
+		return nil
+	}
+
+	id, err := bridge.CreateGroup(bridgeGroup)
+	if err != nil {
+		return fmt.Errorf("failed to create bridge group: %w", err)
+	}
+	group.BridgeID = id
+	fmt.Printf("Group '%s' synced to bridge group %s with %d lights\n", group.Name, id, len(lights))
+	return nil
+}
+
+var groupUnsyncCmd = &cobra.Command{
+	Use:   "unsync [group-name]",
+	Short: "Delete the bridge light group of a synced local group",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		groupName := args[0]
+
+		unlock, err := lockConfig()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		config, err := loadGroupConfig()
+		if err != nil {
+			return fmt.Errorf("failed to load groups: %w", err)
+		}
+		group := findGroup(config, groupName)
+		if group == nil {
+			return notFoundError("group '%s' not found", groupName)
+		}
+		if group.BridgeID == "" {
+			return fmt.Errorf("group '%s' is not synced to the bridge", group.Name)
+		}
+
+		if err := deleteSyncedGroup(group.BridgeID); err != nil {
+			return err
+		}
+		fmt.Printf("Bridge group %s of group '%s' deleted\n", group.BridgeID, group.Name)
+
+		group.BridgeID = ""
+		return saveGroupConfig(*config)
+	},
+}
+
+// deleteSyncedGroup deletes a bridge light group, treating a group that is
+// already gone as deleted
+func deleteSyncedGroup(id string) error {
+	if err := bridge.DeleteGroup(id); err != nil && exitCode(err) != exitNotFound {
+		return fmt.Errorf("failed to delete bridge group %s: %w", id, err)
+	}
+	return nil
+}
+
+// warnGroupOutOfSync reminds the user to sync a local group whose lights changed
+func warnGroupOutOfSync(groupName string) {
+	config, err := loadGroupConfig()
+	if err != nil {
+		return
+	}
+	if group := findGroup(config, groupName); group != nil && group.BridgeID != "" {
+		fmt.Fprintf(os.Stderr, "Note: group '%s' is synced to the bridge; run 'hue group sync %s' to update it\n", group.Name, group.Name)
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestFindBridgeGroup(t *testing.T) {
+	groups := []BridgeGroup{
+		{ID: "1", Name: "Living Room", Type: "Room"},
+		{ID: "2", Name: "living room", Type: "Zone"},
+		{ID: "3", Name: "KITCHEN", Type: "Room"},
+		{ID: "4", Name: "Kitchen", Type: "Room"},
+	}
+	tests := []struct {
+		name      string
+		groupType string
+		nameOrID  string
+		want      string
+	}{
+		{name: "exact name", groupType: "Room", nameOrID: "Living Room", want: "1"},
+		{name: "other type", groupType: "Zone", nameOrID: "Living Room", want: "2"},
+		{name: "any case", groupType: "Room", nameOrID: "living room", want: "1"},
+		{name: "exact name wins", groupType: "Room", nameOrID: "Kitchen", want: "4"},
+		{name: "id", groupType: "Room", nameOrID: "3", want: "3"},
+		{name: "id of other type", groupType: "Room", nameOrID: "2"},
+		{name: "unknown", groupType: "Room", nameOrID: "Garage"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			group := findBridgeGroup(groups, test.groupType, test.nameOrID)
+			got := ""
+			if group != nil {
+				got = group.ID
+			}
+			if got != test.want {
+				t.Errorf("findBridgeGroup(%s, %q) = %q, want %q", test.groupType, test.nameOrID, got, test.want)
+			}
+		})
+	}
+}
+
+func TestSameLights(t *testing.T) {
+	tests := []struct {
+		a, b []string
+		want bool
+	}{
+		{a: []string{"1", "3"}, b: []string{"3", "1"}, want: true},
+		{a: nil, b: []string{}, want: true},
+		{a: []string{"1", "3"}, b: []string{"1"}},
+		{a: []string{"1", "1"}, b: []string{"1", "3"}},
+	}
+	for _, test := range tests {
+		if got := sameLights(test.a, test.b); got != test.want {
+			t.Errorf("sameLights(%v, %v) = %t, want %t", test.a, test.b, got, test.want)
+		}
+	}
+
+	a := []string{"3", "1"}
+	sameLights(a, []string{"1", "3"})
+	if !reflect.DeepEqual(a, []string{"3", "1"}) {
+		t.Errorf("sameLights reordered its argument to %v", a)
+	}
+}
+
+func TestSyncedGroupTarget(t *testing.T) {
+	tests := []struct {
+		name   string
+		synced bool
+		change func(fake *fakeBridge, id string)
+		want   bool
+	}{
+		{name: "synced group", synced: true, want: true},
+		{name: "not synced", synced: false},
+		{
+			name:   "bridge group changed",
+			synced: true,
+			change: func(fake *fakeBridge, id string) {
+				fake.updateGroup("test", id, BridgeGroup{Lights: []string{"1"}})
+			},
+		},
+		{
+			name:   "local group changed",
+			synced: true,
+			change: func(fake *fakeBridge, id string) {
+				config, _ := loadGroupConfig()
+				config.Groups[0].Lights = []string{"1"}
+				saveGroupConfig(*config)
+			},
+		},
+		{
+			name:   "bridge group deleted",
+			synced: true,
+			change: func(fake *fakeBridge, id string) { fake.deleteGroup("test", id) },
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := useFakeBridge(t)
+			config := GroupConfig{Groups: []Group{{Name: "desk", Lights: []string{"Living Room Lamp", "3"}}}}
+			if err := saveGroupConfig(config); err != nil {
+				t.Fatal(err)
+			}
+			if test.synced {
+				if err := groupSyncCmd.RunE(groupSyncCmd, []string{"desk"}); err != nil {
+					t.Fatalf("sync: %v", err)
+				}
+			}
+			group := fakeGroupByName(t, fake, "desk")
+			if (group != nil) != test.synced {
+				t.Fatalf("bridge group = %+v after sync %t", group, test.synced)
+			}
+			if test.change != nil {
+				test.change(fake, group.ID)
+			}
+
+			id, ok := syncedGroupTarget("desk")
+			if ok != test.want || (ok && id != group.ID) {
+				t.Errorf("syncedGroupTarget = %q, %t; want %t", id, ok, test.want)
+			}
+		})
+	}
+}
+
+func TestGroupTargetRequests(t *testing.T) {
+	tests := []struct {
+		name string
+		cmd  *cobra.Command
+		args []string
+		want []string
+	}{
+		{name: "room", cmd: onCmd, args: []string{"r:Living Room"}, want: []string{"group 1: on"}},
+		{name: "room in any case", cmd: onCmd, args: []string{"r:kitchen"}, want: []string{"group 2: on"}},
+		{name: "synced group", cmd: onCmd, args: []string{"g:desk"}, want: []string{"group 4: on"}},
+		{name: "lights", cmd: onCmd, args: []string{"1", "3"}, want: []string{"light 1: on", "light 3: on"}},
+		{name: "synced group off", cmd: offCmd, args: []string{"g:desk"}, want: []string{"group 4: on"}},
+		{name: "synced group brightness", cmd: brightnessCmd, args: []string{"g:desk", "100"}, want: []string{"group 4: bri on"}},
+		{name: "synced group color", cmd: colorCmd, args: []string{"g:desk", "red"}, want: []string{"group 4: on xy"}},
+		{name: "synced group temperature", cmd: tempCmd, args: []string{"g:desk", "warm"}, want: []string{"group 4: ct on"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useFakeBridge(t)
+			if err := saveGroupConfig(GroupConfig{Groups: []Group{{Name: "desk", Lights: []string{"1", "3"}}}}); err != nil {
+				t.Fatal(err)
+			}
+			if err := groupSyncCmd.RunE(groupSyncCmd, []string{"desk"}); err != nil {
+				t.Fatal(err)
+			}
+
+			client := recordRequests(t)
+			if err := test.cmd.RunE(test.cmd, test.args); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(client.requests, test.want) {
+				t.Errorf("requests = %q, want %q", client.requests, test.want)
+			}
+		})
+	}
+}
+
+func TestGroupUnsyncCmd(t *testing.T) {
+	fake := useFakeBridge(t)
+	if err := saveGroupConfig(GroupConfig{Groups: []Group{{Name: "desk", Lights: []string{"1"}}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := groupSyncCmd.RunE(groupSyncCmd, []string{"desk"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := groupUnsyncCmd.RunE(groupUnsyncCmd, []string{"desk"}); err != nil {
+		t.Fatalf("unsync: %v", err)
+	}
+
+	if group := fakeGroupByName(t, fake, "desk"); group != nil {
+		t.Errorf("bridge group %+v was not deleted", group)
+	}
+	config, err := loadGroupConfig()
+	if err != nil || config.Groups[0].BridgeID != "" {
+		t.Errorf("groups = %+v (%v), want the bridge ID cleared", config, err)
+	}
+	checkExitCode(t, groupUnsyncCmd.RunE(groupUnsyncCmd, []string{"desk"}), exitError)
+}
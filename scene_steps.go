@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// SceneStep is one step of a sequential scene. Exactly one of Commands (a
+// block of commands run together), Wait (a pause) or Steps (nested steps
+// run Repeat times) is set.
+type SceneStep struct {
+	Commands []SceneCommand `json:"commands,omitempty" yaml:"commands,omitempty"`
+	Wait     string         `json:"wait,omitempty" yaml:"wait,omitempty"` // e.g. "30s" or "5m"
+	Steps    []SceneStep    `json:"steps,omitempty" yaml:"steps,omitempty"`
+	Repeat   int            `json:"repeat,omitempty" yaml:"repeat,omitempty"` // times to run Steps, 0 means once
+}
+
+// sceneCommands returns every command of a scene in the order they run,
+// counting repeated steps once
+func sceneCommands(scene *Scene) []SceneCommand {
+	commands := append([]SceneCommand(nil), scene.Commands...)
+	var walk func(steps []SceneStep)
+	walk = func(steps []SceneStep) {
+		for _, step := range steps {
+			commands = append(commands, step.Commands...)
+			walk(step.Steps)
+		}
+	}
+	walk(scene.Steps)
+	return commands
+}
+
+// sceneRunner executes the commands and steps of a scene, keeping count of
+// the commands that succeeded
+type sceneRunner struct {
+	transition *uint16
+	total      int
+	succeeded  int
+	firstErr   error
+}
+
+// runCommands runs a block of commands concurrently and waits for all of them
+func (r *sceneRunner) runCommands(commands []SceneCommand) {
+	results := make(chan error, len(commands))
+	for _, command := range commands {
+		go func(cmd SceneCommand) {
+			results <- executeSceneCommand(cmd, r.transition)
+		}(command)
+	}
+
+	for range commands {
+		r.total++
+		if err := <-results; err != nil {
+			fmt.Printf("  Error: %v\n", err)
+			if r.firstErr == nil {
+				r.firstErr = err
+			}
+		} else {
+			r.succeeded++
+		}
+	}
+}
+
+// runSteps runs steps one after another
+func (r *sceneRunner) runSteps(steps []SceneStep) {
+	for _, step := range steps {
+		switch {
+		case step.Wait != "":
+			duration, _ := parseDuration(step.Wait)
+			time.Sleep(duration)
+		case len(step.Steps) > 0:
+			for i := 0; i < max(step.Repeat, 1); i++ {
+				r.runSteps(step.Steps)
+			}
+		default:
+			r.runCommands(step.Commands)
+		}
+	}
+}
+
+var sceneWaitCmd = &cobra.Command{
+	Use:   "wait [scene-name] [duration]",
+	Short: "Add a pause to a scene",
+	Long: `Add a pause after the scene's current steps. Commands added with --step after the pause
+run once it is over.
+
+Example:
+  hue scene add wake-up brightness "Bedroom" 50 --step --transition 5m
+  hue scene wait wake-up 5m
+  hue scene add wake-up brightness "Living Room" 200 --step --transition 2m`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sceneName := args[0]
+		if duration, ok := parseDuration(args[1]); !ok || duration < 0 {
+			return fmt.Errorf("invalid wait '%s' (use e.g. 500ms, 30s or 5m)", args[1])
+		}
+
+		if err := addStepToScene(sceneName, SceneStep{Wait: args[1]}, false); err != nil {
+			return fmt.Errorf("failed to add wait to scene: %w", err)
+		}
+		fmt.Printf("Added %s wait to scene '%s'\n", args[1], sceneName)
+		return nil
+	},
+}
+
+var sceneRepeatCmd = &cobra.Command{
+	Use:   "repeat [scene-name] [count]",
+	Short: "Set how many times a scene runs",
+	Long:  `Run the commands and steps of a scene count times in a row. A count of 1 runs the scene once.`,
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sceneName := args[0]
+		count, err := strconv.Atoi(args[1])
+		if err != nil || count < 1 {
+			return fmt.Errorf("repeat count must be a positive number")
+		}
+
+		unlock, err := lockConfig()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		config, err := loadSceneConfig()
+		if os.IsNotExist(err) {
+			return notFoundError("scene '%s' not found", sceneName)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load scenes: %w", err)
+		}
+		scene := findScene(config, sceneName)
+		if scene == nil {
+			return notFoundError("scene '%s' not found", sceneName)
+		}
+
+		scene.Repeat = count
+		if count == 1 {
+			scene.Repeat = 0
+		}
+		if err := saveSceneConfig(*config); err != nil {
+			return err
+		}
+		fmt.Printf("Scene '%s' will run %d times\n", sceneName, count)
+		return nil
+	},
+}
+
+// addStepToScene appends a step to a scene, creating the scene if needed.
+// With parallel the step's commands join the scene's last command block.
+func addStepToScene(sceneName string, step SceneStep, parallel bool) error {
+	unlock, err := lockConfig()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	config, err := loadSceneConfig()
+	if os.IsNotExist(err) {
+		// Create new config if file doesn't exist
+		config = &SceneConfig{Scenes: []Scene{}}
+	} else if err != nil {
+		return err
+	}
+
+	scene := findScene(config, sceneName)
+	if scene == nil {
+		config.Scenes = append(config.Scenes, Scene{Name: sceneName, Commands: []SceneCommand{}})
+		scene = &config.Scenes[len(config.Scenes)-1]
+	}
+
+	if parallel {
+		if len(scene.Steps) == 0 || len(scene.Steps[len(scene.Steps)-1].Commands) == 0 {
+			return fmt.Errorf("the last step of scene '%s' is not a command step", sceneName)
+		}
+		last := &scene.Steps[len(scene.Steps)-1]
+		last.Commands = append(last.Commands, step.Commands...)
+	} else {
+		scene.Steps = append(scene.Steps, step)
+	}
+
+	return saveSceneConfig(*config)
+}
+
+func removeStepFromScene(sceneName string, index int) error {
+	unlock, err := lockConfig()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	config, err := loadSceneConfig()
+	if err != nil {
+		return err
+	}
+
+	scene := findScene(config, sceneName)
+	if scene == nil {
+		return notFoundError("scene '%s' not found", sceneName)
+	}
+
+	if index < 0 || index >= len(scene.Steps) {
+		return fmt.Errorf("invalid step index %d", index+1)
+	}
+	scene.Steps = append(scene.Steps[:index], scene.Steps[index+1:]...)
+
+	return saveSceneConfig(*config)
+}
+
+// printSceneSteps writes one row per command, wait and loop, numbering
+// nested steps as 2.1, 2.2 and so on
+func printSceneSteps(w io.Writer, steps []SceneStep, prefix string) {
+	for i, step := range steps {
+		number := prefix + strconv.Itoa(i+1)
+		switch {
+		case step.Wait != "":
+			fmt.Fprintf(w, "%s\twait\t\t%s\t\n", number, step.Wait)
+		case len(step.Steps) > 0:
+			fmt.Fprintf(w, "%s\trepeat\t\t%dx\t\n", number, max(step.Repeat, 1))
+			printSceneSteps(w, step.Steps, number+".")
+		default:
+			for _, command := range step.Commands {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", number, command.Type, command.Light, strings.Join(command.Values, " "), command.Transition)
+			}
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExecuteSceneSteps(t *testing.T) {
+	brightness := func(light, value string) []SceneCommand {
+		return []SceneCommand{{Type: "brightness", Light: light, Values: []string{value}}}
+	}
+	scene := Scene{
+		Name:     "wake-up",
+		Commands: brightness("1", "10"),
+		Steps: []SceneStep{
+			{Commands: brightness("1", "20")},
+			{Wait: "20ms"},
+			{Repeat: 2, Steps: []SceneStep{
+				{Commands: brightness("2", "30")},
+				{Commands: brightness("3", "40")},
+			}},
+		},
+		Repeat: 2,
+	}
+
+	useFakeBridge(t)
+	if err := saveSceneConfig(SceneConfig{Scenes: []Scene{scene}}); err != nil {
+		t.Fatal(err)
+	}
+	client := recordRequests(t)
+
+	start := time.Now()
+	if err := executeScene("wake-up", nil); err != nil {
+		t.Fatalf("executeScene: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("scene ran in %s, want at least two 20ms waits", elapsed)
+	}
+
+	run := []string{"light 1: bri on", "light 1: bri on", "light 2: bri on", "light 3: bri on", "light 2: bri on", "light 3: bri on"}
+	want := append(append([]string(nil), run...), run...)
+	if !reflect.DeepEqual(client.requests, want) {
+		t.Errorf("requests = %q, want %q", client.requests, want)
+	}
+}
+
+func TestSceneCommands(t *testing.T) {
+	scene := Scene{
+		Commands: []SceneCommand{{Type: "on", Light: "1"}},
+		Steps: []SceneStep{
+			{Commands: []SceneCommand{{Type: "off", Light: "2"}, {Type: "on", Light: "3"}}},
+			{Wait: "1s"},
+			{Repeat: 3, Steps: []SceneStep{{Commands: []SceneCommand{{Type: "off", Light: "4"}}}}},
+		},
+	}
+	var lights []string
+	for _, command := range sceneCommands(&scene) {
+		lights = append(lights, command.Light)
+	}
+	if want := []string{"1", "2", "3", "4"}; !reflect.DeepEqual(lights, want) {
+		t.Errorf("sceneCommands lights = %v, want %v", lights, want)
+	}
+}
+
+func TestAddStepToScene(t *testing.T) {
+	useFakeBridge(t)
+	on := SceneStep{Commands: []SceneCommand{{Type: "on", Light: "1", Values: []string{}}}}
+	off := SceneStep{Commands: []SceneCommand{{Type: "off", Light: "2", Values: []string{}}}}
+
+	if err := addStepToScene("night", off, true); err == nil {
+		t.Error("a parallel command was added to a scene without command steps")
+	}
+	for _, add := range []struct {
+		step     SceneStep
+		parallel bool
+	}{{on, false}, {off, true}, {SceneStep{Wait: "5m"}, false}} {
+		if err := addStepToScene("night", add.step, add.parallel); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := addStepToScene("night", off, true); err == nil {
+		t.Error("a parallel command was added after a wait")
+	}
+
+	config, err := loadSceneConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SceneStep{
+		{Commands: append(append([]SceneCommand(nil), on.Commands...), off.Commands...)},
+		{Wait: "5m"},
+	}
+	if len(config.Scenes) != 1 || !reflect.DeepEqual(config.Scenes[0].Steps, want) {
+		t.Errorf("scenes = %+v, want steps %+v", config.Scenes, want)
+	}
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults for the request scheduler. The bridge handles about 10 light
+// commands per second and about one group command per second; requests
+// beyond that are dropped or answered with errors.
+const (
+	defaultRequestRate = 10.0
+	groupRequestGap    = time.Second
+	maxRequestRetries  = 3
+	firstRetryDelay    = 200 * time.Millisecond
+)
+
+// bridgeErrInternal is returned by the bridge when its command buffer is full
+const bridgeErrInternal = 901
+
+// requestRate is set by the global --rate flag; 0 uses the profile's rate
+var requestRate float64
+
+// validRequestRate reports whether rate can be used as a request rate, where
+// 0 selects the default
+func validRequestRate(rate float64) bool {
+	return rate >= 0 && !math.IsNaN(rate) && !math.IsInf(rate, 0)
+}
+
+// schedulers holds every scheduler created by this invocation so their
+// statistics can be reported when the command finishes
+var (
+	schedulers      []*requestScheduler
+	schedulersMutex sync.Mutex
+)
+
+// scheduledRequest is a state change waiting to be sent. Later changes to
+// the same light or group are merged into it while it waits.
+type scheduledRequest struct {
+	key     string
+	group   bool
+	update  StateUpdate
+	send    func(StateUpdate) error
+	waiters []chan error
+}
+
+// requestScheduler sends state changes to one bridge at a limited rate,
+// coalescing pending changes to the same target and retrying requests the
+// bridge rejected because it was busy
+type requestScheduler struct {
+	name     string
+	interval time.Duration
+
+	mutex         sync.Mutex
+	queue         []*scheduledRequest
+	pending       map[string]*scheduledRequest
+	running       bool
+	idle          *sync.Cond
+	lastSend      time.Time
+	lastGroupSend time.Time
+
+	coalesced map[string]int // target -> updates superseded before being sent
+	retried   int
+	dropped   map[string]int // target -> updates given up after retries
+	failed    map[string]int // target -> updates the bridge rejected
+}
+
+func newRequestScheduler(name string, rate float64) *requestScheduler {
+	if rate <= 0 {
+		rate = defaultRequestRate
+	}
+	s := &requestScheduler{
+		name:      name,
+		interval:  time.Duration(float64(time.Second) / rate),
+		pending:   make(map[string]*scheduledRequest),
+		coalesced: make(map[string]int),
+		dropped:   make(map[string]int),
+		failed:    make(map[string]int),
+	}
+	s.idle = sync.NewCond(&s.mutex)
+
+	schedulersMutex.Lock()
+	schedulers = append(schedulers, s)
+	schedulersMutex.Unlock()
+	return s
+}
+
+// submit queues a state change for key. The returned channel receives the
+// result once the change (possibly merged with later ones) has been sent.
+func (s *requestScheduler) submit(key string, group bool, update StateUpdate, send func(StateUpdate) error) <-chan error {
+	done := make(chan error, 1)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if request, ok := s.pending[key]; ok {
+		if overridesStateUpdate(request.update, update) {
+			s.coalesced[key]++
+		}
+		request.update = mergeStateUpdates(request.update, update)
+		request.waiters = append(request.waiters, done)
+		s.requeueBehindGroups(request)
+		return done
+	}
+
+	request := &scheduledRequest{key: key, group: group, update: update, send: send, waiters: []chan error{done}}
+	s.pending[key] = request
+	s.queue = append(s.queue, request)
+	if !s.running {
+		s.running = true
+		go s.run()
+	}
+	return done
+}
+
+// requeueBehindGroups moves a request that just absorbed a later update to
+// the end of the queue if a group request queued after it may cover the
+// same lights, so the update is not sent ahead of that group request. A
+// group request moves behind any later request. The caller holds the mutex.
+func (s *requestScheduler) requeueBehindGroups(request *scheduledRequest) {
+	index := -1
+	for i, queued := range s.queue {
+		if queued == request {
+			index = i
+			break
+		}
+	}
+
+	for _, later := range s.queue[index+1:] {
+		if later.group || request.group {
+			s.queue = append(s.queue[:index], s.queue[index+1:]...)
+			s.queue = append(s.queue, request)
+			return
+		}
+	}
+}
+
+// run sends queued requests in order until the queue is empty
+func (s *requestScheduler) run() {
+	for {
+		s.mutex.Lock()
+		if len(s.queue) == 0 {
+			s.running = false
+			s.idle.Broadcast()
+			s.mutex.Unlock()
+			return
+		}
+		request := s.queue[0]
+		wait := s.waitTime(request.group)
+		s.mutex.Unlock()
+
+		if wait > 0 {
+			time.Sleep(wait)
+		}
+
+		// The request stops accepting merges once it is on its way
+		s.mutex.Lock()
+		if s.queue[0] != request {
+			// It was moved behind a group request while waiting
+			s.mutex.Unlock()
+			continue
+		}
+		s.queue = s.queue[1:]
+		delete(s.pending, request.key)
+		s.markSent(request.group)
+		s.mutex.Unlock()
+
+		err := s.sendWithRetries(request)
+		for _, waiter := range request.waiters {
+			waiter <- err
+		}
+	}
+}
+
+// waitTime returns how long to wait before the next request may be sent.
+// The caller holds the mutex.
+func (s *requestScheduler) waitTime(group bool) time.Duration {
+	next := s.lastSend.Add(s.interval)
+	if group {
+		if groupNext := s.lastGroupSend.Add(groupRequestGap); groupNext.After(next) {
+			next = groupNext
+		}
+	}
+	return time.Until(next)
+}
+
+func (s *requestScheduler) markSent(group bool) {
+	s.lastSend = time.Now()
+	if group {
+		s.lastGroupSend = s.lastSend
+	}
+}
+
+func (s *requestScheduler) sendWithRetries(request *scheduledRequest) error {
+	delay := firstRetryDelay
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = request.send(request.update)
+		if err == nil || !isRetryableError(err) || attempt == maxRequestRetries {
+			break
+		}
+
+		s.mutex.Lock()
+		s.retried++
+		s.mutex.Unlock()
+
+		time.Sleep(delay)
+		delay *= 2
+
+		s.mutex.Lock()
+		s.markSent(request.group)
+		s.mutex.Unlock()
+	}
+
+	if err != nil {
+		s.mutex.Lock()
+		if isRetryableError(err) {
+			s.dropped[request.key]++
+		} else {
+			s.failed[request.key]++
+		}
+		s.mutex.Unlock()
+	}
+	return err
+}
+
+// flush waits until every queued request has been sent
+func (s *requestScheduler) flush() {
+	s.mutex.Lock()
+	for s.running {
+		s.idle.Wait()
+	}
+	s.mutex.Unlock()
+}
+
+// isRetryableError reports whether err means the bridge was busy rather
+// than that the request itself was wrong
+func isRetryableError(err error) bool {
+	var bridgeErr *BridgeError
+	if errors.As(err, &bridgeErr) {
+		return bridgeErr.Type == bridgeErrInternal
+	}
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == 429 || statusErr.StatusCode == 503
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Timeout()
+	}
+	return false
+}
+
+// overridesStateUpdate reports whether merging next into base replaces
+// anything base would have set
+func overridesStateUpdate(base, next StateUpdate) bool {
+	baseColor := base.Xy != nil || base.Hue != nil || base.Sat != nil || base.Ct != nil
+	nextColor := next.Xy != nil || next.Hue != nil || next.Sat != nil || next.Ct != nil
+	return (base.On != nil && next.On != nil) ||
+		(base.Bri != nil && next.Bri != nil) ||
+		(baseColor && nextColor) ||
+		(base.Alert != "" && next.Alert != "") ||
+		(base.Effect != "" && next.Effect != "")
+}
+
+// mergeStateUpdates returns base with every attribute set in next applied
+func mergeStateUpdates(base, next StateUpdate) StateUpdate {
+	if next.On != nil {
+		base.On = next.On
+	}
+	if next.Bri != nil {
+		base.Bri = next.Bri
+	}
+	if next.Hue != nil {
+		base.Hue = next.Hue
+	}
+	if next.Sat != nil {
+		base.Sat = next.Sat
+	}
+	if next.Xy != nil {
+		base.Xy = next.Xy
+	}
+	if next.Ct != nil {
+		base.Ct = next.Ct
+	}
+	if next.TransitionTime != nil {
+		base.TransitionTime = next.TransitionTime
+	}
+	if next.Alert != "" {
+		base.Alert = next.Alert
+	}
+	if next.Effect != "" {
+		base.Effect = next.Effect
+	}
+
+	// The newest color wins, whichever color mode it uses
+	switch {
+	case next.Xy != nil:
+		base.Hue, base.Sat, base.Ct = nil, nil, nil
+	case next.Ct != nil:
+		base.Xy, base.Hue, base.Sat = nil, nil, nil
+	case next.Hue != nil || next.Sat != nil:
+		base.Xy, base.Ct = nil, nil
+	}
+	return base
+}
+
+// report writes what the scheduler had to drop, if anything
+func (s *requestScheduler) report(w io.Writer) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	prefix := ""
+	if s.name != "" {
+		prefix = fmt.Sprintf("(%s) ", s.name)
+	}
+	if total := sumCounts(s.coalesced); total > 0 {
+		fmt.Fprintf(w, "Note: %s%d updates were replaced by newer ones before being sent (%s)\n", prefix, total, formatCounts(s.coalesced))
+	}
+	if s.retried > 0 {
+		fmt.Fprintf(w, "Note: %sthe bridge was busy, %d requests were retried\n", prefix, s.retried)
+	}
+	if total := sumCounts(s.dropped); total > 0 {
+		fmt.Fprintf(w, "Warning: %s%d updates were dropped after %d retries (%s)\n", prefix, total, maxRequestRetries, formatCounts(s.dropped))
+	}
+	if total := sumCounts(s.failed); total > 0 {
+		fmt.Fprintf(w, "Warning: %s%d updates were rejected by the bridge (%s)\n", prefix, total, formatCounts(s.failed))
+	}
+}
+
+// reportSchedulers flushes every scheduler and reports dropped updates
+func reportSchedulers(w io.Writer) {
+	schedulersMutex.Lock()
+	defer schedulersMutex.Unlock()
+	for _, s := range schedulers {
+		s.flush()
+		s.report(w)
+	}
+}
+
+func sumCounts(counts map[string]int) int {
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+	return total
+}
+
+func formatCounts(counts map[string]int) string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = fmt.Sprintf("%s: %d", key, counts[key])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// scheduledClient is a BridgeClient whose state changes go through a
+// requestScheduler. Other calls are passed straight to the bridge.
+type scheduledClient struct {
+	BridgeClient
+	scheduler *requestScheduler
+}
+
+func newScheduledClient(client BridgeClient, name string, rate float64) *scheduledClient {
+	return &scheduledClient{BridgeClient: client, scheduler: newRequestScheduler(name, rate)}
+}
+
+func (c *scheduledClient) SetLightState(id int, update StateUpdate) error {
+	return <-c.QueueLightState(id, update)
+}
+
+func (c *scheduledClient) SetGroupAction(groupID string, update StateUpdate) error {
+	return <-c.QueueGroupAction(groupID, update)
+}
+
+// QueueLightState queues a light state change without waiting for it
+func (c *scheduledClient) QueueLightState(id int, update StateUpdate) <-chan error {
+	return c.scheduler.submit(fmt.Sprintf("light %d", id), false, update, func(update StateUpdate) error {
+		return c.BridgeClient.SetLightState(id, update)
+	})
+}
+
+// QueueGroupAction queues a group state change without waiting for it
+func (c *scheduledClient) QueueGroupAction(groupID string, update StateUpdate) <-chan error {
+	return c.scheduler.submit("group "+groupID, true, update, func(update StateUpdate) error {
+		return c.BridgeClient.SetGroupAction(groupID, update)
+	})
+}
+
+// Flush waits until every queued state change has been sent
+func (c *scheduledClient) Flush() {
+	c.scheduler.flush()
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// schedulerSubmission is a state change a test queues on a scheduler
+type schedulerSubmission struct {
+	key    string
+	group  bool
+	update StateUpdate
+}
+
+// sentUpdate is a request the scheduler sent
+type sentUpdate struct {
+	key    string
+	update StateUpdate
+}
+
+func TestSchedulerCoalescing(t *testing.T) {
+	tests := []struct {
+		name          string
+		submissions   []schedulerSubmission
+		want          []sentUpdate
+		wantCoalesced map[string]int
+	}{
+		{
+			name: "attributes of one light are merged",
+			submissions: []schedulerSubmission{
+				{key: "light 1", update: StateUpdate{On: boolPtr(true)}},
+				{key: "light 1", update: StateUpdate{Bri: uint8Ptr(100)}},
+			},
+			want:          []sentUpdate{{"light 1", StateUpdate{On: boolPtr(true), Bri: uint8Ptr(100)}}},
+			wantCoalesced: map[string]int{},
+		},
+		{
+			name: "a newer value replaces an older one",
+			submissions: []schedulerSubmission{
+				{key: "light 1", update: StateUpdate{Bri: uint8Ptr(10)}},
+				{key: "light 1", update: StateUpdate{Bri: uint8Ptr(20)}},
+				{key: "light 1", update: StateUpdate{Bri: uint8Ptr(30)}},
+			},
+			want:          []sentUpdate{{"light 1", StateUpdate{Bri: uint8Ptr(30)}}},
+			wantCoalesced: map[string]int{"light 1": 2},
+		},
+		{
+			name: "the newest color mode wins",
+			submissions: []schedulerSubmission{
+				{key: "light 1", update: StateUpdate{Xy: []float32{0.3, 0.3}}},
+				{key: "light 1", update: StateUpdate{Ct: uint16Ptr(300)}},
+			},
+			want:          []sentUpdate{{"light 1", StateUpdate{Ct: uint16Ptr(300)}}},
+			wantCoalesced: map[string]int{"light 1": 1},
+		},
+		{
+			name: "different lights keep their order",
+			submissions: []schedulerSubmission{
+				{key: "light 2", update: StateUpdate{On: boolPtr(true)}},
+				{key: "light 1", update: StateUpdate{On: boolPtr(true)}},
+				{key: "light 2", update: StateUpdate{Bri: uint8Ptr(5)}},
+			},
+			want: []sentUpdate{
+				{"light 2", StateUpdate{On: boolPtr(true), Bri: uint8Ptr(5)}},
+				{"light 1", StateUpdate{On: boolPtr(true)}},
+			},
+			wantCoalesced: map[string]int{},
+		},
+		{
+			name: "a merged light update stays behind a later group request",
+			submissions: []schedulerSubmission{
+				{key: "light 1", update: StateUpdate{On: boolPtr(true)}},
+				{key: "group 0", group: true, update: StateUpdate{On: boolPtr(false)}},
+				{key: "light 1", update: StateUpdate{Bri: uint8Ptr(50)}},
+			},
+			want: []sentUpdate{
+				{"group 0", StateUpdate{On: boolPtr(false)}},
+				{"light 1", StateUpdate{On: boolPtr(true), Bri: uint8Ptr(50)}},
+			},
+			wantCoalesced: map[string]int{},
+		},
+		{
+			name: "a merged group update stays behind a later light request",
+			submissions: []schedulerSubmission{
+				{key: "group 0", group: true, update: StateUpdate{On: boolPtr(true)}},
+				{key: "light 1", update: StateUpdate{On: boolPtr(false)}},
+				{key: "group 0", group: true, update: StateUpdate{Bri: uint8Ptr(50)}},
+			},
+			want: []sentUpdate{
+				{"light 1", StateUpdate{On: boolPtr(false)}},
+				{"group 0", StateUpdate{On: boolPtr(true), Bri: uint8Ptr(50)}},
+			},
+			wantCoalesced: map[string]int{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newRequestScheduler("", 1000)
+
+			var mutex sync.Mutex
+			var sent []sentUpdate
+			send := func(key string) func(StateUpdate) error {
+				return func(update StateUpdate) error {
+					mutex.Lock()
+					defer mutex.Unlock()
+					sent = append(sent, sentUpdate{key, update})
+					return nil
+				}
+			}
+
+			// Hold the scheduler on a first request so the test's requests
+			// are all queued before any of them is sent
+			release := make(chan struct{})
+			s.submit("blocker", false, StateUpdate{}, func(StateUpdate) error {
+				<-release
+				return nil
+			})
+			for _, submission := range test.submissions {
+				s.submit(submission.key, submission.group, submission.update, send(submission.key))
+			}
+			close(release)
+			s.flush()
+
+			if !reflect.DeepEqual(sent, test.want) {
+				t.Errorf("sent %+v, want %+v", sent, test.want)
+			}
+			if !reflect.DeepEqual(s.coalesced, test.wantCoalesced) {
+				t.Errorf("coalesced = %v, want %v", s.coalesced, test.wantCoalesced)
+			}
+		})
+	}
+}
+
+func TestSchedulerRetries(t *testing.T) {
+	busy := &BridgeError{Type: bridgeErrInternal, Description: "Internal error, 404"}
+	invalid := &BridgeError{Type: bridgeErrInvalidValue, Description: "invalid value"}
+
+	tests := []struct {
+		name         string
+		results      []error
+		wantAttempts int
+		wantErr      error
+		wantRetried  int
+		wantReport   string
+	}{
+		{name: "success", results: []error{nil}, wantAttempts: 1},
+		{name: "busy then success", results: []error{busy, busy, nil}, wantAttempts: 3, wantRetried: 2, wantReport: "2 requests were retried"},
+		{name: "rate limited", results: []error{&httpStatusError{StatusCode: 429}, nil}, wantAttempts: 2, wantRetried: 1, wantReport: "1 requests were retried"},
+		{name: "busy every time", results: []error{busy, busy, busy, busy}, wantAttempts: 4, wantErr: busy, wantRetried: 3, wantReport: "1 updates were dropped after 3 retries (light 1: 1)"},
+		{name: "invalid request", results: []error{invalid}, wantAttempts: 1, wantErr: invalid, wantReport: "1 updates were rejected by the bridge (light 1: 1)"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newRequestScheduler("", 1000)
+			attempts := 0
+			err := <-s.submit("light 1", false, StateUpdate{On: boolPtr(true)}, func(StateUpdate) error {
+				attempts++
+				return test.results[attempts-1]
+			})
+
+			if err != test.wantErr {
+				t.Errorf("err = %v, want %v", err, test.wantErr)
+			}
+			if attempts != test.wantAttempts {
+				t.Errorf("attempts = %d, want %d", attempts, test.wantAttempts)
+			}
+			if s.retried != test.wantRetried {
+				t.Errorf("retried = %d, want %d", s.retried, test.wantRetried)
+			}
+
+			var report bytes.Buffer
+			s.report(&report)
+			if test.wantReport == "" && report.Len() > 0 {
+				t.Errorf("report = %q, want none", report.String())
+			}
+			if !strings.Contains(report.String(), test.wantReport) {
+				t.Errorf("report = %q, want it to contain %q", report.String(), test.wantReport)
+			}
+		})
+	}
+}
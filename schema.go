@@ -0,0 +1,574 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Versions of the scene and group file formats written by this build.
+// Older files are migrated when they are loaded and saved in the current
+// format on the next change.
+const (
+	sceneFileVersion = 1
+	groupFileVersion = 1
+)
+
+// configProblem is a problem found in a config file. Path names the value
+// it concerns, e.g. scenes[2].commands[0].values.
+type configProblem struct {
+	Line    int    `json:"line,omitempty" yaml:"line,omitempty"`
+	Path    string `json:"path,omitempty" yaml:"path,omitempty"`
+	Message string `json:"message" yaml:"message"`
+}
+
+// configFileError is returned when a config file cannot be used because of
+// the problems found in it. Listed is set for files 'hue config validate'
+// does not check, such as apply files, so every problem is in the message.
+type configFileError struct {
+	File     string
+	Problems []configProblem
+	Listed   bool
+}
+
+func (e *configFileError) Error() string {
+	if e.Listed {
+		lines := make([]string, len(e.Problems))
+		for i, problem := range e.Problems {
+			lines[i] = formatProblem(e.File, problem)
+		}
+		return strings.Join(lines, "\n")
+	}
+
+	message := formatProblem(e.File, e.Problems[0])
+	if len(e.Problems) > 1 {
+		message += fmt.Sprintf(" (and %d more problems, run 'hue config validate')", len(e.Problems)-1)
+	}
+	return message
+}
+
+func formatProblem(file string, problem configProblem) string {
+	location := file
+	if problem.Line > 0 {
+		location = fmt.Sprintf("%s:%d", file, problem.Line)
+	}
+	if problem.Path != "" {
+		return fmt.Sprintf("%s: %s: %s", location, problem.Path, problem.Message)
+	}
+	return fmt.Sprintf("%s: %s", location, problem.Message)
+}
+
+// problemList collects problems and looks up the line each one is on
+type problemList struct {
+	lines    map[string]int
+	problems []configProblem
+}
+
+func newProblemList(data []byte) *problemList {
+	return &problemList{lines: jsonLines(data)}
+}
+
+func (l *problemList) add(path, format string, args ...interface{}) {
+	l.problems = append(l.problems, configProblem{Line: l.line(path), Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// line returns the line of path, or of its closest parent present in the file
+func (l *problemList) line(path string) int {
+	for {
+		if line, ok := l.lines[path]; ok {
+			return line
+		}
+		cut := strings.LastIndexAny(path, ".[")
+		if cut < 0 {
+			return l.lines[""]
+		}
+		path = path[:cut]
+	}
+}
+
+// jsonLines maps the path of every value in a JSON document, such as
+// scenes[0].commands[1], to the line the value starts on
+func jsonLines(data []byte) map[string]int {
+	lines := make(map[string]int)
+	decoder := json.NewDecoder(bytes.NewReader(data))
+
+	var walk func(path string) bool
+	walk = func(path string) bool {
+		lines[path] = lineAt(data, valueStart(data, decoder.InputOffset()))
+		token, err := decoder.Token()
+		if err != nil {
+			return false
+		}
+		switch token {
+		case json.Delim('{'):
+			for decoder.More() {
+				key, err := decoder.Token()
+				if err != nil {
+					return false
+				}
+				child, _ := key.(string)
+				if path != "" {
+					child = path + "." + child
+				}
+				if !walk(child) {
+					return false
+				}
+			}
+		case json.Delim('['):
+			for i := 0; decoder.More(); i++ {
+				if !walk(fmt.Sprintf("%s[%d]", path, i)) {
+					return false
+				}
+			}
+		default:
+			return true
+		}
+		_, err = decoder.Token() // closing delimiter
+		return err == nil
+	}
+	walk("")
+	return lines
+}
+
+// valueStart skips the separators between the end of the previous token
+// and the start of the next value
+func valueStart(data []byte, offset int64) int64 {
+	for offset < int64(len(data)) && strings.IndexByte(" \t\r\n,:", data[offset]) >= 0 {
+		offset++
+	}
+	return offset
+}
+
+func lineAt(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+// arrayIndexPattern matches the array indexes in the field paths of JSON
+// type errors, such as the 0 in scenes.0.name
+var arrayIndexPattern = regexp.MustCompile(`\.(\d+)\b`)
+
+// unknownFieldPattern matches the errors of a decoder that disallows
+// unknown fields
+var unknownFieldPattern = regexp.MustCompile(`^json: unknown field "(.*)"$`)
+
+// decodeConfig unmarshals a config file, rejecting unknown fields when
+// strict, and turns decoding errors into a problem with a line number
+func decodeConfig(data []byte, v interface{}, strict bool) *configProblem {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if strict {
+		decoder.DisallowUnknownFields()
+	}
+	err := decoder.Decode(v)
+	if err == nil {
+		return nil
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return &configProblem{Line: lineAt(data, syntaxErr.Offset), Message: "invalid JSON: " + syntaxErr.Error()}
+	case errors.As(err, &typeErr):
+		path := arrayIndexPattern.ReplaceAllString(typeErr.Field, "[$1]")
+		return &configProblem{Line: lineAt(data, typeErr.Offset), Path: path, Message: fmt.Sprintf("expected %s, found %s", typeErr.Type, typeErr.Value)}
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return &configProblem{Line: lineAt(data, int64(len(data))), Message: "unexpected end of file"}
+	}
+
+	// The decoder is past the whole object when it reports an unknown
+	// field, so the line is that of the first key with the field's name
+	offset := decoder.InputOffset()
+	if match := unknownFieldPattern.FindStringSubmatch(err.Error()); match != nil {
+		key := regexp.MustCompile(regexp.QuoteMeta(strconv.Quote(match[1])) + `\s*:`)
+		if location := key.FindIndex(data); location != nil {
+			offset = int64(location[0])
+		}
+	}
+	return &configProblem{Line: lineAt(data, offset), Message: strings.TrimPrefix(err.Error(), "json: ")}
+}
+
+// fileVersion returns the version field of a scene or group file, or 0 if
+// it has none or cannot be read
+func fileVersion(data []byte) int {
+	var header struct {
+		Version int `json:"version"`
+	}
+	json.Unmarshal(data, &header)
+	return header.Version
+}
+
+// parseSceneConfig decodes and migrates the contents of a scene file. It
+// only fails when the file cannot be read as a scene file; the values in it
+// are checked by checkSceneFile and before a scene is used.
+func parseSceneConfig(data []byte) (*SceneConfig, []configProblem) {
+	// A newer file may have fields this version does not know, so its
+	// version is checked before the strict decode
+	if version := fileVersion(data); version > sceneFileVersion {
+		problems := newProblemList(data)
+		problems.add("version", "scene file version %d was written by a newer version of hue (this one reads up to version %d)", version, sceneFileVersion)
+		return nil, problems.problems
+	}
+
+	var config SceneConfig
+	if problem := decodeConfig(data, &config, true); problem != nil {
+		return nil, []configProblem{*problem}
+	}
+	migrateSceneConfig(&config)
+	return &config, nil
+}
+
+// checkSceneFile reads a scene file and validates every scene in it
+func checkSceneFile(data []byte) (int, []configProblem) {
+	config, problems := parseSceneConfig(data)
+	if config == nil {
+		return fileVersion(data), problems
+	}
+	list := newProblemList(data)
+	checkScenes(list, "scenes", config.Scenes)
+	return fileVersion(data), list.problems
+}
+
+// checkStoredScene validates one scene of the scene file before it is run
+// or pushed, so a problem in another scene does not stop it
+func checkStoredScene(config *SceneConfig, scene *Scene) error {
+	for i := range config.Scenes {
+		if &config.Scenes[i] != scene {
+			continue
+		}
+		data, _ := os.ReadFile(sceneFile)
+		problems := newProblemList(data)
+		checkScene(problems, fmt.Sprintf("scenes[%d]", i), *scene)
+		if len(problems.problems) > 0 {
+			return &configFileError{File: sceneFile, Problems: problems.problems}
+		}
+	}
+	return nil
+}
+
+// checkScenes validates a list of scenes, such as the scenes of a scene file
+func checkScenes(problems *problemList, path string, scenes []Scene) {
+	names := make(map[string]bool)
+	for i, scene := range scenes {
+		path := fmt.Sprintf("%s[%d]", path, i)
+		switch {
+		case scene.Name == "":
+			problems.add(path+".name", "scene has no name")
+		case names[scene.Name]:
+			problems.add(path+".name", "duplicate scene name '%s'", scene.Name)
+		}
+		names[scene.Name] = true
+		checkScene(problems, path, scene)
+	}
+}
+
+// checkScene validates the commands and steps of a scene
+func checkScene(problems *problemList, path string, scene Scene) {
+	if scene.Repeat < 0 {
+		problems.add(path+".repeat", "repeat must not be negative")
+	}
+	for j, command := range scene.Commands {
+		checkSceneCommand(problems, fmt.Sprintf("%s.commands[%d]", path, j), command)
+	}
+	checkSceneSteps(problems, path+".steps", scene.Steps)
+}
+
+func checkSceneCommand(problems *problemList, path string, command SceneCommand) {
+	if err := validateSceneCommand(command.Type, command.Values); err != nil {
+		problems.add(path, "%v", err)
+	}
+	if strings.TrimSpace(command.Light) == "" {
+		problems.add(path+".light", "command has no light or group")
+	}
+	if command.Transition != "" {
+		if _, err := parseTransition(command.Transition); err != nil {
+			problems.add(path+".transition", "%v", err)
+		}
+	}
+}
+
+// checkSceneSteps checks that every step is a command block, a wait or a
+// loop of nested steps
+func checkSceneSteps(problems *problemList, path string, steps []SceneStep) {
+	for i, step := range steps {
+		stepPath := fmt.Sprintf("%s[%d]", path, i)
+
+		kinds := 0
+		for _, set := range []bool{len(step.Commands) > 0, step.Wait != "", len(step.Steps) > 0} {
+			if set {
+				kinds++
+			}
+		}
+		if kinds != 1 {
+			problems.add(stepPath, "step must have exactly one of commands, wait or steps")
+		}
+		if step.Repeat < 0 {
+			problems.add(stepPath+".repeat", "repeat must not be negative")
+		}
+		if step.Repeat > 0 && len(step.Steps) == 0 {
+			problems.add(stepPath+".repeat", "repeat needs nested steps")
+		}
+		if step.Wait != "" {
+			if duration, ok := parseDuration(step.Wait); !ok || duration < 0 {
+				problems.add(stepPath+".wait", "invalid wait '%s' (use e.g. 500ms, 30s or 5m)", step.Wait)
+			}
+		}
+
+		for j, command := range step.Commands {
+			checkSceneCommand(problems, fmt.Sprintf("%s.commands[%d]", stepPath, j), command)
+		}
+		checkSceneSteps(problems, stepPath+".steps", step.Steps)
+	}
+}
+
+// migrateSceneConfig brings a scene file up to the current version.
+// Version 0 files predate the version field; they may lack value lists and
+// have command types in any case.
+func migrateSceneConfig(config *SceneConfig) {
+	if config.Version < 1 {
+		for i := range config.Scenes {
+			commands := config.Scenes[i].Commands
+			for j := range commands {
+				commands[j].Type = strings.ToLower(strings.TrimSpace(commands[j].Type))
+				if commands[j].Values == nil {
+					commands[j].Values = []string{}
+				}
+			}
+		}
+	}
+	config.Version = sceneFileVersion
+}
+
+// parseGroupConfig decodes and migrates the contents of a group file. Like
+// parseSceneConfig it leaves the values in it to checkGroupFile.
+func parseGroupConfig(data []byte) (*GroupConfig, []configProblem) {
+	if version := fileVersion(data); version > groupFileVersion {
+		problems := newProblemList(data)
+		problems.add("version", "group file version %d was written by a newer version of hue (this one reads up to version %d)", version, groupFileVersion)
+		return nil, problems.problems
+	}
+
+	var config GroupConfig
+	if problem := decodeConfig(data, &config, true); problem != nil {
+		return nil, []configProblem{*problem}
+	}
+	migrateGroupConfig(&config)
+	return &config, nil
+}
+
+// checkGroupFile reads a group file and validates every group in it
+func checkGroupFile(data []byte) (int, []configProblem) {
+	config, problems := parseGroupConfig(data)
+	if config == nil {
+		return fileVersion(data), problems
+	}
+
+	list := newProblemList(data)
+	names := make(map[string]bool)
+	for i, group := range config.Groups {
+		path := fmt.Sprintf("groups[%d]", i)
+		switch {
+		case group.Name == "":
+			list.add(path+".name", "group has no name")
+		case names[group.Name]:
+			list.add(path+".name", "duplicate group name '%s'", group.Name)
+		}
+		names[group.Name] = true
+
+		for j, light := range group.Lights {
+			if light == "" {
+				list.add(fmt.Sprintf("%s.lights[%d]", path, j), "empty light name")
+			}
+		}
+	}
+	return fileVersion(data), list.problems
+}
+
+// migrateGroupConfig brings a group file up to the current version.
+// Version 0 files predate the version field; light names may carry
+// surrounding spaces.
+func migrateGroupConfig(config *GroupConfig) {
+	if config.Version < 1 {
+		for i := range config.Groups {
+			for j, light := range config.Groups[i].Lights {
+				config.Groups[i].Lights[j] = strings.TrimSpace(light)
+			}
+		}
+	}
+	config.Version = groupFileVersion
+}
+
+// checkProfileFile validates the bridge config file
+func checkProfileFile(data []byte) []configProblem {
+	var file profileFile
+	if problem := decodeConfig(data, &file, false); problem != nil {
+		return []configProblem{*problem}
+	}
+
+	problems := newProblemList(data)
+	for _, name := range profileNames(&ProfileConfig{Profiles: file.Profiles}) {
+		path := "profiles." + name
+		if err := validateProfileName(name); err != nil {
+			problems.add(path, "%v", err)
+		}
+		if file.Profiles[name].Rate < 0 {
+			problems.add(path+".rate", "rate must not be negative")
+		}
+		if api := file.Profiles[name].API; api != "" && api != apiV1 && api != apiV2 {
+			problems.add(path+".api", "unknown API '%s' (use %s or %s)", api, apiV1, apiV2)
+		}
+	}
+	if file.Encryption != "" && file.Encryption != encryptPassphrase && file.Encryption != encryptKeyring {
+		problems.add("encryption", "unknown encryption '%s' (use %s or %s)", file.Encryption, encryptPassphrase, encryptKeyring)
+	}
+	for _, name := range sortedKeys(file.Colors) {
+		if _, err := parseColorWithAliases(file.Colors[name], file.Colors, 0); err != nil {
+			problems.add("colors."+name, "%v", err)
+		}
+	}
+	return problems.problems
+}
+
+// fileValidation is the result of validating one config file
+type fileValidation struct {
+	File     string          `json:"file" yaml:"file"`
+	Exists   bool            `json:"exists" yaml:"exists"`
+	Version  int             `json:"version,omitempty" yaml:"version,omitempty"`
+	Migrated bool            `json:"migrated,omitempty" yaml:"migrated,omitempty"`
+	Problems []configProblem `json:"problems" yaml:"problems"`
+}
+
+type validationDocument struct {
+	Files []fileValidation `json:"files" yaml:"files"`
+}
+
+// validateFile runs check on the contents of file. check returns the file
+// version it read, or 0 for files without one.
+func validateFile(file string, check func(data []byte) (int, []configProblem)) fileValidation {
+	result := fileValidation{File: file, Problems: []configProblem{}}
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return result
+	}
+	result.Exists = true
+	if err != nil {
+		result.Problems = append(result.Problems, configProblem{Message: err.Error()})
+		return result
+	}
+
+	version, problems := check(data)
+	result.Version = version
+	if problems != nil {
+		result.Problems = problems
+	}
+	return result
+}
+
+func validateSceneFile(file string) fileValidation {
+	result := validateFile(file, checkSceneFile)
+	result.Migrated = result.Exists && result.Version < sceneFileVersion
+	return result
+}
+
+func validateGroupFile(file string) fileValidation {
+	result := validateFile(file, checkGroupFile)
+	result.Migrated = result.Exists && result.Version < groupFileVersion
+	return result
+}
+
+// decodeOnly validates that a file without a schema version is valid JSON
+// for v
+func decodeOnly(v interface{}) func(data []byte) (int, []configProblem) {
+	return func(data []byte) (int, []configProblem) {
+		if problem := decodeConfig(data, v, false); problem != nil {
+			return 0, []configProblem{*problem}
+		}
+		return 0, nil
+	}
+}
+
+// printValidation writes the results and fails when any file has problems
+func printValidation(doc validationDocument) error {
+	total := 0
+	for _, file := range doc.Files {
+		total += len(file.Problems)
+	}
+
+	err := printOutput(doc, func(w io.Writer) {
+		for _, file := range doc.Files {
+			switch {
+			case !file.Exists:
+				fmt.Fprintf(w, "%s: not found, skipped\n", file.File)
+			case len(file.Problems) == 0 && file.Migrated:
+				fmt.Fprintf(w, "%s: OK (version %d, upgraded to the current format on the next change)\n", file.File, file.Version)
+			case len(file.Problems) == 0:
+				fmt.Fprintf(w, "%s: OK\n", file.File)
+			}
+			for _, problem := range file.Problems {
+				fmt.Fprintln(w, formatProblem(file.File, problem))
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	switch {
+	case total == 1:
+		return fmt.Errorf("1 problem found")
+	case total > 1:
+		return fmt.Errorf("%d problems found", total)
+	}
+	return nil
+}
+
+var sceneValidateCmd = &cobra.Command{
+	Use:   "validate [file]",
+	Short: "Check a scene file for errors",
+	Long: `Check the scene file of the current profile, or the given file, and report every problem
+with its line number.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file := sceneFile
+		if len(args) == 1 {
+			file = args[0]
+		}
+		return printValidation(validationDocument{Files: []fileValidation{validateSceneFile(file)}})
+	},
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the configuration files",
+}
+
+func init() {
+	configCmd.AddCommand(configValidateCmd)
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check all configuration files for errors",
+	Long: `Check the bridge config file and the scene, group, entertainment and snapshot files of the
+current profile, and report every problem with its file and line number.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		doc := validationDocument{Files: []fileValidation{
+			validateFile(configFile, func(data []byte) (int, []configProblem) { return 0, checkProfileFile(data) }),
+			validateSceneFile(sceneFile),
+			validateGroupFile(groupFile),
+			validateFile(entertainmentFile, decodeOnly(&EntertainmentConfig{})),
+			validateFile(snapshotFile, decodeOnly(&SnapshotConfig{})),
+		}}
+		return printValidation(doc)
+	},
+}
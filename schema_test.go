@@ -0,0 +1,131 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const sceneFileForLines = `{
+  "version": 1,
+  "scenes": [
+    {
+      "name": "evening",
+      "commands": [
+        {"type": "on", "light": "1", "values": []},
+        {
+          "type": "brightness",
+          "light": "2",
+          "values": ["300"]
+        }
+      ]
+    }
+  ]
+}`
+
+func TestJSONLines(t *testing.T) {
+	lines := jsonLines([]byte(sceneFileForLines))
+	tests := []struct {
+		path string
+		want int
+	}{
+		{path: "", want: 1},
+		{path: "version", want: 2},
+		{path: "scenes", want: 3},
+		{path: "scenes[0]", want: 4},
+		{path: "scenes[0].name", want: 5},
+		{path: "scenes[0].commands[0]", want: 7},
+		{path: "scenes[0].commands[0].light", want: 7},
+		{path: "scenes[0].commands[1]", want: 8},
+		{path: "scenes[0].commands[1].values", want: 11},
+		{path: "scenes[0].commands[1].values[0]", want: 11},
+	}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			if got, ok := lines[test.path]; !ok || got != test.want {
+				t.Errorf("line of %q = %d (found %t), want %d", test.path, got, ok, test.want)
+			}
+		})
+	}
+}
+
+func TestProblemListLine(t *testing.T) {
+	problems := newProblemList([]byte(sceneFileForLines))
+	tests := []struct {
+		path string
+		want int
+	}{
+		{path: "scenes[0].commands[1].values", want: 11},
+		{path: "scenes[0].commands[1].transition", want: 8},
+		{path: "scenes[0].steps[2].wait", want: 4},
+		{path: "other", want: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			if got := problems.line(test.path); got != test.want {
+				t.Errorf("line(%q) = %d, want %d", test.path, got, test.want)
+			}
+		})
+	}
+}
+
+func TestCheckSceneFile(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []configProblem // messages only need to be contained
+	}{
+		{name: "valid", data: `{"version": 1, "scenes": [{"name": "a", "commands": [{"type": "on", "light": "1", "values": []}]}]}`},
+		{
+			name: "invalid value",
+			data: sceneFileForLines,
+			want: []configProblem{{Line: 8, Path: "scenes[0].commands[1]", Message: "brightness"}},
+		},
+		{
+			name: "several problems",
+			data: "{\n\"scenes\": [\n{\"name\": \"\", \"commands\": []},\n{\"name\": \"b\", \"commands\": [\n{\"type\": \"on\", \"light\": \"\", \"values\": []}]}]}",
+			want: []configProblem{
+				{Line: 3, Path: "scenes[0].name", Message: "scene has no name"},
+				{Line: 5, Path: "scenes[1].commands[0].light", Message: "command has no light"},
+			},
+		},
+		{
+			name: "wrong type",
+			data: "{\n  \"scenes\": [\n    {\"name\": 5}\n  ]\n}",
+			want: []configProblem{{Line: 3, Path: "scenes[0].name", Message: "expected string, found number"}},
+		},
+		{
+			name: "unknown field",
+			data: "{\n  \"scenes\": [],\n  \"colour\": 1\n}",
+			want: []configProblem{{Line: 3, Message: "unknown field \"colour\""}},
+		},
+		{
+			name: "syntax error",
+			data: "{\n  \"scenes\": [\n    {\"name\": \"a\",}\n  ]\n}",
+			want: []configProblem{{Line: 3, Message: "invalid JSON"}},
+		},
+		{
+			name: "newer version",
+			data: "{\n  \"version\": 99,\n  \"scenes\": []\n}",
+			want: []configProblem{{Line: 2, Path: "version", Message: "newer version of hue"}},
+		},
+		{
+			name: "newer version with new fields",
+			data: "{\n  \"version\": 2,\n  \"scenes\": [],\n  \"schedules\": []\n}",
+			want: []configProblem{{Line: 2, Path: "version", Message: "newer version of hue"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, problems := checkSceneFile([]byte(test.data))
+			if len(problems) != len(test.want) {
+				t.Fatalf("problems = %+v, want %d", problems, len(test.want))
+			}
+			for i, want := range test.want {
+				got := problems[i]
+				if got.Line != want.Line || got.Path != want.Path || !strings.Contains(got.Message, want.Message) {
+					t.Errorf("problem %d = %+v, want %+v", i, got, want)
+				}
+			}
+		})
+	}
+}
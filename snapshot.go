@@ -0,0 +1,317 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// defaultSnapshot is the snapshot used when no name is given
+const defaultSnapshot = "default"
+
+// Snapshot is the saved state of a set of lights
+type Snapshot struct {
+	Name   string          `json:"name" yaml:"name"`
+	Taken  time.Time       `json:"taken" yaml:"taken"`
+	Lights []SnapshotLight `json:"lights" yaml:"lights"`
+}
+
+type SnapshotLight struct {
+	ID    int         `json:"id" yaml:"id"`
+	Name  string      `json:"name" yaml:"name"`
+	State StateUpdate `json:"state" yaml:"state"`
+}
+
+type SnapshotConfig struct {
+	Snapshots []Snapshot `json:"snapshots"`
+}
+
+var snapshotFile string
+
+// liveStateUpdate returns the state change that puts a light back into its
+// current state. Off lights only get switched off, as the bridge rejects
+// other changes to lights that are off.
+func liveStateUpdate(light Light) StateUpdate {
+	state := light.State
+	if !state.On {
+		return StateUpdate{On: boolPtr(false)}
+	}
+
+	update := StateUpdate{On: boolPtr(true)}
+	if state.Bri > 0 {
+		update.Bri = uint8Ptr(state.Bri)
+	}
+	// The bridge reports xy for hue/saturation colors too
+	switch {
+	case state.ColorMode == "ct" && lightSupportsCt(light.Type) && state.Ct > 0:
+		update.Ct = uint16Ptr(state.Ct)
+	case lightSupportsColor(light.Type) && len(state.Xy) == 2:
+		update.Xy = []float32{state.Xy[0], state.Xy[1]}
+	}
+	return update
+}
+
+var sceneCaptureCmd = &cobra.Command{
+	Use:   "capture [scene-name] [lights/groups...]",
+	Short: "Create a scene from the current state of lights",
+	Long: `Read the current state of lights (on/off, brightness and color or color temperature) and save
+it as a scene with one command per light. Without targets every light is captured. An
+existing scene is only replaced with --force.
+
+Examples:
+  hue scene capture evening
+  hue scene capture reading "Desk Lamp" g:office --transition 2s`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sceneName := args[0]
+		targets := args[1:]
+		if len(targets) == 0 {
+			targets = []string{"all"}
+		}
+		force, _ := cmd.Flags().GetBool("force")
+
+		transition, _ := cmd.Flags().GetString("transition")
+		if transition != "" {
+			if _, err := parseTransition(transition); err != nil {
+				return err
+			}
+		}
+
+		unlock, err := lockConfig()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		config, err := loadSceneConfig()
+		if os.IsNotExist(err) {
+			// Create new config if file doesn't exist
+			config = &SceneConfig{Scenes: []Scene{}}
+		} else if err != nil {
+			return err
+		}
+		existing := findScene(config, sceneName)
+		if existing != nil && !force {
+			return fmt.Errorf("scene '%s' already exists (use --force to replace it)", sceneName)
+		}
+
+		lights, err := resolveTargets(targets)
+		if err != nil {
+			return err
+		}
+
+		scene := Scene{Name: sceneName, Commands: []SceneCommand{}}
+		for _, light := range lights {
+			for _, command := range stateCommands(light.Name, liveStateUpdate(light)) {
+				command.Transition = transition
+				scene.Commands = append(scene.Commands, command)
+			}
+		}
+
+		if existing != nil {
+			*existing = scene
+		} else {
+			config.Scenes = append(config.Scenes, scene)
+		}
+		if err := saveSceneConfig(*config); err != nil {
+			return fmt.Errorf("failed to save scene: %w", err)
+		}
+
+		fmt.Printf("Captured %d lights into scene '%s'\n", len(lights), sceneName)
+		return nil
+	},
+}
+
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Save and restore the current state of lights",
+	Long: `Save the current state of lights and restore it later, for example around an effect:
+
+  hue snapshot save
+  hue entertain stream effect "My Room" rainbow --duration 30
+  hue snapshot restore`,
+}
+
+func init() {
+	snapshotCmd.AddCommand(snapshotSaveCmd)
+	snapshotCmd.AddCommand(snapshotRestoreCmd)
+	snapshotCmd.AddCommand(snapshotListCmd)
+	snapshotCmd.AddCommand(snapshotRemoveCmd)
+
+	sceneCaptureCmd.Flags().BoolP("force", "f", false, "Replace an existing scene with the same name")
+	sceneCaptureCmd.Flags().String("transition", "", "Fade duration stored with every command (e.g. 2s)")
+	snapshotRestoreCmd.Flags().String("transition", "", "Fade duration for restoring the lights (e.g. 2s)")
+}
+
+var snapshotSaveCmd = &cobra.Command{
+	Use:   "save [name] [lights/groups...]",
+	Short: "Save the current state of lights",
+	Long: `Save the current state of lights under a name, replacing an earlier snapshot with that name.
+Without a name the snapshot is called "default"; without targets every light is saved.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := defaultSnapshot
+		if len(args) > 0 {
+			name = args[0]
+		}
+		targets := []string{"all"}
+		if len(args) > 1 {
+			targets = args[1:]
+		}
+
+		lights, err := resolveTargets(targets)
+		if err != nil {
+			return err
+		}
+
+		snapshot := Snapshot{Name: name, Taken: time.Now(), Lights: []SnapshotLight{}}
+		for _, light := range lights {
+			snapshot.Lights = append(snapshot.Lights, SnapshotLight{ID: light.ID, Name: light.Name, State: liveStateUpdate(light)})
+		}
+
+		unlock, err := lockConfig()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		config, err := loadSnapshotConfig()
+		if err != nil {
+			return fmt.Errorf("failed to load snapshots: %w", err)
+		}
+		if existing := findSnapshot(config, name); existing != nil {
+			*existing = snapshot
+		} else {
+			config.Snapshots = append(config.Snapshots, snapshot)
+		}
+		if err := saveSnapshotConfig(*config); err != nil {
+			return fmt.Errorf("failed to save snapshot: %w", err)
+		}
+
+		fmt.Printf("Saved the state of %d lights as snapshot '%s'\n", len(lights), name)
+		return nil
+	},
+}
+
+var snapshotRestoreCmd = &cobra.Command{
+	Use:   "restore [name]",
+	Short: "Restore lights to a saved snapshot",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := defaultSnapshot
+		if len(args) > 0 {
+			name = args[0]
+		}
+		transition, err := transitionFlag(cmd)
+		if err != nil {
+			return err
+		}
+
+		config, err := loadSnapshotConfig()
+		if err != nil {
+			return fmt.Errorf("failed to load snapshots: %w", err)
+		}
+		snapshot := findSnapshot(config, name)
+		if snapshot == nil {
+			return notFoundError("snapshot '%s' not found", name)
+		}
+
+		var failures []lightFailure
+		for _, saved := range snapshot.Lights {
+			light := Light{ID: saved.ID, Name: saved.Name}
+			state := stateBuilder{update: saved.State}.Transition(transition)
+			if err := state.send(light); err != nil {
+				failures = append(failures, lightFailure{Name: saved.Name, Err: err})
+			}
+		}
+		if err := lightsResult(len(snapshot.Lights), failures); err != nil {
+			return err
+		}
+
+		fmt.Printf("Restored %d lights from snapshot '%s'\n", len(snapshot.Lights), name)
+		return nil
+	},
+}
+
+var snapshotListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List saved snapshots",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := loadSnapshotConfig()
+		if err != nil {
+			return fmt.Errorf("failed to load snapshots: %w", err)
+		}
+
+		return printOutput(snapshotsDocument{Snapshots: config.Snapshots}, func(w io.Writer) {
+			if len(config.Snapshots) == 0 {
+				fmt.Fprintln(w, "No snapshots found")
+				return
+			}
+			fmt.Fprintln(w, "Name\tTaken\tLights")
+			for _, snapshot := range config.Snapshots {
+				fmt.Fprintf(w, "%s\t%s\t%d\n", snapshot.Name, snapshot.Taken.Format("2006-01-02 15:04:05"), len(snapshot.Lights))
+			}
+		})
+	},
+}
+
+var snapshotRemoveCmd = &cobra.Command{
+	Use:   "remove [name]",
+	Short: "Remove a saved snapshot",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+
+		unlock, err := lockConfig()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		config, err := loadSnapshotConfig()
+		if err != nil {
+			return fmt.Errorf("failed to load snapshots: %w", err)
+		}
+		for i, snapshot := range config.Snapshots {
+			if snapshot.Name == name {
+				config.Snapshots = append(config.Snapshots[:i], config.Snapshots[i+1:]...)
+				if err := saveSnapshotConfig(*config); err != nil {
+					return fmt.Errorf("failed to save snapshots: %w", err)
+				}
+				fmt.Printf("Snapshot '%s' removed\n", name)
+				return nil
+			}
+		}
+		return notFoundError("snapshot '%s' not found", name)
+	},
+}
+
+func findSnapshot(config *SnapshotConfig, name string) *Snapshot {
+	for i, snapshot := range config.Snapshots {
+		if snapshot.Name == name {
+			return &config.Snapshots[i]
+		}
+	}
+	return nil
+}
+
+func loadSnapshotConfig() (*SnapshotConfig, error) {
+	var config SnapshotConfig
+	data, err := os.ReadFile(snapshotFile)
+	if err != nil {
+		return &SnapshotConfig{Snapshots: []Snapshot{}}, nil
+	}
+	err = json.Unmarshal(data, &config)
+	return &config, err
+}
+
+func saveSnapshotConfig(config SnapshotConfig) error {
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeConfigFile(snapshotFile, data)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLiveStateUpdate(t *testing.T) {
+	tests := []struct {
+		name  string
+		light Light
+		want  StateUpdate
+	}{
+		{
+			name:  "off",
+			light: Light{Type: "Extended color light", State: LightState{On: false, Bri: 200, Xy: []float32{0.3, 0.3}}},
+			want:  StateUpdate{On: boolPtr(false)},
+		},
+		{
+			name:  "color",
+			light: Light{Type: "Extended color light", State: LightState{On: true, Bri: 200, ColorMode: "xy", Xy: []float32{0.3, 0.4}, Ct: 300}},
+			want:  StateUpdate{On: boolPtr(true), Bri: uint8Ptr(200), Xy: []float32{0.3, 0.4}},
+		},
+		{
+			name:  "hue and saturation",
+			light: Light{Type: "Extended color light", State: LightState{On: true, Bri: 200, ColorMode: "hs", Xy: []float32{0.3, 0.4}}},
+			want:  StateUpdate{On: boolPtr(true), Bri: uint8Ptr(200), Xy: []float32{0.3, 0.4}},
+		},
+		{
+			name:  "temperature",
+			light: Light{Type: "Extended color light", State: LightState{On: true, Bri: 200, ColorMode: "ct", Xy: []float32{0.3, 0.4}, Ct: 300}},
+			want:  StateUpdate{On: boolPtr(true), Bri: uint8Ptr(200), Ct: uint16Ptr(300)},
+		},
+		{
+			name:  "dimmable",
+			light: Light{Type: "Dimmable light", State: LightState{On: true, Bri: 50}},
+			want:  StateUpdate{On: boolPtr(true), Bri: uint8Ptr(50)},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := liveStateUpdate(test.light); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("liveStateUpdate = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSnapshotSaveAndRestore(t *testing.T) {
+	fake := useFakeBridge(t)
+	fake.setLightState("test", 1, StateUpdate{On: boolPtr(true), Bri: uint8Ptr(100), Xy: []float32{0.6, 0.35}})
+	fake.setLightState("test", 5, StateUpdate{On: boolPtr(true), Bri: uint8Ptr(200), Ct: uint16Ptr(300)})
+	before := []Light{fakeLight(t, fake, 1), fakeLight(t, fake, 2), fakeLight(t, fake, 5)}
+
+	if err := snapshotSaveCmd.RunE(snapshotSaveCmd, []string{"movie", "1", "2", "5"}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	fake.setLightState("test", 1, StateUpdate{On: boolPtr(true), Bri: uint8Ptr(10), Xy: []float32{0.2, 0.2}})
+	fake.setLightState("test", 2, StateUpdate{On: boolPtr(true)})
+	fake.setLightState("test", 5, StateUpdate{On: boolPtr(false)})
+
+	if err := snapshotRestoreCmd.RunE(snapshotRestoreCmd, []string{"movie"}); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	for _, light := range before {
+		got := fakeLight(t, fake, light.ID)
+		want := light.State
+		if got.State.On != want.On || (want.On && (got.State.Bri != want.Bri || got.State.Ct != want.Ct || !reflect.DeepEqual(got.State.Xy, want.Xy))) {
+			t.Errorf("light %d = %+v after restore, want %+v", light.ID, got.State, want)
+		}
+	}
+
+	checkExitCode(t, snapshotRestoreCmd.RunE(snapshotRestoreCmd, []string{"party"}), exitNotFound)
+}
+
+func TestSceneCaptureCmd(t *testing.T) {
+	fake := useFakeBridge(t)
+	fake.setLightState("test", 4, StateUpdate{On: boolPtr(true), Bri: uint8Ptr(120)})
+	fake.setLightState("test", 5, StateUpdate{On: boolPtr(true), Bri: uint8Ptr(200), Ct: uint16Ptr(300)})
+
+	if err := sceneCaptureCmd.RunE(sceneCaptureCmd, []string{"evening", "Hallway", "5", "1"}); err != nil {
+		t.Fatalf("capture: %v", err)
+	}
+	config, err := loadSceneConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SceneCommand{
+		{Type: "brightness", Light: "Hallway", Values: []string{"120"}},
+		{Type: "temp", Light: "Bedroom Ambiance", Values: []string{"300mired", "200"}},
+		{Type: "off", Light: "Living Room Lamp", Values: []string{}},
+	}
+	if len(config.Scenes) != 1 || !reflect.DeepEqual(config.Scenes[0].Commands, want) {
+		t.Errorf("captured scenes = %+v, want commands %+v", config.Scenes, want)
+	}
+
+	// An existing scene is only replaced with --force
+	checkExitCode(t, sceneCaptureCmd.RunE(sceneCaptureCmd, []string{"evening"}), exitError)
+}
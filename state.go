@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// allLightsGroup is the bridge group that always contains every light
+const allLightsGroup = "0"
+
+// stateBuilder collects changes to a light's state so they reach the bridge
+// as a single request instead of one request per attribute. Builders are
+// values: every method returns a modified copy, so a shared base state can
+// be extended per light.
+type stateBuilder struct {
+	update StateUpdate
+}
+
+func newState() stateBuilder {
+	return stateBuilder{}
+}
+
+func (s stateBuilder) On(on bool) stateBuilder {
+	s.update.On = boolPtr(on)
+	return s
+}
+
+func (s stateBuilder) Bri(bri uint8) stateBuilder {
+	s.update.Bri = uint8Ptr(bri)
+	return s
+}
+
+func (s stateBuilder) Xy(xy []float32) stateBuilder {
+	s.update.Xy = xy
+	return s
+}
+
+func (s stateBuilder) Ct(mired uint16) stateBuilder {
+	s.update.Ct = uint16Ptr(mired)
+	return s
+}
+
+func (s stateBuilder) Hue(hue uint16) stateBuilder {
+	s.update.Hue = uint16Ptr(hue)
+	return s
+}
+
+func (s stateBuilder) Sat(sat uint8) stateBuilder {
+	s.update.Sat = uint8Ptr(sat)
+	return s
+}
+
+// Transition sets the fade duration in 100ms steps. nil keeps the bridge default.
+func (s stateBuilder) Transition(steps *uint16) stateBuilder {
+	s.update.TransitionTime = steps
+	return s
+}
+
+// Alert sets the alert effect: "select" (one breath), "lselect" (breathe for
+// 15 seconds) or "none"
+func (s stateBuilder) Alert(alert string) stateBuilder {
+	s.update.Alert = alert
+	return s
+}
+
+// Effect sets the dynamic effect: "colorloop" or "none"
+func (s stateBuilder) Effect(effect string) stateBuilder {
+	s.update.Effect = effect
+	return s
+}
+
+// send applies the state to a single light in one request
+func (s stateBuilder) send(light Light) error {
+	return bridge.SetLightState(light.ID, s.update)
+}
+
+// post queues the state for a single light without waiting for the result.
+// Queued changes to the same light are merged, so callers that update lights
+// faster than the bridge accepts only send the newest state.
+func (s stateBuilder) post(light Light) {
+	if client, ok := bridge.(*scheduledClient); ok {
+		client.QueueLightState(light.ID, s.update)
+		return
+	}
+	s.send(light)
+}
+
+// postToGroup is post for a bridge group
+func (s stateBuilder) postToGroup(groupID string) {
+	if client, ok := bridge.(*scheduledClient); ok {
+		client.QueueGroupAction(groupID, s.update)
+		return
+	}
+	s.sendToGroup(groupID)
+}
+
+// sendToGroup applies the state to every light of a bridge group in one
+// request. The bridge skips attributes a light does not support.
+func (s stateBuilder) sendToGroup(groupID string) error {
+	return bridge.SetGroupAction(groupID, s.update)
+}
+
+// bridgeGroupTarget returns the bridge group addressed by identifiers when
+// they name exactly one ("all", a room, a zone or a synced local group), so
+// the change can be sent to the group as a whole
+func bridgeGroupTarget(identifiers []string) (string, bool) {
+	if len(identifiers) != 1 {
+		return "", false
+	}
+	identifier := identifiers[0]
+
+	switch {
+	case identifier == "all":
+		return allLightsGroup, true
+	case strings.HasPrefix(identifier, "g:"):
+		return syncedGroupTarget(identifier[2:])
+	}
+	if _, _, ok := bridgeGroupIdentifier(identifier); ok {
+		if group, err := resolveBridgeGroup(identifier); err == nil {
+			return group.ID, true
+		}
+	}
+	return "", false
+}
+
+// groupTargetName describes a group target for command output
+func groupTargetName(identifier string) string {
+	if identifier == "all" {
+		return "All lights"
+	}
+	return fmt.Sprintf("Group '%s'", identifier)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordingClient records the state requests sent through it as
+// "light <id>: <attributes>" and "group <id>: <attributes>"
+type recordingClient struct {
+	BridgeClient
+	mutex    sync.Mutex
+	requests []string
+}
+
+// recordRequests wraps the bridge of the test in a recordingClient
+func recordRequests(t *testing.T) *recordingClient {
+	t.Helper()
+	client := &recordingClient{BridgeClient: bridge}
+	bridge = client
+	return client
+}
+
+func (c *recordingClient) SetLightState(id int, update StateUpdate) error {
+	c.record(fmt.Sprintf("light %d: %s", id, updateAttributes(update)))
+	return c.BridgeClient.SetLightState(id, update)
+}
+
+func (c *recordingClient) SetGroupAction(groupID string, update StateUpdate) error {
+	c.record(fmt.Sprintf("group %s: %s", groupID, updateAttributes(update)))
+	return c.BridgeClient.SetGroupAction(groupID, update)
+}
+
+func (c *recordingClient) record(request string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.requests = append(c.requests, request)
+}
+
+// updateAttributes lists the attributes an update sets, in sorted order
+func updateAttributes(update StateUpdate) string {
+	data, _ := json.Marshal(update)
+	var fields map[string]interface{}
+	json.Unmarshal(data, &fields)
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, " ")
+}
+
+func TestCombinedStateRequests(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func() error
+		want []string
+	}{
+		{
+			name: "brightness of a light",
+			run:  func() error { return brightnessCmd.RunE(brightnessCmd, []string{"Desk Lamp", "100"}) },
+			want: []string{"light 3: bri on"},
+		},
+		{
+			name: "brightness of all lights",
+			run:  func() error { return brightnessCmd.RunE(brightnessCmd, []string{"all", "100"}) },
+			want: []string{"group 0: bri on"},
+		},
+		{
+			name: "color with brightness",
+			run:  func() error { return colorCmd.RunE(colorCmd, []string{"1", "red", "100"}) },
+			want: []string{"light 1: bri on xy"},
+		},
+		{
+			name: "temperature with brightness",
+			run:  func() error { return tempCmd.RunE(tempCmd, []string{"Bedroom Ambiance", "warm", "100"}) },
+			want: []string{"light 5: bri ct on"},
+		},
+		{
+			name: "color of a room",
+			run:  func() error { return colorCmd.RunE(colorCmd, []string{"r:Living Room", "red", "100"}) },
+			want: []string{"group 1: bri on xy"},
+		},
+		{
+			name: "temperature of a room",
+			run:  func() error { return tempCmd.RunE(tempCmd, []string{"r:Living Room", "warm"}) },
+			want: []string{"group 1: ct on"},
+		},
+		{
+			name: "temperature of all lights with a white light",
+			run:  func() error { return tempCmd.RunE(tempCmd, []string{"all", "warm"}) },
+			want: []string{"light 1: ct on", "light 2: ct on", "light 3: ct on", "light 5: ct on"},
+		},
+		{
+			name: "scene color of a room",
+			run: func() error {
+				return executeSceneCommand(SceneCommand{Type: "color", Light: "r:Living Room", Values: []string{"blue"}}, nil)
+			},
+			want: []string{"group 1: on xy"},
+		},
+		{
+			name: "scene temperature of all lights with a white light",
+			run: func() error {
+				return executeSceneCommand(SceneCommand{Type: "temp", Light: "all", Values: []string{"warm"}}, nil)
+			},
+			want: []string{"light 1: ct on", "light 2: ct on", "light 3: ct on", "light 5: ct on"},
+		},
+		{
+			name: "off",
+			run:  func() error { return offCmd.RunE(offCmd, []string{"1", "2"}) },
+			want: []string{"light 1: on", "light 2: on"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useFakeBridge(t)
+			client := recordRequests(t)
+			if err := test.run(); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(client.requests, test.want) {
+				t.Errorf("requests = %q, want %q", client.requests, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Color temperature range supported by Hue white ambiance lights, in mireds
+const (
+	minMired = 153 // 6500K
+	maxMired = 500 // 2000K
+)
+
+// temperaturePresets are named color temperatures in Kelvin
+var temperaturePresets = map[string]int{
+	"warm":     2700,
+	"neutral":  4000,
+	"daylight": 6500,
+}
+
+// parseColorTemperature parses a color temperature given as Kelvin
+// ("2700K"), mireds ("370mired") or a preset name, and returns it in mireds
+// clamped to the range lights support. Bare numbers of 1000 and above are
+// read as Kelvin, smaller ones as mireds.
+func parseColorTemperature(value string) (uint16, error) {
+	text := strings.ToLower(strings.TrimSpace(value))
+
+	if kelvin, ok := temperaturePresets[text]; ok {
+		return kelvinToMired(kelvin), nil
+	}
+
+	isKelvin := false
+	isMired := false
+	switch {
+	case strings.HasSuffix(text, "mireds"):
+		text, isMired = strings.TrimSuffix(text, "mireds"), true
+	case strings.HasSuffix(text, "mired"):
+		text, isMired = strings.TrimSuffix(text, "mired"), true
+	case strings.HasSuffix(text, "k"):
+		text, isKelvin = strings.TrimSuffix(text, "k"), true
+	}
+
+	number, err := strconv.Atoi(strings.TrimSpace(text))
+	if err != nil || number <= 0 {
+		return 0, fmt.Errorf("invalid color temperature '%s' (use e.g. 2700K, 370mired or one of: %s)", value, strings.Join(temperaturePresetNames(), ", "))
+	}
+
+	if !isMired && (isKelvin || number >= 1000) {
+		return kelvinToMired(number), nil
+	}
+	return clampMired(number), nil
+}
+
+func kelvinToMired(kelvin int) uint16 {
+	return clampMired(int(math.Round(1e6 / float64(kelvin))))
+}
+
+func miredToKelvin(mired uint16) int {
+	if mired == 0 {
+		return 0
+	}
+	return int(math.Round(1e6 / float64(mired)))
+}
+
+func clampMired(mired int) uint16 {
+	if mired < minMired {
+		return minMired
+	}
+	if mired > maxMired {
+		return maxMired
+	}
+	return uint16(mired)
+}
+
+// lightMired clamps mired to the color temperature range light reports.
+// Many lights cover less than 2000K-6500K; lights that report no range are
+// left to the bridge.
+func lightMired(light Light, mired uint16) uint16 {
+	caps := light.Capabilities
+	if caps == nil || caps.CtMax == 0 {
+		return mired
+	}
+	if mired < caps.CtMin {
+		return caps.CtMin
+	}
+	if mired > caps.CtMax {
+		return caps.CtMax
+	}
+	return mired
+}
+
+func temperaturePresetNames() []string {
+	names := make([]string, 0, len(temperaturePresets))
+	for name := range temperaturePresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// temperatureLights splits lights into those that accept a color
+// temperature and those that do not
+func temperatureLights(lights []Light) (capable, skipped []Light) {
+	for _, light := range lights {
+		if lightSupportsCt(light.Type) {
+			capable = append(capable, light)
+		} else {
+			skipped = append(skipped, light)
+		}
+	}
+	return capable, skipped
+}
+
+var tempCmd = &cobra.Command{
+	Use:   "temp [light-id/light-name/group] [2700K|370mired|preset] [brightness]",
+	Short: "Set color temperature of lights",
+	Long: `Set the white color temperature of one or more lights, in Kelvin (2700K) or mireds (370mired).
+Bare numbers of 1000 and above are read as Kelvin, smaller ones as mireds. Values are clamped
+to the 2000K-6500K range of Hue lights, and further to the range each light reports. Brightness
+is optional (0-254).
+
+Presets: warm (2700K), neutral (4000K), daylight (6500K)
+
+Examples:
+  hue temp "Desk Lamp" 2700K
+  hue temp g:living-room 370mired 200
+  hue temp all daylight`,
+	Args: cobra.RangeArgs(2, 3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mired, err := parseColorTemperature(args[1])
+		if err != nil {
+			return err
+		}
+
+		state := newState().On(true).Ct(mired)
+		if len(args) == 3 {
+			brightness, err := strconv.Atoi(args[2])
+			if err != nil || brightness < 0 || brightness > 254 {
+				return fmt.Errorf("brightness must be a number between 0 and 254")
+			}
+			state = state.Bri(uint8(brightness))
+		}
+		transition, err := transitionFlag(cmd)
+		if err != nil {
+			return err
+		}
+		state = state.Transition(transition)
+
+		lights, err := resolveTargets(args[:1])
+		if err != nil {
+			return err
+		}
+
+		lights, skipped := temperatureLights(lights)
+		for _, light := range skipped {
+			fmt.Fprintf(os.Stderr, "Warning: Light '%s' does not support color temperature, skipping\n", light.Name)
+		}
+		if len(lights) == 0 {
+			return fmt.Errorf("none of the lights support color temperature")
+		}
+
+		kelvin := miredToKelvin(mired)
+
+		// A bridge group gets the temperature in a single request and the
+		// bridge clamps it to each light's range. Groups with lights without
+		// color temperature are set light by light, as the group request would
+		// turn those lights on.
+		if groupID, ok := bridgeGroupTarget(args[:1]); ok && len(skipped) == 0 {
+			if err := state.sendToGroup(groupID); err != nil {
+				return err
+			}
+			fmt.Printf("%s color temperature set to %dK (%d mired)\n", groupTargetName(args[0]), kelvin, mired)
+			return nil
+		}
+
+		all := args[0] == "all"
+		var failures []lightFailure
+		for _, light := range lights {
+			ct := lightMired(light, mired)
+			if err := state.Ct(ct).send(light); err != nil {
+				failures = append(failures, lightFailure{Name: light.Name, Err: err})
+				continue
+			}
+			if !all {
+				fmt.Printf("Light '%s' color temperature set to %dK (%d mired)\n", light.Name, miredToKelvin(ct), ct)
+			}
+		}
+		updated := len(lights) - len(failures)
+		err = lightsResult(len(lights), failures)
+
+		if all && err == nil {
+			fmt.Printf("All lights color temperature set to %dK (%d mired)\n", kelvin, mired)
+		}
+		if !all && updated > 1 {
+			fmt.Printf("Total: %d lights color temperature set to %dK (%d mired)\n", updated, kelvin, mired)
+		}
+		return err
+	},
+}
+
+// kelvinToRGB approximates the RGB color of a white light at kelvin
+func kelvinToRGB(kelvin int) (uint8, uint8, uint8) {
+	t := float64(kelvin) / 100
+
+	var r, g, b float64
+	if t <= 66 {
+		r = 255
+		g = 99.4708025861*math.Log(t) - 161.1195681661
+	} else {
+		r = 329.698727446 * math.Pow(t-60, -0.1332047592)
+		g = 288.1221695283 * math.Pow(t-60, -0.0755148492)
+	}
+
+	switch {
+	case t >= 66:
+		b = 255
+	case t <= 19:
+		b = 0
+	default:
+		b = 138.5177312231*math.Log(t-10) - 305.0447927307
+	}
+
+	return clampByte(r), clampByte(g), clampByte(b)
+}
+
+func clampByte(value float64) uint8 {
+	return uint8(math.Max(0, math.Min(255, math.Round(value))))
+}
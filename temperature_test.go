@@ -0,0 +1,141 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseColorTemperature(t *testing.T) {
+	tests := []struct {
+		value string
+		want  uint16
+		ok    bool
+	}{
+		{value: "2700K", want: 370, ok: true},
+		{value: "2700k", want: 370, ok: true},
+		{value: "4000", want: 250, ok: true},
+		{value: "370mired", want: 370, ok: true},
+		{value: "370 mireds", want: 370, ok: true},
+		{value: "370", want: 370, ok: true},
+		{value: "warm", want: 370, ok: true},
+		{value: "Daylight", want: 154, ok: true},
+		{value: "10000K", want: minMired, ok: true},
+		{value: "1000K", want: maxMired, ok: true},
+		{value: "50mired", want: minMired, ok: true},
+		{value: "hot"},
+		{value: "-2700K"},
+		{value: "0"},
+	}
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			got, err := parseColorTemperature(test.value)
+			if (err == nil) != test.ok || got != test.want {
+				t.Errorf("parseColorTemperature(%q) = %d, %v; want %d (ok %t)", test.value, got, err, test.want, test.ok)
+			}
+		})
+	}
+}
+
+func TestTempCmd(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		wantCt    map[int]uint16
+		wantBri   uint8
+		code      int
+		unchanged []int
+		ctRange   [2]uint16 // range light 5 reports, if set
+	}{
+		{name: "one light", args: []string{"Bedroom Ambiance", "2700K"}, wantCt: map[int]uint16{5: 370}},
+		{name: "with brightness", args: []string{"1", "warm", "100"}, wantCt: map[int]uint16{1: 370}, wantBri: 100},
+		{name: "white lights are skipped", args: []string{"all", "daylight"}, wantCt: map[int]uint16{1: 154, 2: 154, 3: 154, 5: 154}, unchanged: []int{4}},
+		{name: "clamped to the light's range", args: []string{"Bedroom Ambiance", "2000K"}, wantCt: map[int]uint16{5: 454}, ctRange: [2]uint16{153, 454}},
+		{name: "each light's own range", args: []string{"all", "2000K"}, wantCt: map[int]uint16{1: 500, 5: 454}, ctRange: [2]uint16{153, 454}, unchanged: []int{4}},
+		{name: "only white lights", args: []string{"Hallway", "2700K"}, code: exitError, unchanged: []int{4}},
+		{name: "invalid temperature", args: []string{"1", "hot"}, code: exitError, unchanged: []int{1}},
+		{name: "invalid brightness", args: []string{"1", "2700K", "300"}, code: exitError, unchanged: []int{1}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := useFakeBridge(t)
+			if test.ctRange[1] != 0 {
+				fake.lights[5].Capabilities.CtMin, fake.lights[5].Capabilities.CtMax = test.ctRange[0], test.ctRange[1]
+			}
+			checkExitCode(t, tempCmd.RunE(tempCmd, test.args), test.code)
+
+			for id, ct := range test.wantCt {
+				light := fakeLight(t, fake, id)
+				if !light.State.On || light.State.ColorMode != "ct" || light.State.Ct != ct {
+					t.Errorf("light %d = on %t, colormode %q, ct %d; want on, ct %d", id, light.State.On, light.State.ColorMode, light.State.Ct, ct)
+				}
+				if test.wantBri != 0 && light.State.Bri != test.wantBri {
+					t.Errorf("light %d bri = %d, want %d", id, light.State.Bri, test.wantBri)
+				}
+			}
+			for _, id := range test.unchanged {
+				if fakeLight(t, fake, id).State.On {
+					t.Errorf("light %d was turned on", id)
+				}
+			}
+		})
+	}
+}
+
+func TestLightMired(t *testing.T) {
+	narrow := Light{Capabilities: &LightCapabilities{CtMin: 200, CtMax: 454}}
+	tests := []struct {
+		name  string
+		light Light
+		mired uint16
+		want  uint16
+	}{
+		{name: "inside the range", light: narrow, mired: 370, want: 370},
+		{name: "too cold", light: narrow, mired: 153, want: 200},
+		{name: "too warm", light: narrow, mired: 500, want: 454},
+		{name: "no reported range", light: Light{}, mired: 500, want: 500},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := lightMired(test.light, test.mired); got != test.want {
+				t.Errorf("lightMired(%d) = %d, want %d", test.mired, got, test.want)
+			}
+		})
+	}
+}
+
+func TestLightColorMessageColors(t *testing.T) {
+	warmR, warmG, warmB := kelvinToRGB(2703)
+	tests := []struct {
+		name string
+		msg  LightColorMessage
+		want map[string]RGB
+		fail bool
+	}{
+		{
+			name: "rgb",
+			msg:  LightColorMessage{Lights: map[string]LightColorValue{"1": {R: 255, G: 10}}},
+			want: map[string]RGB{"1": {R: 255, G: 10}},
+		},
+		{
+			name: "temperature and rgb",
+			msg:  LightColorMessage{Lights: map[string]LightColorValue{"1": {Temp: "2700K"}, "2": {Temp: "370mired"}, "3": {B: 9}}},
+			want: map[string]RGB{"1": {warmR, warmG, warmB}, "2": {warmR, warmG, warmB}, "3": {B: 9}},
+		},
+		{
+			name: "invalid temperature",
+			msg:  LightColorMessage{Lights: map[string]LightColorValue{"1": {Temp: "hot"}}},
+			fail: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.msg.colors()
+			if (err != nil) != test.fail {
+				t.Fatalf("err = %v, want failure %t", err, test.fail)
+			}
+			if !test.fail && !reflect.DeepEqual(got, test.want) {
+				t.Errorf("colors = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
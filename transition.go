@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// maxTransition is the longest transition the bridge accepts, as its
+// transitiontime is a 16 bit count of 100ms steps
+const maxTransition = math.MaxUint16 * 100 * time.Millisecond
+
+// parseDuration parses a duration such as "400ms", "2s" or "1m30s". Bare
+// numbers are read as seconds.
+func parseDuration(value string) (time.Duration, bool) {
+	text := strings.TrimSpace(value)
+
+	duration, err := time.ParseDuration(text)
+	if err != nil {
+		seconds, numErr := strconv.ParseFloat(text, 64)
+		if numErr != nil {
+			return 0, false
+		}
+		duration = time.Duration(seconds * float64(time.Second))
+	}
+	return duration, true
+}
+
+// parseTransition parses a transition duration and returns it in the
+// bridge's 100ms steps
+func parseTransition(value string) (uint16, error) {
+	duration, ok := parseDuration(value)
+	if !ok {
+		return 0, fmt.Errorf("invalid transition '%s' (use e.g. 400ms, 2s or 1m)", value)
+	}
+
+	if duration < 0 || duration > maxTransition {
+		return 0, fmt.Errorf("transition must be between 0s and %s", maxTransition)
+	}
+	return uint16(math.Round(float64(duration) / float64(100*time.Millisecond))), nil
+}
+
+// transitionFlag returns the --transition value of cmd in 100ms steps, or
+// nil when the flag was not given so the bridge default applies
+func transitionFlag(cmd *cobra.Command) (*uint16, error) {
+	value, _ := cmd.Flags().GetString("transition")
+	if value == "" {
+		return nil, nil
+	}
+	steps, err := parseTransition(value)
+	if err != nil {
+		return nil, err
+	}
+	return uint16Ptr(steps), nil
+}
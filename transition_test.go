@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestParseTransition(t *testing.T) {
+	tests := []struct {
+		value string
+		want  uint16
+		ok    bool
+	}{
+		{value: "0", want: 0, ok: true},
+		{value: "400ms", want: 4, ok: true},
+		{value: "2s", want: 20, ok: true},
+		{value: "1.5", want: 15, ok: true},
+		{value: "1m30s", want: 900, ok: true},
+		{value: "150ms", want: 2, ok: true},
+		{value: "6553.5s", want: 65535, ok: true},
+		{value: "2h"},
+		{value: "-1s"},
+		{value: "soon"},
+	}
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			got, err := parseTransition(test.value)
+			if (err == nil) != test.ok || got != test.want {
+				t.Errorf("parseTransition(%q) = %d, %v; want %d (ok %t)", test.value, got, err, test.want, test.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/spf13/cobra"
+)
+
+// appName is the application part of the devicetype of users created by hue
+const appName = "hue_cli"
+
+// deviceType returns the devicetype new bridge users are created with,
+// "hue_cli#<device>", where device defaults to this machine's host name.
+// The bridge allows at most 19 bytes for the device name, so longer names
+// are cut at a character boundary.
+func deviceType(device string) string {
+	if device == "" {
+		device, _ = os.Hostname()
+		device, _, _ = strings.Cut(device, ".")
+	}
+	if device == "" {
+		device = "device"
+	}
+	for len(device) > 19 {
+		_, size := utf8.DecodeLastRuneInString(device)
+		device = device[:len(device)-size]
+	}
+	return appName + "#" + device
+}
+
+// parseAge parses an age such as 90d, 2w or 36h
+func parseAge(value string) (time.Duration, error) {
+	text := strings.TrimSpace(value)
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if number, ok := strings.CutSuffix(text, suffix); ok {
+			if n, err := strconv.Atoi(number); err == nil && n >= 0 {
+				return time.Duration(n) * unit, nil
+			}
+		}
+	}
+	if duration, err := time.ParseDuration(text); err == nil && duration >= 0 {
+		return duration, nil
+	}
+	return 0, fmt.Errorf("invalid age '%s' (use e.g. 90d, 2w or 36h)", value)
+}
+
+var usersCmd = &cobra.Command{
+	Use:   "users",
+	Short: "Manage the apps paired with the bridge",
+	Long: `List and remove the users (apps and devices) whitelisted on the bridge. Every 'hue auth'
+and every phone or app that pairs with the bridge adds one.`,
+}
+
+func init() {
+	usersCmd.AddCommand(usersListCmd)
+	usersCmd.AddCommand(usersPruneCmd)
+
+	usersListCmd.Flags().Bool("show-secrets", false, "Show the usernames in full")
+	usersPruneCmd.Flags().String("older-than", "", "Only remove users not used for this long (e.g. 90d)")
+	usersPruneCmd.Flags().String("name", "", "Only remove users whose name matches this pattern (e.g. 'hue_cli*')")
+	usersPruneCmd.Flags().Bool("dry-run", false, "Show the users that would be removed without removing them")
+	usersPruneCmd.Flags().BoolP("yes", "y", false, "Remove the users without asking for confirmation")
+}
+
+// ownUsername returns the username this invocation uses, which is never pruned
+func ownUsername() string {
+	config, err := loadBridgeConfig()
+	if err != nil {
+		return ""
+	}
+	return config.Username
+}
+
+var usersListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the users whitelisted on the bridge",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		showSecrets, _ := cmd.Flags().GetBool("show-secrets")
+
+		users, err := bridge.GetUsers()
+		if err != nil {
+			return fmt.Errorf("failed to get users: %w", err)
+		}
+		own := ownUsername()
+
+		doc := usersDocument{Users: []userDocument{}}
+		for _, user := range users {
+			username := user.ID
+			if !showSecrets {
+				username = redact(username)
+			}
+			doc.Users = append(doc.Users, userDocument{Name: user.Name, Username: username, Created: user.Created, LastUsed: user.LastUsed, Current: user.ID == own})
+		}
+
+		return printOutput(doc, func(w io.Writer) {
+			fmt.Fprintln(w, "Name\tCreated\tLast Used\tUsername")
+			for _, user := range doc.Users {
+				marker := ""
+				if user.Current {
+					marker = " *"
+				}
+				fmt.Fprintf(w, "%s%s\t%s\t%s\t%s\n", user.Name, marker, formatUserDate(user.Created), formatUserDate(user.LastUsed), user.Username)
+			}
+		})
+	},
+}
+
+var usersPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove old or unused users from the bridge",
+	Long: `Remove the users that match all of the given filters. The user this command runs as is
+never removed. The users are listed and removed after confirmation.
+
+Current bridge firmware no longer lets API users remove others; on those bridges this
+command reports it and users can only be removed from your Hue account.
+
+Examples:
+  hue users prune --older-than 90d --name 'hue_cli*'
+  hue users prune --name 'hue_cli#old-laptop' --yes`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		olderThan, _ := cmd.Flags().GetString("older-than")
+		pattern, _ := cmd.Flags().GetString("name")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		yes, _ := cmd.Flags().GetBool("yes")
+
+		if olderThan == "" && pattern == "" {
+			return fmt.Errorf("give --older-than, --name or both to choose the users to remove")
+		}
+		var cutoff time.Time
+		if olderThan != "" {
+			age, err := parseAge(olderThan)
+			if err != nil {
+				return err
+			}
+			cutoff = time.Now().Add(-age)
+		}
+		if pattern != "" {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid name pattern '%s': %w", pattern, err)
+			}
+		}
+
+		users, err := bridge.GetUsers()
+		if err != nil {
+			return fmt.Errorf("failed to get users: %w", err)
+		}
+		own := ownUsername()
+
+		var matches []BridgeUser
+		for _, user := range users {
+			if user.ID == own {
+				continue
+			}
+			if pattern != "" {
+				if ok, _ := path.Match(pattern, user.Name); !ok {
+					continue
+				}
+			}
+			if !cutoff.IsZero() && userLastActive(user).After(cutoff) {
+				continue
+			}
+			matches = append(matches, user)
+		}
+
+		if len(matches) == 0 {
+			fmt.Println("No users to remove")
+			return nil
+		}
+		for _, user := range matches {
+			fmt.Printf("- %s (%s, last used %s)\n", user.Name, redact(user.ID), formatUserDate(user.LastUsed))
+		}
+		if dryRun {
+			fmt.Printf("%d users would be removed\n", len(matches))
+			return nil
+		}
+		if !yes && !confirm(fmt.Sprintf("Remove these %d users?", len(matches))) {
+			return fmt.Errorf("cancelled, no users removed")
+		}
+
+		var failed []string
+		for _, user := range matches {
+			err := bridge.DeleteUser(user.ID)
+			if errors.Is(err, errUserDeletionUnsupported) {
+				return err
+			}
+			if err != nil {
+				fmt.Printf("  Error removing %s: %v\n", user.Name, err)
+				failed = append(failed, user.Name)
+			}
+		}
+		switch {
+		case len(failed) == len(matches):
+			return fmt.Errorf("failed to remove %d users", len(failed))
+		case len(failed) > 0:
+			return partialFailureError("%d/%d users could not be removed: %s", len(failed), len(matches), strings.Join(failed, ", "))
+		}
+		fmt.Printf("Removed %d users\n", len(matches))
+		return nil
+	},
+}
+
+// userLastActive returns when a user was last used, or created if never used
+func userLastActive(user BridgeUser) time.Time {
+	if user.LastUsed.After(user.Created) {
+		return user.LastUsed
+	}
+	return user.Created
+}
+
+func formatUserDate(date time.Time) string {
+	if date.IsZero() {
+		return "never"
+	}
+	return date.Local().Format("2006-01-02 15:04")
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeviceType(t *testing.T) {
+	tests := []struct {
+		device string
+		want   string
+	}{
+		{device: "laptop", want: "hue_cli#laptop"},
+		{device: "a-very-long-host-name-indeed", want: "hue_cli#a-very-long-host-na"},
+		{device: "wohnzimmer-rechner-ü", want: "hue_cli#wohnzimmer-rechner-"},
+		{device: "ççççççççççç", want: "hue_cli#ççççççççç"},
+	}
+	for _, test := range tests {
+		t.Run(test.device, func(t *testing.T) {
+			if got := deviceType(test.device); got != test.want {
+				t.Errorf("deviceType(%q) = %q, want %q", test.device, got, test.want)
+			}
+		})
+	}
+	if got := deviceType(""); !strings.HasPrefix(got, "hue_cli#") || len(got) > len("hue_cli#")+19 {
+		t.Errorf("deviceType(\"\") = %q", got)
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		fails bool
+	}{
+		{value: "90d", want: 90 * 24 * time.Hour},
+		{value: "2w", want: 14 * 24 * time.Hour},
+		{value: "36h", want: 36 * time.Hour},
+		{value: " 1d ", want: 24 * time.Hour},
+		{value: "-1d", fails: true},
+		{value: "soon", fails: true},
+	}
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			got, err := parseAge(test.value)
+			if (err != nil) != test.fails {
+				t.Fatalf("parseAge error = %v, want failure %t", err, test.fails)
+			}
+			if got != test.want {
+				t.Errorf("parseAge = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestUsersPruneCmd(t *testing.T) {
+	tests := []struct {
+		name      string
+		olderThan string
+		pattern   string
+		dryRun    bool
+		removed   []string
+		code      int
+	}{
+		{name: "older than", olderThan: "90d", removed: []string{"hue_cli#device", "hue_cli#device"}},
+		{name: "by name", pattern: "Hue#*", removed: []string{"Hue#iPhone"}},
+		{name: "invalid age", olderThan: "7M", code: exitError},
+		{name: "name and age", olderThan: "26w", pattern: "hue_cli*", removed: []string{"hue_cli#device"}},
+		{name: "dry run", pattern: "*", dryRun: true},
+		{name: "own user is kept", pattern: "hue_cli#own"},
+		{name: "no filter", code: exitError},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := useFakeBridge(t)
+			now := time.Now().UTC()
+			fake.addUser("hue_cli#own", now.AddDate(-3, 0, 0), now.AddDate(-3, 0, 0))
+			users, _ := fake.getUsers("test")
+			var own string
+			for _, user := range users {
+				if user.Name == "hue_cli#own" {
+					own = user.ID
+				}
+			}
+			if err := saveBridgeConfig(BridgeConfig{Host: "192.168.1.2", Username: own}); err != nil {
+				t.Fatal(err)
+			}
+
+			flags := usersPruneCmd.Flags()
+			flags.Set("older-than", test.olderThan)
+			flags.Set("name", test.pattern)
+			flags.Set("dry-run", strconv.FormatBool(test.dryRun))
+			flags.Set("yes", "true")
+			defer func() {
+				flags.Set("older-than", "")
+				flags.Set("name", "")
+				flags.Set("dry-run", "false")
+				flags.Set("yes", "false")
+			}()
+
+			var err error
+			captureStdout(t, func() { err = usersPruneCmd.RunE(usersPruneCmd, nil) })
+			checkExitCode(t, err, test.code)
+
+			left, _ := fake.getUsers("test")
+			var removed []string
+			for _, user := range users {
+				if !containsUser(left, user.ID) {
+					removed = append(removed, user.Name)
+				}
+			}
+			sort.Strings(removed)
+			if strings.Join(removed, ",") != strings.Join(test.removed, ",") {
+				t.Errorf("removed %q, want %q", removed, test.removed)
+			}
+		})
+	}
+}
+
+func containsUser(users []BridgeUser, id string) bool {
+	for _, user := range users {
+		if user.ID == id {
+			return true
+		}
+	}
+	return false
+}